@@ -0,0 +1,162 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CorsPolicy bir route için geçerli CORS kurallarını tutan yapı
+type CorsPolicy struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// errWildcardCredentials "*" origin'i kimlik bilgili isteklerle birlikte kullanılamaz
+var errWildcardCredentials = errors.New(`cors: wildcard origin "*" can not be combined with AllowCredentials`)
+
+// NewCorsPolicy politikayı doğrular. "*" ile AllowCredentials birlikte verilirse her siteye
+// kimlik bilgili istek izni verilmiş olur, bu yüzden reddedilir.
+func NewCorsPolicy(p CorsPolicy) (*CorsPolicy, error) {
+	if p.AllowCredentials && p.wildcard() {
+		return nil, errWildcardCredentials
+	}
+	return &p, nil
+}
+
+// mustCorsPolicy geçersiz politikada sunucuyu başlatmaz
+func mustCorsPolicy(p CorsPolicy) *CorsPolicy {
+	policy, err := NewCorsPolicy(p)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return policy
+}
+
+// defaultAllowedOrigins CORS_ALLOWED_ORIGINS ortam değişkeninden izinli origin listesini okur
+func defaultAllowedOrigins() []string {
+	env := os.Getenv("CORS_ALLOWED_ORIGINS")
+	if env == "" {
+		return []string{"http://localhost:3000"}
+	}
+
+	var origins []string
+	for _, origin := range strings.Split(env, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// allowsOrigin origin'in politikada izinli olup olmadığını kontrol eder
+func (p *CorsPolicy) allowsOrigin(origin string) bool {
+	for _, allowed := range p.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsMethod ön uçuş isteğinde istenen metodun izinli olup olmadığını kontrol eder
+func (p *CorsPolicy) allowsMethod(method string) bool {
+	for _, allowed := range p.AllowedMethods {
+		if strings.EqualFold(allowed, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// wildcard tüm origin'lere izin verilip verilmediğini döner
+func (p *CorsPolicy) wildcard() bool {
+	for _, allowed := range p.AllowedOrigins {
+		if allowed == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// Handler handler'ı politikaya göre CORS başlıklarını ekleyen ve OPTIONS ön uçuş isteklerini
+// cevaplayan bir middleware ile sarar. OPTIONS istekleri hiçbir zaman handler'a ulaşmaz ve her zaman
+// 204 ile cevaplanır; origin veya metot izinli değilse CORS başlıkları eklenmez, tarayıcı isteği engeller.
+func (p *CorsPolicy) Handler(next http.HandlerFunc) http.HandlerFunc {
+	// NewCorsPolicy kullanılmadan "*" ile kurulmuş politikada kimlik bilgisine izin verilmez
+	allowCredentials := p.AllowCredentials && !p.wildcard()
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Origin")
+
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			if r.Method == http.MethodOptions {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			// Tarayıcı dışı istek, CORS uygulanmaz
+			next(w, r)
+			return
+		}
+
+		if !p.allowsOrigin(origin) {
+			if r.Method == http.MethodOptions {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			// Başlık eklenmez, tarayıcı yanıtı engeller
+			next(w, r)
+			return
+		}
+
+		if p.wildcard() {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+		if allowCredentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		// Ön uçuş olmayan OPTIONS istekleri de handler'a iletilmeden 204 ile cevaplanır
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") == "" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		// OPTIONS isteği için CORS ön uçuş kontrolü
+		if r.Method == http.MethodOptions {
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+
+			if !p.allowsMethod(r.Header.Get("Access-Control-Request-Method")) {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(p.AllowedMethods, ", "))
+			if len(p.AllowedHeaders) > 0 {
+				w.Header().Set("Access-Control-Allow-Headers", strings.Join(p.AllowedHeaders, ", "))
+			}
+			if p.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(p.MaxAge.Seconds())))
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if len(p.ExposedHeaders) > 0 {
+			w.Header().Set("Access-Control-Expose-Headers", strings.Join(p.ExposedHeaders, ", "))
+		}
+		next(w, r)
+	}
+}
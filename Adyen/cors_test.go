@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCorsPolicyHandler(t *testing.T) {
+	policy := &CorsPolicy{
+		AllowedOrigins:   []string{"https://shop.example.com"},
+		AllowedMethods:   []string{"POST"},
+		AllowedHeaders:   []string{"Content-Type"},
+		ExposedHeaders:   []string{"X-Request-Id"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+	handler := policy.Handler(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	tests := []struct {
+		name          string
+		method        string
+		origin        string
+		requestMethod string
+		wantStatus    int
+		wantOrigin    string
+		wantMethods   string
+	}{
+		{"no origin", http.MethodPost, "", "", http.StatusCreated, "", ""},
+		{"allowed origin", http.MethodPost, "https://shop.example.com", "", http.StatusCreated, "https://shop.example.com", ""},
+		{"origin is case insensitive", http.MethodPost, "https://SHOP.example.com", "", http.StatusCreated, "https://SHOP.example.com", ""},
+		{"disallowed origin", http.MethodPost, "https://evil.example.com", "", http.StatusCreated, "", ""},
+		{"options without origin", http.MethodOptions, "", "", http.StatusNoContent, "", ""},
+		{"options without request method", http.MethodOptions, "https://shop.example.com", "", http.StatusNoContent, "https://shop.example.com", ""},
+		{"preflight", http.MethodOptions, "https://shop.example.com", "POST", http.StatusNoContent, "https://shop.example.com", "POST"},
+		{"preflight with disallowed method", http.MethodOptions, "https://shop.example.com", "DELETE", http.StatusNoContent, "https://shop.example.com", ""},
+		{"preflight from disallowed origin", http.MethodOptions, "https://evil.example.com", "POST", http.StatusNoContent, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/create-payment", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.requestMethod != "" {
+				req.Header.Set("Access-Control-Request-Method", tt.requestMethod)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Methods"); got != tt.wantMethods {
+				t.Errorf("Allow-Methods = %q, want %q", got, tt.wantMethods)
+			}
+			if tt.wantOrigin != "" && rec.Header().Get("Access-Control-Allow-Credentials") != "true" {
+				t.Error("Allow-Credentials is missing")
+			}
+		})
+	}
+}
+
+func TestCorsPolicyWildcard(t *testing.T) {
+	policy := &CorsPolicy{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"GET"}}
+	handler := policy.Handler(func(w http.ResponseWriter, r *http.Request) {})
+
+	req := httptest.NewRequest(http.MethodGet, "/payment-methods", nil)
+	req.Header.Set("Origin", "https://any.example.com")
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Allow-Origin = %q, want *", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("Allow-Credentials = %q, want none", got)
+	}
+}
+
+func TestNewCorsPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  CorsPolicy
+		wantErr error
+	}{
+		{"origin list with credentials", CorsPolicy{AllowedOrigins: []string{"https://shop.example.com"}, AllowCredentials: true}, nil},
+		{"wildcard without credentials", CorsPolicy{AllowedOrigins: []string{"*"}}, nil},
+		{"wildcard with credentials", CorsPolicy{AllowedOrigins: []string{"https://shop.example.com", "*"}, AllowCredentials: true}, errWildcardCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := NewCorsPolicy(tt.policy)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && policy == nil {
+				t.Error("nil policy without an error")
+			}
+		})
+	}
+}
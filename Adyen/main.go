@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
+	"time"
 )
 
 const apiKey = ""
@@ -88,10 +89,6 @@ func getPaymentMethods() (*PaymentMethodsResponse, error) {
 
 // createPayment ödeme oluşturma işlemi
 func createPayment(w http.ResponseWriter, r *http.Request) {
-	enableCors(&w)
-	if r.Method == "OPTIONS" {
-		return
-	}
 	var paymentReq PaymentRequest
 	err := json.NewDecoder(r.Body).Decode(&paymentReq)
 	if err != nil {
@@ -149,30 +146,39 @@ func createPayment(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(paymentResp)
 }
 
+// paymentCorsPolicy ödeme oluşturma route'u için CORS politikası
+// CORS_ALLOWED_ORIGINS "*" içeriyorsa sunucu başlamaz
+var paymentCorsPolicy = mustCorsPolicy(CorsPolicy{
+	AllowedOrigins:   defaultAllowedOrigins(),
+	AllowedMethods:   []string{"POST", "OPTIONS"},
+	AllowedHeaders:   []string{"Content-Type", "X-API-Key"},
+	AllowCredentials: true,
+	MaxAge:           10 * time.Minute,
+})
+
+// paymentMethodsCorsPolicy ödeme yöntemleri route'u için CORS politikası
+var paymentMethodsCorsPolicy = mustCorsPolicy(CorsPolicy{
+	AllowedOrigins: defaultAllowedOrigins(),
+	AllowedMethods: []string{"GET", "POST", "OPTIONS"},
+	AllowedHeaders: []string{"Content-Type"},
+	MaxAge:         time.Hour,
+})
+
+// listPaymentMethods ödeme yöntemlerini JSON olarak döner
+func listPaymentMethods(w http.ResponseWriter, r *http.Request) {
+	paymentMethods, err := getPaymentMethods()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(paymentMethods)
+}
+
 // main fonksiyonu HTTP sunucusunu başlatır ve endpoint'leri dinler
 func main() {
-	http.HandleFunc("/create-payment", createPayment)
-	http.HandleFunc("/payment-methods", func(w http.ResponseWriter, r *http.Request) {
-		/*enableCors(&w)
-		// OPTIONS isteği için CORS ön uçuş kontrolü
-		// Bu, tarayıcının CORS politikasını doğrulamasına izin verir
-		if r.Method == "OPTIONS" {
-			return
-		} */
-		paymentMethods, err := getPaymentMethods()
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(paymentMethods)
-	})
+	http.HandleFunc("/create-payment", paymentCorsPolicy.Handler(createPayment))
+	http.HandleFunc("/payment-methods", paymentMethodsCorsPolicy.Handler(listPaymentMethods))
 	fmt.Println("Server started at :8080")
 	log.Fatal(http.ListenAndServe(":8080", nil))
 }
-
-func enableCors(w *http.ResponseWriter) {
-	(*w).Header().Set("Access-Control-Allow-Origin", "*")
-	(*w).Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-	(*w).Header().Set("Access-Control-Allow-Headers", "Content-Type, X-API-Key")
-}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"paypal/paypal"
+)
+
+// authorizeApprovedOrder authorize intent'li order'ı /success dönüşünde bloke eder. Provizyon
+// alınamazsa state geri yüklenir, kullanıcı aynı dönüş linkiyle tekrar deneyebilir.
+func authorizeApprovedOrder(w http.ResponseWriter, r *http.Request, payment Payment, state redirectState) {
+	order, err := client.AuthorizeOrder(payment.PayPalOrderID, payment.orderRequestID("authorize"))
+	if err != nil {
+		payments.restoreState(state)
+		writePayPalError(w, "Failed to authorize payment", err)
+		return
+	}
+
+	authorizations := order.Authorizations()
+	if order.Status != "COMPLETED" || len(authorizations) == 0 || authorizations[0].Status != "CREATED" {
+		payments.restoreState(state)
+		http.Error(w, "Payment was not authorized", http.StatusPaymentRequired)
+		return
+	}
+
+	payments.update(payment.OrderID, func(p *Payment) error {
+		p.Status = PaymentAuthorized
+		p.AuthorizationID = authorizations[0].ID
+		p.AuthorizedAt = time.Now()
+		if order.Payer != nil {
+			p.PayerID = order.Payer.PayerID
+		}
+		return nil
+	})
+
+	fmt.Fprintln(w, "Payment authorized successfully! Authorization ID:", authorizations[0].ID)
+}
+
+// ensureHonored honor period dolmuşsa provizyonu bir kez yeniler, geçerlilik süresi dolmuşsa hata döner
+func ensureHonored(payment Payment) (Payment, error) {
+	age := time.Since(payment.AuthorizedAt)
+	if age > paypal.AuthorizationValidity {
+		return payment, fmt.Errorf("authorization %s expired", payment.AuthorizationID)
+	}
+	if age <= paypal.AuthorizationHonorPeriod || payment.Reauthorized {
+		return payment, nil
+	}
+
+	remaining := paypal.NewMoney(payment.Amount-payment.Captured, payment.Currency)
+	auth, err := client.Reauthorize(payment.AuthorizationID, &remaining)
+	if err != nil {
+		return payment, err
+	}
+	log.Printf("order %s: reauthorized %s as %s", payment.OrderID, payment.AuthorizationID, auth.ID)
+
+	// Geçerlilik süresi ilk authorization'dan itibaren sayılmaya devam eder, AuthorizedAt değişmez
+	return payments.update(payment.OrderID, func(p *Payment) error {
+		p.AuthorizationID = auth.ID
+		p.Reauthorized = true
+		return nil
+	})
+}
+
+// authorizationAPIRequest /authorizations/* istekleri
+type authorizationAPIRequest struct {
+	OrderID      string `json:"order_id"`
+	Amount       string `json:"amount,omitempty"`
+	FinalCapture *bool  `json:"final_capture,omitempty"`
+	InvoiceID    string `json:"invoice_id,omitempty"`
+}
+
+// decodeAuthorizationRequest isteği çözer ve authorize edilmiş ödemeyi bulur
+func decodeAuthorizationRequest(w http.ResponseWriter, r *http.Request) (authorizationAPIRequest, Payment, bool) {
+	var req authorizationAPIRequest
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return req, Payment{}, false
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid payload: "+err.Error(), http.StatusBadRequest)
+		return req, Payment{}, false
+	}
+
+	payment, ok := payments.find(req.OrderID)
+	if !ok {
+		http.Error(w, "Payment not found", http.StatusNotFound)
+		return req, Payment{}, false
+	}
+	if payment.AuthorizationID == "" || (payment.Status != PaymentAuthorized && payment.Status != PaymentPartiallyCaptured) {
+		http.Error(w, "Payment is not authorized", http.StatusConflict)
+		return req, Payment{}, false
+	}
+	return req, payment, true
+}
+
+/*
+	Restoran siparişi onayladığında provizyonun tamamı veya bir kısmı tahsil edilir (API-Key gerekir):
+	curl -X POST http://localhost:3000/authorizations/capture \
+	-H "API-Key: <ADMIN_API_KEY>" -H "Content-Type: application/json" \
+	-d '{"order_id": "<ORDER_ID>", "amount": "18.50", "final_capture": true}'
+*/
+
+func handleCaptureAuthorization(w http.ResponseWriter, r *http.Request) {
+	req, payment, ok := decodeAuthorizationRequest(w, r)
+	if !ok {
+		return
+	}
+
+	remaining := payment.Amount - payment.Captured
+	amount := remaining
+	if req.Amount != "" {
+		var err error
+		if amount, err = paypal.ParseMinorUnits(req.Amount, payment.Currency); err != nil {
+			http.Error(w, "Invalid amount: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if amount <= 0 || amount > remaining {
+		http.Error(w, fmt.Sprintf("Capture amount must be between 0 and %s", paypal.FormatMinorUnits(remaining, payment.Currency)), http.StatusBadRequest)
+		return
+	}
+	finalCapture := amount == remaining
+	if req.FinalCapture != nil {
+		finalCapture = *req.FinalCapture
+	}
+
+	payment, err := ensureHonored(payment)
+	if err != nil {
+		writePayPalError(w, "Failed to reauthorize payment", err)
+		return
+	}
+
+	captureAmount := paypal.NewMoney(amount, payment.Currency)
+	capture, err := client.CaptureAuthorization(payment.AuthorizationID, paypal.CaptureRequest{
+		// Tekrar denenen tahsilat PayPal'da ikinci kez işlenmez, bkz. handleRefund
+		RequestID:    fmt.Sprintf("%s-capture-%d-%d-%d", payment.AuthorizationID, payment.Captured, amount, payment.Attempts),
+		Amount:       &captureAmount,
+		InvoiceID:    req.InvoiceID,
+		FinalCapture: finalCapture,
+	})
+	var apiErr *paypal.APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode < 500 {
+		// PayPal tahsilatı reddetti, sonraki deneme yeni bir ID ile gönderilir
+		payments.update(payment.OrderID, func(p *Payment) error {
+			p.Attempts++
+			return nil
+		})
+	}
+	if err != nil {
+		writePayPalError(w, "Failed to capture payment", err)
+		return
+	}
+
+	// Aynı capture'ın webhook'u daha önce geldiyse tutar tekrar eklenmez
+	payment, _ = payments.update(payment.OrderID, func(p *Payment) error {
+		return p.applyCapture(capture.ID, &captureAmount, finalCapture)
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"capture_id":     capture.ID,
+		"capture_status": capture.Status,
+		"amount":         captureAmount.Value,
+		"currency":       captureAmount.CurrencyCode,
+		"payment_status": payment.Status,
+		"captured_total": paypal.FormatMinorUnits(payment.Captured, payment.Currency),
+	})
+}
+
+// handleVoidAuthorization restoranın reddettiği siparişin provizyonunu iptal eder
+func handleVoidAuthorization(w http.ResponseWriter, r *http.Request) {
+	_, payment, ok := decodeAuthorizationRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := client.VoidAuthorization(payment.AuthorizationID); err != nil {
+		writePayPalError(w, "Failed to void authorization", err)
+		return
+	}
+
+	payment, _ = payments.update(payment.OrderID, func(p *Payment) error {
+		if p.Captured > 0 {
+			// Kısmi tahsilattan sonra kalan provizyon serbest bırakıldı
+			p.Status = PaymentCompleted
+		} else {
+			p.Status = PaymentVoided
+		}
+		return nil
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"authorization_id": payment.AuthorizationID,
+		"payment_status":   payment.Status,
+	})
+}
+
+// handleReauthorize honor period dolmuş provizyonu elle yeniler
+func handleReauthorize(w http.ResponseWriter, r *http.Request) {
+	_, payment, ok := decodeAuthorizationRequest(w, r)
+	if !ok {
+		return
+	}
+	if time.Since(payment.AuthorizedAt) <= paypal.AuthorizationHonorPeriod {
+		http.Error(w, "Authorization is still within its honor period", http.StatusConflict)
+		return
+	}
+
+	payment, err := ensureHonored(payment)
+	if err != nil {
+		writePayPalError(w, "Failed to reauthorize payment", err)
+		return
+	}
+
+	auth, err := client.GetAuthorization(payment.AuthorizationID)
+	if err != nil {
+		writePayPalError(w, "Failed to get authorization", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"authorization_id": auth.ID,
+		"status":           auth.Status,
+		"expiration_time":  auth.ExpirationTime,
+	})
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"paypal/paypal"
+)
+
+// stubPayPal istemciyi handler'a yönlendirir. Token isteği sabit bir token ile yanıtlanır.
+func stubPayPal(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/oauth2/token" {
+			fmt.Fprint(w, `{"access_token": "test-token", "expires_in": 32400}`)
+			return
+		}
+		handler(w, r)
+	}))
+	savedClient, savedPayments, savedKey := client, payments, adminAPIKey
+	t.Cleanup(func() {
+		srv.Close()
+		client, payments, adminAPIKey = savedClient, savedPayments, savedKey
+	})
+
+	client = paypal.NewClient("client-id", "secret", srv.URL)
+	payments = newTestPayments()
+	adminAPIKey = "secret"
+}
+
+func authorizationRequest(t *testing.T, handler http.HandlerFunc, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/authorizations", strings.NewReader(body))
+	req.Header.Set("API-Key", "secret")
+	rec := httptest.NewRecorder()
+	requireAPIKey(handler)(rec, req)
+	return rec
+}
+
+func TestCaptureAuthorization(t *testing.T) {
+	var captures []paypal.CaptureRequest
+	stubPayPal(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/payments/authorizations/AUTH-1/capture" {
+			http.Error(w, "unexpected "+r.URL.Path, http.StatusNotFound)
+			return
+		}
+		var req paypal.CaptureRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		captures = append(captures, req)
+		fmt.Fprintf(w, `{"id":"CAP-%d","status":"COMPLETED"}`, len(captures))
+	})
+	payments.create(Payment{OrderID: "ORDER-1", PayPalOrderID: "PAYPAL-1", Intent: paypal.IntentAuthorize, Status: PaymentAuthorized,
+		Currency: "EUR", Amount: 2000, AuthorizationID: "AUTH-1", AuthorizedAt: time.Now()})
+
+	if rec := authorizationRequest(t, handleCaptureAuthorization, `{"order_id":"ORDER-1","amount":"20.01"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("capture above authorized amount: status %d", rec.Code)
+	}
+
+	rec := authorizationRequest(t, handleCaptureAuthorization, `{"order_id":"ORDER-1","amount":"8.50"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("partial capture: status %d: %s", rec.Code, rec.Body)
+	}
+	p, _ := payments.find("ORDER-1")
+	if p.Status != PaymentPartiallyCaptured || p.Captured != 850 || p.CaptureID != "CAP-1" {
+		t.Fatalf("after partial capture: %+v", p)
+	}
+
+	rec = authorizationRequest(t, handleCaptureAuthorization, `{"order_id":"ORDER-1"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("final capture: status %d: %s", rec.Code, rec.Body)
+	}
+	p, _ = payments.find("ORDER-1")
+	if p.Status != PaymentCompleted || p.Captured != 2000 || p.CaptureID != "CAP-2" {
+		t.Fatalf("after final capture: %+v", p)
+	}
+
+	if len(captures) != 2 || captures[0].FinalCapture || captures[0].Amount.Value != "8.50" ||
+		!captures[1].FinalCapture || captures[1].Amount.Value != "11.50" {
+		t.Errorf("capture requests = %+v", captures)
+	}
+
+	if rec := authorizationRequest(t, handleCaptureAuthorization, `{"order_id":"ORDER-1"}`); rec.Code != http.StatusConflict {
+		t.Errorf("capture of completed payment: status %d", rec.Code)
+	}
+}
+
+func TestVoidAuthorization(t *testing.T) {
+	var voided []string
+	stubPayPal(t, func(w http.ResponseWriter, r *http.Request) {
+		voided = append(voided, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	payments.create(Payment{OrderID: "ORDER-1", PayPalOrderID: "PAYPAL-1", Intent: paypal.IntentAuthorize, Status: PaymentAuthorized,
+		Currency: "EUR", Amount: 2000, AuthorizationID: "AUTH-1", AuthorizedAt: time.Now()})
+	payments.create(Payment{OrderID: "ORDER-2", PayPalOrderID: "PAYPAL-2", Intent: paypal.IntentAuthorize, Status: PaymentPartiallyCaptured,
+		Currency: "EUR", Amount: 2000, Captured: 500, AuthorizationID: "AUTH-2", AuthorizedAt: time.Now()})
+
+	for _, tt := range []struct{ orderID, want string }{
+		{"ORDER-1", PaymentVoided},
+		{"ORDER-2", PaymentCompleted},
+	} {
+		rec := authorizationRequest(t, handleVoidAuthorization, `{"order_id":"`+tt.orderID+`"}`)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("void %s: status %d: %s", tt.orderID, rec.Code, rec.Body)
+		}
+		if p, _ := payments.find(tt.orderID); p.Status != tt.want {
+			t.Errorf("void %s: status %s, want %s", tt.orderID, p.Status, tt.want)
+		}
+	}
+
+	if len(voided) != 2 || voided[0] != "/v2/payments/authorizations/AUTH-1/void" {
+		t.Errorf("void requests = %v", voided)
+	}
+	if rec := authorizationRequest(t, handleVoidAuthorization, `{"order_id":"ORDER-1"}`); rec.Code != http.StatusConflict {
+		t.Errorf("void of voided payment: status %d", rec.Code)
+	}
+}
+
+func TestReauthorize(t *testing.T) {
+	var reauthorized int
+	stubPayPal(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/payments/authorizations/AUTH-1/reauthorize":
+			reauthorized++
+			var body map[string]paypal.Money
+			json.NewDecoder(r.Body).Decode(&body)
+			if body["amount"].Value != "15.00" {
+				http.Error(w, "unexpected amount "+body["amount"].Value, http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"id":"AUTH-2","status":"CREATED"}`)
+		case "/v2/payments/authorizations/AUTH-2":
+			fmt.Fprint(w, `{"id":"AUTH-2","status":"CREATED","expiration_time":"2026-11-17T10:00:00Z"}`)
+		default:
+			http.Error(w, "unexpected "+r.URL.Path, http.StatusNotFound)
+		}
+	})
+	authorizedAt := time.Now().Add(-4 * 24 * time.Hour)
+	payments.create(Payment{OrderID: "ORDER-1", PayPalOrderID: "PAYPAL-1", Intent: paypal.IntentAuthorize, Status: PaymentPartiallyCaptured,
+		Currency: "EUR", Amount: 2000, Captured: 500, AuthorizationID: "AUTH-1", AuthorizedAt: authorizedAt})
+	payments.create(Payment{OrderID: "ORDER-2", PayPalOrderID: "PAYPAL-2", Intent: paypal.IntentAuthorize, Status: PaymentAuthorized,
+		Currency: "EUR", Amount: 2000, AuthorizationID: "AUTH-3", AuthorizedAt: time.Now()})
+	payments.create(Payment{OrderID: "ORDER-3", PayPalOrderID: "PAYPAL-3", Intent: paypal.IntentAuthorize, Status: PaymentAuthorized,
+		Currency: "EUR", Amount: 2000, AuthorizationID: "AUTH-4", AuthorizedAt: time.Now().Add(-30 * 24 * time.Hour)})
+
+	if rec := authorizationRequest(t, handleReauthorize, `{"order_id":"ORDER-2"}`); rec.Code != http.StatusConflict {
+		t.Errorf("reauthorize within honor period: status %d", rec.Code)
+	}
+	if rec := authorizationRequest(t, handleReauthorize, `{"order_id":"ORDER-3"}`); rec.Code == http.StatusOK {
+		t.Error("reauthorize of expired authorization succeeded")
+	}
+
+	rec := authorizationRequest(t, handleReauthorize, `{"order_id":"ORDER-1"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("reauthorize: status %d: %s", rec.Code, rec.Body)
+	}
+	p, _ := payments.find("ORDER-1")
+	if p.AuthorizationID != "AUTH-2" || !p.Reauthorized || !p.AuthorizedAt.Equal(authorizedAt) {
+		t.Errorf("after reauthorize: %+v", p)
+	}
+
+	// Provizyon yalnızca bir kez yenilenir
+	if rec := authorizationRequest(t, handleReauthorize, `{"order_id":"ORDER-1"}`); rec.Code != http.StatusOK || reauthorized != 1 {
+		t.Errorf("second reauthorize: status %d, reauthorized %d times", rec.Code, reauthorized)
+	}
+}
+
+func TestCaptureAuthorizationIdempotent(t *testing.T) {
+	var requestIDs []string
+	stubPayPal(t, func(w http.ResponseWriter, r *http.Request) {
+		requestIDs = append(requestIDs, r.Header.Get("PayPal-Request-Id"))
+		if len(requestIDs) == 1 {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			fmt.Fprint(w, `{"name":"UNPROCESSABLE_ENTITY","details":[{"issue":"INSTRUMENT_DECLINED"}]}`)
+			return
+		}
+		// Webhook handler'ın yanıtından önce gelir
+		processWebhookEvent(captureEvent(t, "CAP-1", "4.00"))
+		fmt.Fprint(w, `{"id":"CAP-1","status":"COMPLETED"}`)
+	})
+	payments.create(Payment{OrderID: "ORDER-1", PayPalOrderID: "PAYPAL-1", Intent: paypal.IntentAuthorize, Status: PaymentAuthorized,
+		Currency: "EUR", Amount: 2000, AuthorizationID: "AUTH-1", AuthorizedAt: time.Now()})
+
+	if rec := authorizationRequest(t, handleCaptureAuthorization, `{"order_id":"ORDER-1","amount":"4.00"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("declined capture: status %d", rec.Code)
+	}
+	if rec := authorizationRequest(t, handleCaptureAuthorization, `{"order_id":"ORDER-1","amount":"4.00"}`); rec.Code != http.StatusOK {
+		t.Fatalf("capture: status %d: %s", rec.Code, rec.Body)
+	}
+	// Reddedilen tahsilattan sonraki deneme yeni bir ID ile gönderilir
+	if len(requestIDs) != 2 || requestIDs[0] != "AUTH-1-capture-0-400-0" || requestIDs[1] != "AUTH-1-capture-0-400-1" {
+		t.Errorf("request IDs = %v", requestIDs)
+	}
+
+	// Aynı capture'ın tekrar gelen webhook'u da tutarı değiştirmez
+	if err := processWebhookEvent(captureEvent(t, "CAP-1", "4.00")); err != nil {
+		t.Fatal(err)
+	}
+	p, _ := payments.find("ORDER-1")
+	if p.Captured != 400 || p.Status != PaymentPartiallyCaptured || len(p.CaptureIDs) != 1 {
+		t.Errorf("after capture and webhooks: %+v", p)
+	}
+}
+
+// captureEvent PAYPAL-1 siparişine ait PAYMENT.CAPTURE.COMPLETED olayı oluşturur
+func captureEvent(t *testing.T, captureID, value string) paypal.WebhookEvent {
+	t.Helper()
+	raw, err := json.Marshal(map[string]interface{}{
+		"id":                 captureID,
+		"status":             "COMPLETED",
+		"amount":             paypal.Money{CurrencyCode: "EUR", Value: value},
+		"supplementary_data": map[string]interface{}{"related_ids": map[string]string{"order_id": "PAYPAL-1"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return paypal.WebhookEvent{ID: "WH-" + captureID, EventType: "PAYMENT.CAPTURE.COMPLETED", Resource: raw}
+}
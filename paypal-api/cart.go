@@ -0,0 +1,206 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"paypal/paypal"
+)
+
+// maxItemQuantity bir kalem için kabul edilen en yüksek adet
+const maxItemQuantity = 1000
+
+var errAmountOverflow = errors.New("cart total is too large")
+
+// CartItem sepetteki bir ürün. UnitPrice ve Tax birim başına, Shipping satır başına tutardır.
+type CartItem struct {
+	Name        string `json:"name"`
+	SKU         string `json:"sku,omitempty"`
+	Description string `json:"description,omitempty"`
+	Quantity    int    `json:"quantity"`
+	UnitPrice   string `json:"unit_price"`
+	Tax         string `json:"tax,omitempty"`
+	Shipping    string `json:"shipping,omitempty"`
+}
+
+// Cart /pay endpoint'ine gönderilen sepet
+type Cart struct {
+	OrderID     string     `json:"order_id"`
+	Currency    string     `json:"currency"`
+	Description string     `json:"description,omitempty"`
+	Items       []CartItem `json:"items"`
+	// Intent "authorize" ise tutar sadece bloke edilir, restoran onayından sonra tahsil edilir
+	Intent string `json:"intent,omitempty"`
+	// Total gönderilirse hesaplanan toplam ile karşılaştırılır
+	Total string `json:"total,omitempty"`
+}
+
+// cartStore order ID'ye göre sepetleri bellekte tutar
+type cartStore struct {
+	mu    sync.RWMutex
+	carts map[string]Cart
+}
+
+var carts = &cartStore{carts: make(map[string]Cart)}
+
+// save sepeti order ID ile kaydeder
+func (s *cartStore) save(cart Cart) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.carts[cart.OrderID] = cart
+}
+
+// find order ID'ye ait sepeti döner
+func (s *cartStore) find(orderID string) (Cart, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	cart, ok := s.carts[orderID]
+	return cart, ok
+}
+
+// orderIntent sepetin PayPal order intent değerini döner
+func (c Cart) orderIntent() (string, error) {
+	switch strings.ToLower(c.Intent) {
+	case "", "capture":
+		return paypal.IntentCapture, nil
+	case "authorize":
+		return paypal.IntentAuthorize, nil
+	}
+	return "", fmt.Errorf("unknown intent %q", c.Intent)
+}
+
+// optionalMinorUnits boş değerleri sıfır kabul ederek tutarı çevirir
+func optionalMinorUnits(value, currency string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return paypal.ParseMinorUnits(value, currency)
+}
+
+// purchaseUnit sepetten kalemleri ve tutar dağılımı olan bir PayPal purchase unit oluşturur
+func (c Cart) purchaseUnit() (paypal.PurchaseUnitRequest, error) {
+	currency, err := paypal.NormalizeCurrency(c.Currency)
+	if err != nil {
+		return paypal.PurchaseUnitRequest{}, err
+	}
+	if c.OrderID == "" {
+		return paypal.PurchaseUnitRequest{}, fmt.Errorf("order_id is required")
+	}
+	if len(c.Items) == 0 {
+		return paypal.PurchaseUnitRequest{}, fmt.Errorf("cart has no items")
+	}
+
+	var itemTotal, taxTotal, shipping int64
+	items := make([]paypal.Item, 0, len(c.Items))
+	for i, ci := range c.Items {
+		if ci.Name == "" {
+			return paypal.PurchaseUnitRequest{}, fmt.Errorf("item %d: name is required", i)
+		}
+		if ci.Quantity <= 0 {
+			return paypal.PurchaseUnitRequest{}, fmt.Errorf("item %d: quantity must be positive", i)
+		}
+		if ci.Quantity > maxItemQuantity {
+			return paypal.PurchaseUnitRequest{}, fmt.Errorf("item %d: quantity must be at most %d", i, maxItemQuantity)
+		}
+
+		unitPrice, err := paypal.ParseMinorUnits(ci.UnitPrice, currency)
+		if err != nil {
+			return paypal.PurchaseUnitRequest{}, fmt.Errorf("item %d: unit_price: %w", i, err)
+		}
+		tax, err := optionalMinorUnits(ci.Tax, currency)
+		if err != nil {
+			return paypal.PurchaseUnitRequest{}, fmt.Errorf("item %d: tax: %w", i, err)
+		}
+		itemShipping, err := optionalMinorUnits(ci.Shipping, currency)
+		if err != nil {
+			return paypal.PurchaseUnitRequest{}, fmt.Errorf("item %d: shipping: %w", i, err)
+		}
+
+		qty := int64(ci.Quantity)
+		if itemTotal, err = addMinorUnits(itemTotal, unitPrice, qty); err != nil {
+			return paypal.PurchaseUnitRequest{}, fmt.Errorf("item %d: %w", i, err)
+		}
+		if taxTotal, err = addMinorUnits(taxTotal, tax, qty); err != nil {
+			return paypal.PurchaseUnitRequest{}, fmt.Errorf("item %d: %w", i, err)
+		}
+		if shipping, err = addMinorUnits(shipping, itemShipping, 1); err != nil {
+			return paypal.PurchaseUnitRequest{}, fmt.Errorf("item %d: %w", i, err)
+		}
+
+		item := paypal.Item{
+			Name:        ci.Name,
+			Quantity:    strconv.Itoa(ci.Quantity),
+			UnitAmount:  paypal.NewMoney(unitPrice, currency),
+			SKU:         ci.SKU,
+			Description: ci.Description,
+			Category:    "PHYSICAL_GOODS",
+		}
+		if tax > 0 {
+			taxMoney := paypal.NewMoney(tax, currency)
+			item.Tax = &taxMoney
+		}
+		items = append(items, item)
+	}
+
+	total, err := addMinorUnits(itemTotal, taxTotal, 1)
+	if err == nil {
+		total, err = addMinorUnits(total, shipping, 1)
+	}
+	if err != nil {
+		return paypal.PurchaseUnitRequest{}, err
+	}
+	if total <= 0 {
+		return paypal.PurchaseUnitRequest{}, fmt.Errorf("cart total must be greater than zero")
+	}
+	if c.Total != "" {
+		expected, err := paypal.ParseMinorUnits(c.Total, currency)
+		if err != nil {
+			return paypal.PurchaseUnitRequest{}, fmt.Errorf("total: %w", err)
+		}
+		if expected != total {
+			return paypal.PurchaseUnitRequest{}, fmt.Errorf("total %s does not match calculated total %s",
+				paypal.FormatMinorUnits(expected, currency), paypal.FormatMinorUnits(total, currency))
+		}
+	}
+
+	itemTotalMoney := paypal.NewMoney(itemTotal, currency)
+	taxTotalMoney := paypal.NewMoney(taxTotal, currency)
+	shippingMoney := paypal.NewMoney(shipping, currency)
+
+	unit := paypal.PurchaseUnitRequest{
+		ReferenceID: c.OrderID,
+		Description: c.Description,
+		InvoiceID:   c.OrderID,
+		Amount: paypal.AmountWithBreakdown{
+			CurrencyCode: currency,
+			Value:        paypal.FormatMinorUnits(total, currency),
+			Breakdown: &paypal.AmountBreakdown{
+				ItemTotal: &itemTotalMoney,
+				TaxTotal:  &taxTotalMoney,
+				Shipping:  &shippingMoney,
+			},
+		},
+		Items: items,
+	}
+	if err := unit.VerifyBreakdown(); err != nil {
+		return paypal.PurchaseUnitRequest{}, err
+	}
+	return unit, nil
+}
+
+// addMinorUnits sum + amount*qty hesaplar, int64 taşarsa errAmountOverflow döner.
+// amount ve qty negatif olamaz, ParseMinorUnits ve adet kontrolü bunu garanti eder.
+func addMinorUnits(sum, amount, qty int64) (int64, error) {
+	if qty > 0 && amount > math.MaxInt64/qty {
+		return 0, errAmountOverflow
+	}
+	product := amount * qty
+	if sum > math.MaxInt64-product {
+		return 0, errAmountOverflow
+	}
+	return sum + product, nil
+}
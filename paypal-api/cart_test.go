@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCartPurchaseUnit(t *testing.T) {
+	cart := Cart{
+		OrderID:  "order-1",
+		Currency: "eur",
+		Items: []CartItem{
+			{Name: "Pizza", Quantity: 3, UnitPrice: "12.50", Tax: "1.00", Shipping: "2.00"},
+			{Name: "Ayran", Quantity: 2, UnitPrice: "0.10"},
+		},
+	}
+	unit, err := cart.purchaseUnit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 0.1 + 0.2 gibi toplamlar float'ta kayar, kuruş hesabında tam çıkar
+	b := unit.Amount.Breakdown
+	if unit.Amount.Value != "42.70" || b.ItemTotal.Value != "37.70" || b.TaxTotal.Value != "3.00" || b.Shipping.Value != "2.00" {
+		t.Errorf("amount = %s, breakdown = %s + %s + %s", unit.Amount.Value, b.ItemTotal.Value, b.TaxTotal.Value, b.Shipping.Value)
+	}
+	if unit.Amount.CurrencyCode != "EUR" || unit.InvoiceID != "order-1" || len(unit.Items) != 2 || unit.Items[0].Quantity != "3" {
+		t.Errorf("purchase unit = %+v", unit)
+	}
+	if unit.Items[0].Tax == nil || unit.Items[0].Tax.Value != "1.00" || unit.Items[1].Tax != nil {
+		t.Errorf("item taxes = %+v, %+v", unit.Items[0].Tax, unit.Items[1].Tax)
+	}
+
+	cart.Total = "42.70"
+	if _, err := cart.purchaseUnit(); err != nil {
+		t.Errorf("matching total rejected: %v", err)
+	}
+	cart.Total = "42.00"
+	if _, err := cart.purchaseUnit(); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("total mismatch error = %v", err)
+	}
+}
+
+func TestCartPurchaseUnitValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		cart Cart
+	}{
+		{"missing order id", Cart{Currency: "EUR", Items: []CartItem{{Name: "A", Quantity: 1, UnitPrice: "1.00"}}}},
+		{"unsupported currency", Cart{OrderID: "o", Currency: "TRY", Items: []CartItem{{Name: "A", Quantity: 1, UnitPrice: "1.00"}}}},
+		{"no items", Cart{OrderID: "o", Currency: "EUR"}},
+		{"missing name", Cart{OrderID: "o", Currency: "EUR", Items: []CartItem{{Quantity: 1, UnitPrice: "1.00"}}}},
+		{"zero quantity", Cart{OrderID: "o", Currency: "EUR", Items: []CartItem{{Name: "A", UnitPrice: "1.00"}}}},
+		{"too many decimals", Cart{OrderID: "o", Currency: "EUR", Items: []CartItem{{Name: "A", Quantity: 1, UnitPrice: "1.005"}}}},
+		{"zero total", Cart{OrderID: "o", Currency: "EUR", Items: []CartItem{{Name: "A", Quantity: 1, UnitPrice: "0.00"}}}},
+	}
+	for _, tt := range tests {
+		if _, err := tt.cart.purchaseUnit(); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestCartPurchaseUnitLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		items   []CartItem
+		wantErr string
+	}{
+		{"quantity above limit", []CartItem{{Name: "Pizza", Quantity: maxItemQuantity + 1, UnitPrice: "1.00"}}, "quantity must be at most"},
+		{"line total overflows", []CartItem{{Name: "Pizza", Quantity: 1000, UnitPrice: "92233720368547758.07"}}, errAmountOverflow.Error()},
+		{"items sum overflows", []CartItem{
+			{Name: "A", Quantity: 1, UnitPrice: "92233720368547758.07"},
+			{Name: "B", Quantity: 1, UnitPrice: "0.01"},
+		}, errAmountOverflow.Error()},
+		{"tax overflows total", []CartItem{{Name: "A", Quantity: 1, UnitPrice: "92233720368547758.00", Tax: "1.00"}}, errAmountOverflow.Error()},
+		{"valid", []CartItem{{Name: "Pizza", Quantity: 3, UnitPrice: "12.50", Tax: "1.00", Shipping: "2.00"}}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cart := Cart{OrderID: "order-1", Currency: "EUR", Items: tt.items}
+			unit, err := cart.purchaseUnit()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if unit.Amount.Value != "42.50" {
+					t.Errorf("total = %s, want 42.50", unit.Amount.Value)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAddMinorUnits(t *testing.T) {
+	if got, err := addMinorUnits(100, 250, 3); err != nil || got != 850 {
+		t.Errorf("addMinorUnits(100, 250, 3) = %d, %v", got, err)
+	}
+	if _, err := addMinorUnits(0, 1<<62, 2); !errors.Is(err, errAmountOverflow) {
+		t.Errorf("multiplication overflow not detected: %v", err)
+	}
+	if _, err := addMinorUnits(1<<62, 1<<62, 1); !errors.Is(err, errAmountOverflow) {
+		t.Errorf("addition overflow not detected: %v", err)
+	}
+}
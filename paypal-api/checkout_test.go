@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"paypal/paypal"
+	"paypal/paypal/paypaltest"
+)
+
+// newCheckoutTest handler'ları sahte PayPal'a bağlı bir test sunucusunda çalıştırır
+func newCheckoutTest(t *testing.T) (*httptest.Server, *paypaltest.Server) {
+	t.Helper()
+	fake := paypaltest.NewServer("", "")
+	t.Cleanup(fake.Close)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/pay", handlePay)
+	mux.HandleFunc("/success", handleSuccess)
+	mux.HandleFunc("/cancel", handleCancel)
+	mux.HandleFunc("/refunds", requireAPIKey(handleRefund))
+	app := httptest.NewServer(mux)
+	t.Cleanup(app.Close)
+
+	oldClient, oldKey, oldPayments := client, adminAPIKey, payments
+	oldReturn, oldCancel := returnURL, cancelURL
+	t.Cleanup(func() {
+		client, adminAPIKey, payments = oldClient, oldKey, oldPayments
+		returnURL, cancelURL = oldReturn, oldCancel
+	})
+	client = paypal.NewClient("", "", fake.URL)
+	adminAPIKey = "test-key"
+	payments = openPaymentStore("")
+	returnURL, cancelURL = app.URL+"/success", app.URL+"/cancel"
+	return app, fake
+}
+
+// noRedirect yönlendirmeleri takip etmeyen istemci, Location başlığı test tarafından izlenir
+var noRedirect = &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+	return http.ErrUseLastResponse
+}}
+
+func TestCheckoutCaptureAndRefund(t *testing.T) {
+	app, _ := newCheckoutTest(t)
+
+	cart := `{"order_id": "order-e2e", "currency": "EUR", "items": [
+		{"name": "Pizza", "quantity": 2, "unit_price": "12.50"},
+		{"name": "Ayran", "quantity": 1, "unit_price": "3.00"}]}`
+	resp, err := http.Post(app.URL+"/pay", "application/json", strings.NewReader(cart))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var created map[string]string
+	json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || created["approval_url"] == "" {
+		t.Fatalf("POST /pay = %d %v", resp.StatusCode, created)
+	}
+
+	// Alıcı PayPal'da onaylar, PayPal dönüş adresine yönlendirir
+	resp, err = noRedirect.Get(created["approval_url"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	location := resp.Header.Get("Location")
+	if resp.StatusCode != http.StatusFound || !strings.HasPrefix(location, app.URL+"/success?") {
+		t.Fatalf("approval = %d, Location %q", resp.StatusCode, location)
+	}
+
+	resp, err = http.Get(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /success = %d", resp.StatusCode)
+	}
+	payment, ok := payments.find("order-e2e")
+	if !ok || payment.Status != PaymentCompleted || payment.Captured != 2800 || payment.CaptureID == "" {
+		t.Fatalf("payment after capture = %+v", payment)
+	}
+
+	// Aynı dönüş linki ikinci kez kullanılamaz
+	resp, err = http.Get(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("replayed /success = %d, want %d", resp.StatusCode, http.StatusConflict)
+	}
+
+	refund := func(body string, key string) (int, map[string]string) {
+		req, _ := http.NewRequest(http.MethodPost, app.URL+"/refunds", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("API-Key", key)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var result map[string]string
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+
+	if status, _ := refund(`{"capture_id": "`+payment.CaptureID+`"}`, "wrong-key"); status != http.StatusUnauthorized {
+		t.Errorf("refund with a wrong API key = %d, want %d", status, http.StatusUnauthorized)
+	}
+	status, result := refund(`{"capture_id": "`+payment.CaptureID+`", "amount": "5.00", "reason": "Missing item"}`, "test-key")
+	if status != http.StatusOK || result["refunded_total"] != "5.00" || result["payment_status"] != PaymentPartiallyRefunded {
+		t.Fatalf("partial refund = %d %v", status, result)
+	}
+	if status, _ := refund(`{"capture_id": "`+payment.CaptureID+`", "amount": "30.00"}`, "test-key"); status != http.StatusBadRequest {
+		t.Errorf("refund over the captured amount = %d, want %d", status, http.StatusBadRequest)
+	}
+	status, result = refund(`{"capture_id": "`+payment.CaptureID+`"}`, "test-key")
+	if status != http.StatusOK || result["amount"] != "23.00" || result["payment_status"] != PaymentRefunded {
+		t.Fatalf("full refund = %d %v", status, result)
+	}
+}
+
+func TestCheckoutCancel(t *testing.T) {
+	app, _ := newCheckoutTest(t)
+
+	cart := `{"order_id": "order-cancel", "currency": "EUR", "items": [{"name": "Pizza", "quantity": 1, "unit_price": "12.50"}]}`
+	resp, err := http.Post(app.URL+"/pay", "application/json", strings.NewReader(cart))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var created map[string]string
+	json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+
+	resp, err = noRedirect.Get(created["approval_url"] + "&cancel=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	location := resp.Header.Get("Location")
+	if !strings.HasPrefix(location, app.URL+"/cancel?") {
+		t.Fatalf("cancel redirect Location = %q", location)
+	}
+
+	resp, err = http.Get(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /cancel = %d", resp.StatusCode)
+	}
+	if p, _ := payments.find("order-cancel"); p.Status != PaymentAbandoned {
+		t.Errorf("payment status = %q, want %q", p.Status, PaymentAbandoned)
+	}
+}
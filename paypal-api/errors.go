@@ -0,0 +1,28 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"paypal/paypal"
+)
+
+// writePayPalError hatayı loglar ve istemciye uygun HTTP durumu ile döner.
+// PayPal'ın iş kuralı hataları (422) olduğu gibi iletilir, diğerleri 502 olarak döner.
+func writePayPalError(w http.ResponseWriter, message string, err error) {
+	log.Printf("%s: %v", message, err)
+
+	var apiErr *paypal.APIError
+	if !errors.As(err, &apiErr) {
+		http.Error(w, message, http.StatusInternalServerError)
+		return
+	}
+
+	if apiErr.StatusCode == http.StatusUnprocessableEntity {
+		http.Error(w, fmt.Sprintf("%s: %s (debug_id %s)", message, apiErr.Name, apiErr.DebugID), http.StatusUnprocessableEntity)
+		return
+	}
+	http.Error(w, fmt.Sprintf("%s (debug_id %s)", message, apiErr.DebugID), http.StatusBadGateway)
+}
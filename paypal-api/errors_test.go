@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"paypal/paypal"
+)
+
+func TestWritePayPalError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code int
+		body string
+	}{
+		{"business rule", &paypal.APIError{StatusCode: 422, Name: "UNPROCESSABLE_ENTITY", DebugID: "d1"}, http.StatusUnprocessableEntity, "UNPROCESSABLE_ENTITY (debug_id d1)"},
+		{"server error", &paypal.APIError{StatusCode: 500, Name: "INTERNAL_SERVER_ERROR", DebugID: "d2"}, http.StatusBadGateway, "(debug_id d2)"},
+		{"transport error", errors.New("connection refused"), http.StatusInternalServerError, "Failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			writePayPalError(rec, "Failed", tt.err)
+			if rec.Code != tt.code {
+				t.Errorf("status = %d, want %d", rec.Code, tt.code)
+			}
+			if !strings.Contains(rec.Body.String(), tt.body) {
+				t.Errorf("body = %q, want it to contain %q", rec.Body, tt.body)
+			}
+		})
+	}
+}
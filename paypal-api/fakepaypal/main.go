@@ -0,0 +1,42 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"paypal/paypal/paypaltest"
+)
+
+/*
+	Sahte PayPal sunucusunu ayrı bir süreç olarak çalıştırır:
+	FAKE_PAYPAL_ADDR=:8081 go run ./fakepaypal
+
+	paypal-api:   PAYPAL_BASE_URL=http://localhost:8081 go run .
+	paypal-login: PAYPAL_API_BASE=http://localhost:8081 PAYPAL_WEB_BASE=http://localhost:8081 go run .
+
+	Onay sayfası (/checkoutnow) ve giriş sayfası (/signin/authorize) kullanıcıyı hemen geri yönlendirir.
+	FAKE_PAYPAL_CLIENT_ID ve FAKE_PAYPAL_SECRET boş ise her client kabul edilir.
+	FAKE_PAYPAL_TOKEN_TTL (örn. 30s) token yenileme akışlarını denemek için access token süresini kısaltır.
+	FAKE_PAYPAL_ISSUER id_token'ların iss alanıdır, boşsa isteğin geldiği adres kullanılır.
+*/
+
+func main() {
+	addr := os.Getenv("FAKE_PAYPAL_ADDR")
+	if addr == "" {
+		addr = ":8081"
+	}
+
+	fake := paypaltest.New(os.Getenv("FAKE_PAYPAL_CLIENT_ID"), os.Getenv("FAKE_PAYPAL_SECRET"))
+	if ttl := os.Getenv("FAKE_PAYPAL_TOKEN_TTL"); ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil {
+			log.Fatalf("FAKE_PAYPAL_TOKEN_TTL: %v", err)
+		}
+		fake.TokenTTL = d
+	}
+	fake.Issuer = os.Getenv("FAKE_PAYPAL_ISSUER")
+	log.Println("Fake PayPal starting at", addr)
+	log.Fatal(http.ListenAndServe(addr, fake))
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"paypal/paypal"
+)
+
+// maxSettlementReport yüklenen STL dosyası için üst sınır
+const maxSettlementReport = 32 << 20
+
+// Uyuşmazlık türleri
+const (
+	DiscrepancyUnknownTransaction = "UNKNOWN_TRANSACTION"
+	DiscrepancyMissingInPayPal    = "MISSING_IN_PAYPAL"
+	DiscrepancyCapturedMismatch   = "CAPTURED_MISMATCH"
+	DiscrepancyRefundedMismatch   = "REFUNDED_MISMATCH"
+	DiscrepancyCurrencyMismatch   = "CURRENCY_MISMATCH"
+	DiscrepancyStatusMismatch     = "STATUS_MISMATCH"
+	DiscrepancyInvalidEntry       = "INVALID_ENTRY"
+)
+
+// LedgerEntry Transaction Search ve STL raporundan gelen işlemlerin ortak hali.
+// Tutarlar en küçük birimdedir, PayPal hesabından çıkan tutarlar eksi işaretlidir.
+type LedgerEntry struct {
+	TransactionID string    `json:"transaction_id"`
+	ReferenceID   string    `json:"reference_id,omitempty"`
+	EventCode     string    `json:"event_code"`
+	InvoiceID     string    `json:"invoice_id,omitempty"`
+	Status        string    `json:"status"`
+	Currency      string    `json:"currency"`
+	Gross         int64     `json:"gross"`
+	Fee           int64     `json:"fee"`
+	Date          time.Time `json:"date"`
+}
+
+// isPayment T00xx kodlu gelen ödeme. Giden T00xx kayıtları (payouts) siparişlerle eşleşmez.
+func (e LedgerEntry) isPayment() bool {
+	return strings.HasPrefix(e.EventCode, "T00") && e.Gross > 0
+}
+
+// isRefund T11xx kodlu iade, ters kayıt veya chargeback
+func (e LedgerEntry) isRefund() bool {
+	return strings.HasPrefix(e.EventCode, "T11") && e.Gross < 0
+}
+
+// ledgerFromTransaction Transaction Search kaydını LedgerEntry'ye çevirir
+func ledgerFromTransaction(detail paypal.TransactionDetail) (LedgerEntry, error) {
+	info := detail.TransactionInfo
+	entry := LedgerEntry{
+		TransactionID: info.TransactionID,
+		ReferenceID:   info.PayPalReferenceID,
+		EventCode:     info.TransactionEventCode,
+		InvoiceID:     info.InvoiceID,
+		Status:        info.TransactionStatus,
+	}
+	entry.Date, _ = time.Parse("2006-01-02T15:04:05-0700", info.TransactionInitiationDate)
+
+	if info.TransactionAmount == nil {
+		return entry, fmt.Errorf("transaction %s has no amount", info.TransactionID)
+	}
+	currency, err := paypal.NormalizeCurrency(info.TransactionAmount.CurrencyCode)
+	if err != nil {
+		return entry, err
+	}
+	entry.Currency = currency
+	if entry.Gross, err = paypal.ParseSignedMinorUnits(info.TransactionAmount.Value, currency); err != nil {
+		return entry, err
+	}
+	if info.FeeAmount != nil {
+		if entry.Fee, err = paypal.ParseSignedMinorUnits(info.FeeAmount.Value, currency); err != nil {
+			return entry, err
+		}
+	}
+	return entry, nil
+}
+
+// ledgerFromSettlement STL satırını LedgerEntry'ye çevirir. Rapor yalnızca tamamlanmış
+// işlemleri içerdiği için durum S (success) kabul edilir.
+func ledgerFromSettlement(s paypal.SettlementEntry) LedgerEntry {
+	return LedgerEntry{
+		TransactionID: s.TransactionID,
+		ReferenceID:   s.ReferenceID,
+		EventCode:     s.EventCode,
+		InvoiceID:     s.InvoiceID,
+		Status:        "S",
+		Currency:      strings.ToUpper(s.Currency),
+		Gross:         s.Gross,
+		Fee:           s.Fee,
+		Date:          s.InitiatedAt,
+	}
+}
+
+// Discrepancy PayPal kayıtları ile yerel ödemeler arasındaki bir fark
+type Discrepancy struct {
+	Kind          string `json:"kind"`
+	OrderID       string `json:"order_id,omitempty"`
+	TransactionID string `json:"transaction_id,omitempty"`
+	Expected      string `json:"expected,omitempty"`
+	Actual        string `json:"actual,omitempty"`
+	Message       string `json:"message"`
+}
+
+// ReconciliationReport karşılaştırma sonucu. Fees para birimine göre toplam PayPal kesintisidir.
+type ReconciliationReport struct {
+	Source        string            `json:"source"`
+	Start         time.Time         `json:"start"`
+	End           time.Time         `json:"end"`
+	Entries       int               `json:"entries"`
+	Matched       int               `json:"matched"`
+	Skipped       int               `json:"skipped"`
+	Fees          map[string]string `json:"fees"`
+	Discrepancies []Discrepancy     `json:"discrepancies"`
+}
+
+// ledgerTotals bir siparişe ait PayPal tarafındaki toplamlar
+type ledgerTotals struct {
+	currency string
+	captured int64
+	refunded int64
+}
+
+// matchLedger PayPal kayıtlarını yerel ödemelerle eşleştirir. [start, end) aralığında oluşturulup
+// tahsil edilmiş ama raporda hiç görünmeyen ödemeler de fark olarak işaretlenir.
+func matchLedger(source string, entries []LedgerEntry, start, end time.Time) ReconciliationReport {
+	report := ReconciliationReport{
+		Source:        source,
+		Start:         start,
+		End:           end,
+		Entries:       len(entries),
+		Fees:          make(map[string]string),
+		Discrepancies: []Discrepancy{},
+	}
+	flag := func(d Discrepancy) { report.Discrepancies = append(report.Discrepancies, d) }
+
+	fees := make(map[string]int64)
+	totals := make(map[string]*ledgerTotals)
+	for _, e := range entries {
+		fees[e.Currency] += e.Fee
+
+		var payment Payment
+		var ok bool
+		switch {
+		case e.isPayment():
+			if payment, ok = payments.findByCapture(e.TransactionID); !ok && e.InvoiceID != "" {
+				payment, ok = payments.find(e.InvoiceID)
+			}
+		case e.isRefund():
+			payment, ok = payments.findByCapture(e.ReferenceID)
+		default:
+			report.Skipped++
+			continue
+		}
+		if !ok {
+			flag(Discrepancy{
+				Kind:          DiscrepancyUnknownTransaction,
+				TransactionID: e.TransactionID,
+				Actual:        paypal.FormatMinorUnits(e.Gross, e.Currency) + " " + e.Currency,
+				Message:       fmt.Sprintf("%s transaction has no local payment", e.EventCode),
+			})
+			continue
+		}
+		report.Matched++
+
+		if e.Currency != payment.Currency {
+			flag(Discrepancy{
+				Kind:          DiscrepancyCurrencyMismatch,
+				OrderID:       payment.OrderID,
+				TransactionID: e.TransactionID,
+				Expected:      payment.Currency,
+				Actual:        e.Currency,
+				Message:       "transaction currency differs from the local payment",
+			})
+			continue
+		}
+		if e.Status != "S" {
+			// Bekleyen (P), reddedilen (D) veya geri alınan (V) işlemler toplama katılmaz
+			if e.isPayment() && (payment.Status == PaymentCompleted || payment.Status == PaymentPartiallyCaptured) {
+				flag(Discrepancy{
+					Kind:          DiscrepancyStatusMismatch,
+					OrderID:       payment.OrderID,
+					TransactionID: e.TransactionID,
+					Expected:      "S",
+					Actual:        e.Status,
+					Message:       fmt.Sprintf("local payment is %s", payment.Status),
+				})
+			}
+			continue
+		}
+
+		t, ok := totals[payment.OrderID]
+		if !ok {
+			t = &ledgerTotals{currency: e.Currency}
+			totals[payment.OrderID] = t
+		}
+		if e.isPayment() {
+			t.captured += e.Gross
+		} else {
+			t.refunded -= e.Gross
+		}
+	}
+	for currency, fee := range fees {
+		report.Fees[currency] = paypal.FormatMinorUnits(fee, currency)
+	}
+
+	for orderID, t := range totals {
+		payment, _ := payments.find(orderID)
+		if t.captured != payment.Captured {
+			flag(Discrepancy{
+				Kind:     DiscrepancyCapturedMismatch,
+				OrderID:  orderID,
+				Expected: paypal.FormatMinorUnits(payment.Captured, t.currency),
+				Actual:   paypal.FormatMinorUnits(t.captured, t.currency),
+				Message:  "captured amount differs from PayPal",
+			})
+		}
+		if t.refunded != payment.Refunded {
+			flag(Discrepancy{
+				Kind:     DiscrepancyRefundedMismatch,
+				OrderID:  orderID,
+				Expected: paypal.FormatMinorUnits(payment.Refunded, t.currency),
+				Actual:   paypal.FormatMinorUnits(t.refunded, t.currency),
+				Message:  "refunded amount differs from PayPal",
+			})
+		}
+	}
+
+	for _, p := range payments.list("") {
+		if p.Captured == 0 || p.CreatedAt.Before(start) || !p.CreatedAt.Before(end) {
+			continue
+		}
+		if t, ok := totals[p.OrderID]; !ok || t.captured == 0 {
+			flag(Discrepancy{
+				Kind:     DiscrepancyMissingInPayPal,
+				OrderID:  p.OrderID,
+				Expected: paypal.FormatMinorUnits(p.Captured, p.Currency) + " " + p.Currency,
+				Message:  fmt.Sprintf("local payment is %s but PayPal has no capture", p.Status),
+			})
+		}
+	}
+	return report
+}
+
+// parseReportDate 2006-01-02 veya RFC3339 biçimindeki tarihi okur
+func parseReportDate(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+/*
+	Transaction Search API'den tarih aralığındaki işlemleri çekip yerel ödemelerle karşılaştırır (API-Key gerekir):
+	GET /reports/transactions?start=2024-01-01&end=2024-02-01
+	PayPal işlemleri aramaya yaklaşık 3 saat gecikmeyle ekler.
+*/
+
+func handleTransactionReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	start, err := parseReportDate(r.URL.Query().Get("start"))
+	if err != nil {
+		http.Error(w, "Invalid start date", http.StatusBadRequest)
+		return
+	}
+	end, err := parseReportDate(r.URL.Query().Get("end"))
+	if err != nil || !end.After(start) {
+		http.Error(w, "Invalid end date", http.StatusBadRequest)
+		return
+	}
+
+	details, err := client.SearchTransactions(start, end)
+	if err != nil {
+		writePayPalError(w, "Failed to search transactions", err)
+		return
+	}
+
+	entries := make([]LedgerEntry, 0, len(details))
+	var invalid []Discrepancy
+	for _, detail := range details {
+		entry, err := ledgerFromTransaction(detail)
+		if err != nil {
+			invalid = append(invalid, Discrepancy{
+				Kind:          DiscrepancyInvalidEntry,
+				TransactionID: detail.TransactionInfo.TransactionID,
+				Message:       err.Error(),
+			})
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	report := matchLedger("transaction_search", entries, start, end)
+	report.Entries = len(details)
+	report.Discrepancies = append(report.Discrepancies, invalid...)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+/*
+	PayPal'dan indirilen STL settlement raporunu yükler ve yerel ödemelerle karşılaştırır (API-Key gerekir):
+	curl -X POST http://localhost:3000/reports/settlement \
+	-H "API-Key: <ADMIN_API_KEY>" -H "Content-Type: text/csv" \
+	--data-binary @STL-20240115.01.009.CSV
+*/
+
+func handleSettlementReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rows, err := paypal.ParseSettlementReport(io.LimitReader(r.Body, maxSettlementReport))
+	if err != nil {
+		http.Error(w, "Invalid settlement report: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var start, end time.Time
+	entries := make([]LedgerEntry, 0, len(rows))
+	for _, row := range rows {
+		entry := ledgerFromSettlement(row)
+		if start.IsZero() || entry.Date.Before(start) {
+			start = entry.Date
+		}
+		if entry.Date.After(end) {
+			end = entry.Date
+		}
+		entries = append(entries, entry)
+	}
+	if !end.IsZero() {
+		// Aralık son işlemi de kapsasın
+		end = end.Add(time.Second)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(matchLedger("settlement_report", entries, start, end))
+}
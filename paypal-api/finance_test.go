@@ -0,0 +1,80 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"paypal/paypal"
+)
+
+func TestLedgerFromTransaction(t *testing.T) {
+	var detail paypal.TransactionDetail
+	detail.TransactionInfo = paypal.TransactionInfo{
+		TransactionID:             "1JU08902781691411",
+		PayPalReferenceID:         "3C679366HH908993F",
+		TransactionEventCode:      "T1107",
+		TransactionInitiationDate: "2024-01-15T12:00:00+0000",
+		TransactionAmount:         &paypal.TransactionAmount{CurrencyCode: "EUR", Value: "-5.00"},
+		FeeAmount:                 &paypal.TransactionAmount{CurrencyCode: "EUR", Value: "0.15"},
+		TransactionStatus:         "S",
+	}
+	entry, err := ledgerFromTransaction(detail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Gross != -500 || entry.Fee != 15 || !entry.isRefund() || entry.isPayment() {
+		t.Errorf("entry = %+v", entry)
+	}
+	if want := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC); !entry.Date.Equal(want) {
+		t.Errorf("Date = %v, want %v", entry.Date, want)
+	}
+
+	detail.TransactionInfo.TransactionAmount = nil
+	if _, err := ledgerFromTransaction(detail); err == nil {
+		t.Error("transaction without amount was accepted")
+	}
+}
+
+func TestMatchLedger(t *testing.T) {
+	saved := payments
+	defer func() { payments = saved }()
+	payments = newTestPayments()
+	payments.create(Payment{OrderID: "ORDER-1", PayPalOrderID: "PAYPAL-1", CaptureID: "CAP-1", Status: PaymentPartiallyRefunded, Currency: "EUR", Amount: 2800, Captured: 2800, Refunded: 500})
+	payments.create(Payment{OrderID: "ORDER-2", PayPalOrderID: "PAYPAL-2", CaptureID: "CAP-2", Status: PaymentCompleted, Currency: "EUR", Amount: 1000, Captured: 1000})
+	payments.create(Payment{OrderID: "ORDER-3", PayPalOrderID: "PAYPAL-3", CaptureID: "CAP-3", Status: PaymentCompleted, Currency: "EUR", Amount: 1000, Captured: 1000})
+	payments.create(Payment{OrderID: "ORDER-4", PayPalOrderID: "PAYPAL-4", CaptureID: "CAP-4", Status: PaymentCompleted, Currency: "EUR", Amount: 1000, Captured: 1000})
+
+	entries := []LedgerEntry{
+		{TransactionID: "CAP-1", EventCode: "T0006", Status: "S", Currency: "EUR", Gross: 2800, Fee: -116},
+		{TransactionID: "REF-1", ReferenceID: "CAP-1", EventCode: "T1107", Status: "S", Currency: "EUR", Gross: -500, Fee: 15},
+		{TransactionID: "CAP-2", EventCode: "T0006", Status: "S", Currency: "EUR", Gross: 900, Fee: -40},
+		{TransactionID: "CAP-4", EventCode: "T0006", Status: "S", Currency: "USD", Gross: 1000},
+		{TransactionID: "CAP-X", EventCode: "T0006", Status: "S", Currency: "EUR", Gross: 100},
+		{TransactionID: "PAYOUT-1", EventCode: "T0000", Status: "S", Currency: "EUR", Gross: -4200},
+	}
+	report := matchLedger("test", entries, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+
+	if report.Matched != 4 || report.Skipped != 1 || report.Fees["EUR"] != "-1.41" {
+		t.Errorf("matched %d skipped %d fees %v", report.Matched, report.Skipped, report.Fees)
+	}
+
+	got := make(map[string][]string)
+	for _, d := range report.Discrepancies {
+		got[d.Kind] = append(got[d.Kind], d.OrderID+d.TransactionID)
+	}
+	for _, ids := range got {
+		sort.Strings(ids)
+	}
+	// ORDER-4'ün tek kaydı para birimi farklı olduğu için toplama girmez, PayPal'da yok sayılır
+	want := map[string][]string{
+		DiscrepancyCapturedMismatch:   {"ORDER-2"},
+		DiscrepancyCurrencyMismatch:   {"ORDER-4CAP-4"},
+		DiscrepancyUnknownTransaction: {"CAP-X"},
+		DiscrepancyMissingInPayPal:    {"ORDER-3", "ORDER-4"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("discrepancies = %v, want %v", got, want)
+	}
+}
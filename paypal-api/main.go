@@ -1,11 +1,17 @@
 package main
 
 import (
-	"bytes"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"time"
+
+	"paypal/paypal"
+	"paypal/phoneApi"
 )
 
 const (
@@ -13,241 +19,373 @@ const (
 	clientSecret = ""
 )
 
+// client tüm handler'ların paylaştığı PayPal istemcisi. PAYPAL_BASE_URL boş ise sandbox kullanılır,
+// canlı ortam için PAYPAL_BASE_URL=https://api-m.paypal.com verilir.
+var client = paypal.NewClient(clientID, clientSecret, os.Getenv("PAYPAL_BASE_URL"))
+
+// adminAPIKey iade gibi mağaza işlemlerini yetkilendiren anahtar
+var adminAPIKey = os.Getenv("ADMIN_API_KEY")
+
 func main() {
 	http.HandleFunc("/pay", handlePay)
 	http.HandleFunc("/success", handleSuccess)
 	http.HandleFunc("/cancel", handleCancel)
+	http.HandleFunc("/refunds", requireAPIKey(handleRefund))
+	http.HandleFunc("/webhooks/paypal", handleWebhook)
+
+	http.HandleFunc("/authorizations/capture", requireAPIKey(handleCaptureAuthorization))
+	http.HandleFunc("/authorizations/void", requireAPIKey(handleVoidAuthorization))
+	http.HandleFunc("/authorizations/reauthorize", requireAPIKey(handleReauthorize))
+
+	http.HandleFunc("/plans", requireAPIKey(handleCreatePlan))
+	http.HandleFunc("/subscriptions", handleSubscribe)
+	http.HandleFunc("/subscriptions/success", handleSubscriptionSuccess)
+	http.HandleFunc("/subscriptions/abandon", handleSubscriptionAbandon)
+	http.HandleFunc("/subscriptions/{id}", requireAPIKey(handleGetSubscription))
+	http.HandleFunc("/subscriptions/activate", requireAPIKey(handleSubscriptionAction("activate")))
+	http.HandleFunc("/subscriptions/suspend", requireAPIKey(handleSubscriptionAction("suspend")))
+	http.HandleFunc("/subscriptions/cancel", requireAPIKey(handleSubscriptionAction("cancel")))
+
+	http.HandleFunc("/payouts", requireAPIKey(handlePayouts))
+	http.HandleFunc("/payouts/cancel-unclaimed", requireAPIKey(handleCancelUnclaimed))
+	go pollPayouts(time.Minute)
+
+	http.HandleFunc("/payments", requireAPIKey(handlePayments))
+	http.HandleFunc("/payments/reconcile", requireAPIKey(handleReconcile))
+	go runReconciler(5 * time.Minute)
+
+	http.HandleFunc("/reports/transactions", requireAPIKey(handleTransactionReport))
+	http.HandleFunc("/reports/settlement", requireAPIKey(handleSettlementReport))
+
+	http.HandleFunc("/otp/send", otp.HandleSend)
+	http.HandleFunc("/otp/verify", otp.HandleVerify)
+	http.HandleFunc("/sms/notify", requireAPIKey(handleSMSNotify))
+	http.HandleFunc("/sms/receipts", handleSMSReceipt)
+	http.HandleFunc("/sms/messages", requireAPIKey(smsDeliveries.HandleQuery(phoneapi.DefaultOTPConfig.DefaultRegion)))
 
 	log.Println("Server starting at :3000")
 	log.Fatal(http.ListenAndServe(":3000", nil))
 }
 
+/*
+	Sepet iki şekilde gönderilebilir:
+	GET  /pay?order_id=<ORDER_ID>  -> daha önce kaydedilmiş sepet ile PayPal onay sayfasına yönlendirir
+	POST /pay (JSON Cart)          -> sepeti kaydeder ve onay linkini JSON olarak döner
+*/
+
 func handlePay(w http.ResponseWriter, r *http.Request) {
-	token, err := getAccessToken()
-	if err != nil {
-		http.Error(w, "Failed to get access token", http.StatusInternalServerError)
+	var cart Cart
+	switch r.Method {
+	case http.MethodGet:
+		orderID := r.URL.Query().Get("order_id")
+		if orderID == "" {
+			http.Error(w, "Missing order_id in request", http.StatusBadRequest)
+			return
+		}
+		var ok bool
+		if cart, ok = carts.find(orderID); !ok {
+			http.Error(w, "Order not found", http.StatusNotFound)
+			return
+		}
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&cart); err != nil {
+			http.Error(w, "Invalid cart payload: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	approvalURL, err := createPayment(token)
+	unit, err := cart.purchaseUnit()
 	if err != nil {
-		http.Error(w, "Failed to create payment", http.StatusInternalServerError)
+		http.Error(w, "Invalid cart: "+err.Error(), http.StatusBadRequest)
 		return
 	}
-
-	// Kullanıcıyı PayPal onay sayfasına yönlendirme
-	http.Redirect(w, r, approvalURL, http.StatusTemporaryRedirect)
-}
-
-func handleSuccess(w http.ResponseWriter, r *http.Request) {
-	token, err := getAccessToken()
+	intent, err := cart.orderIntent()
 	if err != nil {
-		http.Error(w, "Failed to get access token", http.StatusInternalServerError)
+		http.Error(w, "Invalid cart: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Ödemesi alınmış siparişin sepeti ve ödeme kaydı değiştirilemez
+	if err := payments.checkReplaceable(cart.OrderID); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
 		return
 	}
 
-	paymentID := r.URL.Query().Get("paymentId")
-	payerID := r.URL.Query().Get("PayerID")
-
-	saleID, err := executePayment(token, paymentID, payerID)
+	// state dönüşte PayPal order'ının bu siparişe ait olduğunu kanıtlar
+	state := newRedirectState(cart.OrderID)
+	order, err := client.CreateOrder(paypal.OrderRequest{
+		Intent:        intent,
+		PurchaseUnits: []paypal.PurchaseUnitRequest{unit},
+		ApplicationContext: &paypal.ApplicationContext{
+			UserAction: "PAY_NOW",
+			ReturnURL:  withState(returnURL, state), // Ödeme başarılı olursa
+			CancelURL:  withState(cancelURL, state), // Ödeme iptal olursa
+		},
+	})
 	if err != nil {
-		http.Error(w, "Failed to execute payment", http.StatusInternalServerError)
+		writePayPalError(w, "Failed to create payment", err)
 		return
 	}
 
-	// Sipariş oluşturma işlemi burada yapılabilir
-	// Örneğin, veritabanına kaydetme
-
-	fmt.Fprintln(w, "Payment completed successfully! Sale ID:", saleID)
-}
-
-func handleCancel(w http.ResponseWriter, r *http.Request) {
-	token, err := getAccessToken()
+	approvalURL, err := order.ApprovalURL()
 	if err != nil {
-		http.Error(w, "Failed to get access token", http.StatusInternalServerError)
+		log.Println(err)
+		http.Error(w, "Failed to create payment", http.StatusInternalServerError)
 		return
 	}
 
-	// URL'den saleID'yi al
-	saleID := r.URL.Query().Get("saleID")
-	if saleID == "" {
-		http.Error(w, "Missing saleID in request", http.StatusBadRequest)
+	total, _ := paypal.ParseMinorUnits(unit.Amount.Value, unit.Amount.CurrencyCode)
+	err = payments.create(Payment{
+		OrderID:       cart.OrderID,
+		PayPalOrderID: order.ID,
+		Intent:        intent,
+		Status:        PaymentPending,
+		Currency:      unit.Amount.CurrencyCode,
+		Amount:        total,
+		StateNonce:    state.Nonce,
+	})
+	if err != nil {
+		// Aynı sipariş için eşzamanlı gelen başka bir istek ödemeyi tamamladı
+		http.Error(w, err.Error(), http.StatusConflict)
 		return
 	}
 
-	err = refundPayment(token, saleID)
-	if err != nil {
-		http.Error(w, "Failed to refund payment", http.StatusInternalServerError)
+	if r.Method == http.MethodPost {
+		carts.save(cart)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"order_id":        cart.OrderID,
+			"paypal_order_id": order.ID,
+			"approval_url":    approvalURL,
+		})
 		return
 	}
 
-	fmt.Fprintln(w, "Payment canceled and refunded successfully!")
+	// Kullanıcıyı PayPal onay sayfasına yönlendirme
+	http.Redirect(w, r, approvalURL, http.StatusTemporaryRedirect)
 }
 
-func getAccessToken() (string, error) {
-	url := "https://api.sandbox.paypal.com/v1/oauth2/token"
-	reqBody := []byte("grant_type=client_credentials")
+/*
+	PayPal onaydan sonra order ID'yi token parametresi ile döner, state bizim eklediğimiz imzalı değerdir:
+	<PUBLIC_BASE_URL>/success?state=<STATE>&token=<ORDER_ID>&PayerID=<PAYER_ID>
+*/
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(reqBody))
+func handleSuccess(w http.ResponseWriter, r *http.Request) {
+	orderID := r.URL.Query().Get("token")
+	if orderID == "" {
+		http.Error(w, "Missing token in request", http.StatusBadRequest)
+		return
+	}
+
+	state, err := parseRedirectState(r.URL.Query().Get("state"))
+	if err != nil {
+		http.Error(w, "Invalid state: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	payment, err := payments.consumeState(state, orderID)
 	if err != nil {
-		return "", err
+		http.Error(w, "Invalid state: "+err.Error(), http.StatusConflict)
+		return
 	}
 
-	req.SetBasicAuth(clientID, clientSecret)
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if payment.Intent == paypal.IntentAuthorize {
+		authorizeApprovedOrder(w, r, payment, state)
+		return
+	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	order, err := client.CaptureOrder(orderID, payment.orderRequestID("capture"))
 	if err != nil {
-		return "", err
+		// Ödeme alınmadı, kullanıcı aynı dönüş linkiyle tekrar deneyebilir
+		payments.restoreState(state)
+	}
+	var apiErr *paypal.APIError
+	if errors.As(err, &apiErr) && apiErr.HasIssue("INSTRUMENT_DECLINED") {
+		// Ödeme yöntemi reddedildi, kullanıcı başka bir yöntem seçmesi için onay sayfasına geri gönderilir
+		if pending, getErr := client.GetOrder(orderID); getErr == nil {
+			if approvalURL, urlErr := pending.ApprovalURL(); urlErr == nil {
+				http.Redirect(w, r, approvalURL, http.StatusTemporaryRedirect)
+				return
+			}
+		}
+	}
+	if err != nil {
+		writePayPalError(w, "Failed to execute payment", err)
+		return
 	}
-	defer resp.Body.Close()
-
-	var result map[string]interface{}
-	json.NewDecoder(resp.Body).Decode(&result)
 
-	if token, ok := result["access_token"].(string); ok {
-		return token, nil
+	captures := order.Captures()
+	if len(captures) > 0 && captures[0].Status == "PENDING" {
+		// PayPal tahsilatı inceliyor (örn. eCheck), sonuç PAYMENT.CAPTURE.COMPLETED veya DENIED webhook'u ile gelir.
+		// Ödeme PENDING kalır ve webhook geldiğinde applyCapture ile tamamlanır.
+		payments.update(payment.OrderID, func(p *Payment) error {
+			p.PayPalStatus = order.Status
+			return nil
+		})
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprintln(w, "Payment is pending review by PayPal, capture ID:", captures[0].ID)
+		return
+	}
+	if order.Status != "COMPLETED" || len(captures) == 0 {
+		payments.restoreState(state)
+		http.Error(w, "Payment was not completed", http.StatusPaymentRequired)
+		return
 	}
 
-	return "", fmt.Errorf("could not get access token")
+	payments.update(payment.OrderID, func(p *Payment) error {
+		p.Status = PaymentCompleted
+		p.Captured = p.Amount
+		p.CaptureID = captures[0].ID
+		if order.Payer != nil {
+			p.PayerID = order.Payer.PayerID
+		}
+		return nil
+	})
+
+	fmt.Fprintln(w, "Payment completed successfully! Capture ID:", captures[0].ID)
 }
 
-func createPayment(token string) (string, error) {
-	url := "https://api.sandbox.paypal.com/v1/payments/payment"
+/*
+	Kullanıcı PayPal onay sayfasında ödemeyi iptal ettiğinde buraya döner:
+	<PUBLIC_BASE_URL>/cancel?state=<STATE>&token=<ORDER_ID>
+	Ödeme alınmadığı için iade yapılmaz, bekleyen sipariş terk edilmiş olarak işaretlenir.
+*/
 
-	paymentBody := map[string]interface{}{
-		"intent": "sale",
-		"payer": map[string]interface{}{
-			"payment_method": "paypal",
-		},
-		"transactions": []map[string]interface{}{
-			{
-				"amount": map[string]interface{}{
-					"total":    "10.00", // Ödeme tutarı
-					"currency": "USD",
-				},
-				"description": "This is the payment description.",
-			},
-		},
-		"redirect_urls": map[string]interface{}{
-			"return_url": "http://localhost:3000/success", // Ödeme başarılı olursa
-			"cancel_url": "http://localhost:3000/cancel",  // Ödeme iptal olursa
-		},
+func handleCancel(w http.ResponseWriter, r *http.Request) {
+	orderID := r.URL.Query().Get("token")
+	if orderID == "" {
+		http.Error(w, "Missing token in request", http.StatusBadRequest)
+		return
 	}
 
-	reqBody, err := json.Marshal(paymentBody)
+	state, err := parseRedirectState(r.URL.Query().Get("state"))
 	if err != nil {
-		return "", err
+		http.Error(w, "Invalid state: "+err.Error(), http.StatusBadRequest)
+		return
 	}
-
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(reqBody))
+	payment, err := payments.consumeState(state, orderID)
 	if err != nil {
-		return "", err
+		http.Error(w, "Invalid state: "+err.Error(), http.StatusConflict)
+		return
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+token)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	_, err = payments.update(payment.OrderID, func(p *Payment) error {
+		if p.Status != PaymentPending {
+			return fmt.Errorf("payment is %s", p.Status)
+		}
+		p.Status = PaymentAbandoned
+		return nil
+	})
 	if err != nil {
-		return "", err
+		http.Error(w, "Payment can not be canceled: "+err.Error(), http.StatusConflict)
+		return
 	}
-	defer resp.Body.Close()
-
-	var result map[string]interface{}
-	json.NewDecoder(resp.Body).Decode(&result)
 
-	// Ödeme onayı için kullanıcıyı yönlendireceğimiz PayPal URL'sini alıyoruz
-	if links, ok := result["links"].([]interface{}); ok {
-		for _, link := range links {
-			linkMap := link.(map[string]interface{})
-			if rel, exists := linkMap["rel"]; exists && rel == "approval_url" {
-				return linkMap["href"].(string), nil
-			}
-		}
-	}
+	fmt.Fprintln(w, "Payment canceled!")
+}
 
-	return "", fmt.Errorf("approval_url not found")
+// refundAPIRequest POST /refunds isteği. Amount boş ise kalan tutarın tamamı iade edilir.
+type refundAPIRequest struct {
+	CaptureID string `json:"capture_id"`
+	Amount    string `json:"amount,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	InvoiceID string `json:"invoice_id,omitempty"`
 }
 
-func executePayment(token, paymentID, payerID string) (string, error) {
-	url := fmt.Sprintf("https://api.sandbox.paypal.com/v1/payments/payment/%s/execute", paymentID)
+/*
+	Mağaza tarafından yapılan iade. API-Key başlığı ile doğrulanır:
+	curl -X POST http://localhost:3000/refunds \
+	-H "API-Key: <ADMIN_API_KEY>" -H "Content-Type: application/json" \
+	-d '{"capture_id": "<CAPTURE_ID>", "amount": "5.00", "reason": "Missing item", "invoice_id": "<ORDER_ID>"}'
+*/
 
-	executeBody := map[string]interface{}{
-		"payer_id": payerID,
+func handleRefund(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
 
-	reqBody, err := json.Marshal(executeBody)
-	if err != nil {
-		return "", err
+	var req refundAPIRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid refund payload: "+err.Error(), http.StatusBadRequest)
+		return
 	}
-
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(reqBody))
-	if err != nil {
-		return "", err
+	if req.CaptureID == "" {
+		http.Error(w, "Missing capture_id in request", http.StatusBadRequest)
+		return
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+token)
+	payment, ok := payments.findByCapture(req.CaptureID)
+	if !ok {
+		http.Error(w, "Payment not found", http.StatusNotFound)
+		return
+	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("payment execution failed with status: %d", resp.StatusCode)
-	}
-
-	var result map[string]interface{}
-	json.NewDecoder(resp.Body).Decode(&result)
-
-	// Sale ID'yi al
-	if transactions, ok := result["transactions"].([]interface{}); ok {
-		for _, transaction := range transactions {
-			transactionMap := transaction.(map[string]interface{})
-			if relatedResources, exists := transactionMap["related_resources"].([]interface{}); exists {
-				for _, resource := range relatedResources {
-					resourceMap := resource.(map[string]interface{})
-					if sale, exists := resourceMap["sale"].(map[string]interface{}); exists {
-						if saleID, exists := sale["id"].(string); exists {
-							return saleID, nil
-						}
-					}
-				}
-			}
+	remaining := payment.Captured - payment.Refunded
+	amount := remaining
+	if req.Amount != "" {
+		var err error
+		if amount, err = paypal.ParseMinorUnits(req.Amount, payment.Currency); err != nil {
+			http.Error(w, "Invalid amount: "+err.Error(), http.StatusBadRequest)
+			return
 		}
 	}
-
-	return "", fmt.Errorf("sale_id not found")
-}
-func refundPayment(token, saleID string) error {
-	url := fmt.Sprintf("https://api.sandbox.paypal.com/v1/payments/sale/%s/refund", saleID)
-
-	req, err := http.NewRequest("POST", url, nil)
-	if err != nil {
-		return err
+	if amount <= 0 || amount > remaining {
+		http.Error(w, fmt.Sprintf("Refund amount must be between 0 and %s", paypal.FormatMinorUnits(remaining, payment.Currency)), http.StatusBadRequest)
+		return
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+token)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	refundAmount := paypal.NewMoney(amount, payment.Currency)
+	refund, err := client.RefundCapture(req.CaptureID, paypal.RefundRequest{
+		// Aynı iade tekrar denenirse (örn. zaman aşımından sonra) PayPal ikinci kez iade etmez.
+		// Kaydedilen iade toplamı veya deneme sayacı değişince ID de değişir.
+		RequestID:   fmt.Sprintf("%s-refund-%d-%d-%d", req.CaptureID, payment.Refunded, amount, payment.Attempts),
+		Amount:      &refundAmount,
+		InvoiceID:   req.InvoiceID,
+		NoteToPayer: req.Reason,
+	})
+	var apiErr *paypal.APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode < 500 {
+		// PayPal iadeyi reddetti, aynı ID ile tekrar deneme yine bu hatayı alır
+		payments.update(payment.OrderID, func(p *Payment) error {
+			p.Attempts++
+			return nil
+		})
+	}
 	if err != nil {
-		return err
+		writePayPalError(w, "Failed to refund payment", err)
+		return
 	}
-	defer resp.Body.Close()
 
-	// 200, 201 veya 204 durum kodlarını kabul et
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("refund failed with status: %d", resp.StatusCode)
-	}
+	payment, _ = payments.update(payment.OrderID, func(p *Payment) error {
+		if refund.Status == "CANCELLED" || refund.Status == "FAILED" {
+			p.Attempts++
+			return nil
+		}
+		p.applyRefund(refund.ID, amount)
+		return nil
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"refund_id":      refund.ID,
+		"status":         refund.Status,
+		"amount":         refundAmount.Value,
+		"currency":       refundAmount.CurrencyCode,
+		"payment_status": payment.Status,
+		"refunded_total": paypal.FormatMinorUnits(payment.Refunded, payment.Currency),
+	})
+}
 
-	fmt.Println("Payment refunded successfully!")
-	return nil
+// requireAPIKey handler'ı API-Key başlığı ile korur
+func requireAPIKey(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("API-Key")
+		if adminAPIKey == "" || subtle.ConstantTimeCompare([]byte(key), []byte(adminAPIKey)) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
 }
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"paypal/paypal"
+)
+
+// Yerel ödeme durumları
+const (
+	PaymentPending           = "PENDING"
+	PaymentAbandoned         = "ABANDONED"
+	PaymentAuthorized        = "AUTHORIZED"
+	PaymentPartiallyCaptured = "PARTIALLY_CAPTURED"
+	PaymentVoided            = "VOIDED"
+	PaymentCompleted         = "COMPLETED"
+	PaymentDenied            = "DENIED"
+	PaymentPartiallyRefunded = "PARTIALLY_REFUNDED"
+	PaymentRefunded          = "REFUNDED"
+)
+
+// Payment yerel sipariş ile PayPal order/capture bilgisini eşleyen kayıt.
+// Tutarlar para biriminin en küçük biriminde tutulur.
+type Payment struct {
+	OrderID       string `json:"order_id"`
+	PayPalOrderID string `json:"paypal_order_id"`
+	CaptureID     string `json:"capture_id,omitempty"`
+	PayerID       string `json:"payer_id,omitempty"`
+	Intent        string `json:"intent"`
+	Status        string `json:"status"`
+	Currency      string `json:"currency"`
+	Amount        int64  `json:"amount"`
+	Captured      int64  `json:"captured"`
+	Refunded      int64  `json:"refunded"`
+	// Attempts PayPal-Request-Id'lere eklenen deneme sayacı. Tekrar denemeler aynı ID ile
+	// PayPal'da bir kez işlenir; işlem kesin olarak başarısız olunca artırılır ki sonraki
+	// deneme önceki sonucun önbellekteki kopyasını almasın.
+	Attempts int `json:"attempts,omitempty"`
+	// Authorize akışında provizyon bilgisi
+	AuthorizationID string    `json:"authorization_id,omitempty"`
+	AuthorizedAt    time.Time `json:"authorized_at,omitempty"`
+	Reauthorized    bool      `json:"reauthorized,omitempty"`
+	CaptureIDs      []string  `json:"capture_ids,omitempty"`
+	RefundIDs       []string  `json:"refund_ids,omitempty"`
+	DisputeID       string    `json:"dispute_id,omitempty"`
+	DisputeStatus   string    `json:"dispute_status,omitempty"`
+	// PayPalStatus PayPal'daki son bilinen order durumu, ReconciledAt son karşılaştırma zamanı
+	PayPalStatus string    `json:"paypal_status,omitempty"`
+	ReconciledAt time.Time `json:"reconciled_at,omitempty"`
+	// StateNonce dönüş URL'sindeki state'in tek kullanımlık değeri, kullanılınca silinir
+	StateNonce string    `json:"-"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// errPaymentExists sipariş için ödeme alınmış veya işlemde, yeni PayPal order'ı açılamaz
+var errPaymentExists = errors.New("a payment for this order is already in progress or completed")
+
+// replaceable ödeme alınmadıysa sipariş için yeni bir PayPal order'ı açılabilir
+func (p *Payment) replaceable() bool {
+	return p.Status == PaymentPending || p.Status == PaymentAbandoned
+}
+
+// applyRefund iadeyi ödemeye işler. Aynı iade hem /refunds hem webhook ile
+// gelebildiği için iade ID'si daha önce işlendiyse tutar tekrar eklenmez.
+func (p *Payment) applyRefund(refundID string, amount int64) {
+	for _, id := range p.RefundIDs {
+		if id == refundID {
+			return
+		}
+	}
+	p.RefundIDs = append(p.RefundIDs, refundID)
+	p.Refunded += amount
+	if p.Refunded > p.Captured {
+		p.Refunded = p.Captured
+	}
+
+	if p.Refunded >= p.Captured {
+		p.Status = PaymentRefunded
+	} else if p.Refunded > 0 {
+		p.Status = PaymentPartiallyRefunded
+	}
+}
+
+// applyCapture tahsilatı ödemeye işler. Provizyon tahsilatı hem /authorizations/capture hem
+// webhook ile gelebildiği için capture ID'si daha önce işlendiyse tutar tekrar eklenmez.
+// amount yoksa kalan tutarın tamamı eklenir; final veya tutarın tamamı tahsil edildiyse ödeme tamamlanır.
+func (p *Payment) applyCapture(captureID string, amount *paypal.Money, final bool) error {
+	for _, id := range p.CaptureIDs {
+		if id == captureID {
+			return nil
+		}
+	}
+	switch p.Status {
+	case PaymentPending, PaymentAbandoned, PaymentAuthorized, PaymentPartiallyCaptured:
+	default:
+		// Tamamlanmış, iade edilmiş veya iptal edilmiş ödemenin capture ID'si ve tutarları değişmez
+		return nil
+	}
+
+	captured := p.Amount - p.Captured
+	if amount != nil {
+		var err error
+		if captured, err = paypal.ParseMinorUnits(amount.Value, p.Currency); err != nil {
+			return fmt.Errorf("capture %s: %w", captureID, err)
+		}
+	}
+	p.CaptureIDs = append(p.CaptureIDs, captureID)
+	p.CaptureID = captureID
+	p.Captured += captured
+	if p.Captured > p.Amount {
+		p.Captured = p.Amount
+	}
+	if final || p.Captured >= p.Amount || p.Intent != paypal.IntentAuthorize {
+		p.Status = PaymentCompleted
+	} else {
+		p.Status = PaymentPartiallyCaptured
+	}
+	return nil
+}
+
+// paymentStore ödemeleri bellekte tutar. path verilmişse her değişiklikten sonra
+// kayıtlar JSON dosyasına yazılır ve açılışta oradan yüklenir.
+type paymentStore struct {
+	mu        sync.Mutex
+	path      string
+	byOrderID map[string]*Payment
+	byPayPal  map[string]string
+	byCapture map[string]string
+}
+
+var payments = openPaymentStore(os.Getenv("PAYMENTS_FILE"))
+
+// storedPayment dosyaya yazılan kayıt. StateNonce API yanıtlarında görünmez ama
+// yeniden başlatmadan sonra dönüş linklerinin çalışması için saklanır.
+type storedPayment struct {
+	Payment
+	StateNonce string `json:"state_nonce,omitempty"`
+}
+
+// openPaymentStore store'u oluşturur ve varsa dosyadaki kayıtları yükler
+func openPaymentStore(path string) *paymentStore {
+	s := &paymentStore{
+		path:      path,
+		byOrderID: make(map[string]*Payment),
+		byPayPal:  make(map[string]string),
+		byCapture: make(map[string]string),
+	}
+	if path == "" {
+		return s
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s
+	}
+	if err != nil {
+		log.Fatalf("payments: read %s: %v", path, err)
+	}
+	var stored []storedPayment
+	if err := json.Unmarshal(data, &stored); err != nil {
+		log.Fatalf("payments: decode %s: %v", path, err)
+	}
+	for _, sp := range stored {
+		p := sp.Payment
+		p.StateNonce = sp.StateNonce
+		s.index(&p)
+	}
+	return s
+}
+
+// index kaydı ve arama tablolarını günceller, kilit altında çağrılır
+func (s *paymentStore) index(p *Payment) {
+	s.byOrderID[p.OrderID] = p
+	s.byPayPal[p.PayPalOrderID] = p.OrderID
+	if p.CaptureID != "" {
+		s.byCapture[p.CaptureID] = p.OrderID
+	}
+}
+
+// persist tüm kayıtları dosyaya yazar, kilit altında çağrılır. Yarım yazılmış
+// dosya kalmaması için önce geçici dosyaya yazılıp yeniden adlandırılır.
+func (s *paymentStore) persist() {
+	if s.path == "" {
+		return
+	}
+	stored := make([]storedPayment, 0, len(s.byOrderID))
+	for _, p := range s.byOrderID {
+		stored = append(stored, storedPayment{Payment: *p, StateNonce: p.StateNonce})
+	}
+	sort.Slice(stored, func(i, j int) bool { return stored[i].CreatedAt.Before(stored[j].CreatedAt) })
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		log.Printf("payments: encode: %v", err)
+		return
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		log.Printf("payments: write %s: %v", tmp, err)
+		return
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		log.Printf("payments: rename %s: %v", tmp, err)
+	}
+}
+
+// create yeni bir ödeme kaydı ekler. Sipariş için bekleyen veya terk edilmiş bir ödeme varsa
+// yenisiyle değiştirilir ve eski PayPal order'ının arama kayıtları silinir; ödeme alınmışsa
+// errPaymentExists döner.
+func (s *paymentStore) create(p Payment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if old, ok := s.byOrderID[p.OrderID]; ok {
+		if !old.replaceable() {
+			return errPaymentExists
+		}
+		delete(s.byPayPal, old.PayPalOrderID)
+		if old.CaptureID != "" {
+			delete(s.byCapture, old.CaptureID)
+		}
+	}
+
+	now := time.Now()
+	p.CreatedAt, p.UpdatedAt = now, now
+	s.index(&p)
+	s.persist()
+	return nil
+}
+
+// checkReplaceable sipariş için yeni ödeme açılıp açılamayacağını PayPal'a gitmeden kontrol eder
+func (s *paymentStore) checkReplaceable(orderID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if old, ok := s.byOrderID[orderID]; ok && !old.replaceable() {
+		return errPaymentExists
+	}
+	return nil
+}
+
+// find yerel order ID'sine ait ödemeyi döner
+func (s *paymentStore) find(orderID string) (Payment, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.byOrderID[orderID]
+	if !ok {
+		return Payment{}, false
+	}
+	return *p, true
+}
+
+// findByPayPalOrder PayPal order ID'sine ait ödemeyi döner
+func (s *paymentStore) findByPayPalOrder(paypalOrderID string) (Payment, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.byOrderID[s.byPayPal[paypalOrderID]]
+	if !ok {
+		return Payment{}, false
+	}
+	return *p, true
+}
+
+// findByCapture capture ID'sine ait ödemeyi döner
+func (s *paymentStore) findByCapture(captureID string) (Payment, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.byOrderID[s.byCapture[captureID]]
+	if !ok {
+		return Payment{}, false
+	}
+	return *p, true
+}
+
+// update ödemeyi kilit altında günceller
+func (s *paymentStore) update(orderID string, fn func(p *Payment) error) (Payment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.byOrderID[orderID]
+	if !ok {
+		return Payment{}, fmt.Errorf("payment for order %s not found", orderID)
+	}
+
+	updated := *p
+	if err := fn(&updated); err != nil {
+		return Payment{}, err
+	}
+	updated.UpdatedAt = time.Now()
+	*p = updated
+	if p.CaptureID != "" {
+		s.byCapture[p.CaptureID] = orderID
+	}
+	s.persist()
+	return *p, nil
+}
+
+// list ödemeleri oluşturulma sırasına göre döner, status boş değilse filtreler
+func (s *paymentStore) list(status string) []Payment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var list []Payment
+	for _, p := range s.byOrderID {
+		if status == "" || p.Status == status {
+			list = append(list, *p)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list
+}
+
+// consumeState dönüş state'ini doğrular ve tek kullanımlık değeri siler. State başka bir
+// PayPal order'ına aitse veya daha önce kullanıldıysa hata döner.
+func (s *paymentStore) consumeState(state redirectState, paypalOrderID string) (Payment, error) {
+	return s.update(state.OrderID, func(p *Payment) error {
+		if p.PayPalOrderID != paypalOrderID {
+			return fmt.Errorf("state does not belong to paypal order %s", paypalOrderID)
+		}
+		if p.StateNonce == "" || !hmac.Equal([]byte(p.StateNonce), []byte(state.Nonce)) {
+			return fmt.Errorf("state already used")
+		}
+		p.StateNonce = ""
+		return nil
+	})
+}
+
+// restoreState işlem tamamlanamadığında state'i tekrar kullanılabilir yapar,
+// örn. ödeme yöntemi reddedilip kullanıcı onay sayfasına geri gönderildiğinde.
+// Sonraki deneme yeni bir PayPal-Request-Id ile gönderilsin diye sayaç artırılır.
+func (s *paymentStore) restoreState(state redirectState) {
+	s.update(state.OrderID, func(p *Payment) error {
+		p.StateNonce = state.Nonce
+		p.Attempts++
+		return nil
+	})
+}
+
+// orderRequestID order'ın capture/authorize isteği için PayPal-Request-Id üretir. Aynı
+// denemede tekrar gönderilen istek (örn. zaman aşımından sonra) PayPal'da bir kez işlenir.
+func (p Payment) orderRequestID(action string) string {
+	return fmt.Sprintf("%s-%s-%d", p.PayPalOrderID, action, p.Attempts)
+}
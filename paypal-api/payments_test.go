@@ -0,0 +1,338 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"paypal/paypal"
+)
+
+func newTestPayments() *paymentStore {
+	return openPaymentStore("")
+}
+
+func TestPaymentStoreUpdate(t *testing.T) {
+	s := newTestPayments()
+	s.create(Payment{OrderID: "ORDER-1", PayPalOrderID: "PAYPAL-1", Status: PaymentPending, Currency: "USD", Amount: 1000})
+
+	if _, ok := s.findByCapture("CAPTURE-1"); ok {
+		t.Fatal("findByCapture found a payment before capture")
+	}
+
+	p, err := s.update("ORDER-1", func(p *Payment) error {
+		p.Status = PaymentCompleted
+		p.CaptureID = "CAPTURE-1"
+		return nil
+	})
+	if err != nil || p.Status != PaymentCompleted {
+		t.Fatalf("update = %+v, %v", p, err)
+	}
+	if got, ok := s.findByCapture("CAPTURE-1"); !ok || got.OrderID != "ORDER-1" {
+		t.Errorf("findByCapture = %+v, %v", got, ok)
+	}
+
+	// Hata dönen güncelleme kaydı değiştirmemeli
+	_, err = s.update("ORDER-1", func(p *Payment) error {
+		p.Refunded = 500
+		return errors.New("rejected")
+	})
+	if err == nil {
+		t.Fatal("update did not return the callback error")
+	}
+	if got, _ := s.findByPayPalOrder("PAYPAL-1"); got.Refunded != 0 {
+		t.Errorf("failed update leaked Refunded = %d", got.Refunded)
+	}
+
+	if _, err := s.update("MISSING", func(p *Payment) error { return nil }); err == nil {
+		t.Error("update of unknown order succeeded")
+	}
+}
+
+func TestPaymentStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "payments.json")
+	s := openPaymentStore(path)
+	state := newRedirectState("ORDER-1")
+	s.create(Payment{OrderID: "ORDER-1", PayPalOrderID: "PAYPAL-1", Status: PaymentPending, Currency: "EUR", Amount: 1000, StateNonce: state.Nonce})
+	s.create(Payment{OrderID: "ORDER-2", PayPalOrderID: "PAYPAL-2", Status: PaymentPending, Currency: "EUR", Amount: 500})
+	s.update("ORDER-2", func(p *Payment) error {
+		p.Status, p.CaptureID, p.Captured = PaymentCompleted, "CAPTURE-2", 500
+		return nil
+	})
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+
+	reopened := openPaymentStore(path)
+	if p, ok := reopened.findByCapture("CAPTURE-2"); !ok || p.OrderID != "ORDER-2" || p.Captured != 500 {
+		t.Errorf("findByCapture after restart = %+v, %v", p, ok)
+	}
+	// Yeniden başlatmadan önce oluşturulan dönüş linki çalışmaya devam etmeli
+	if _, err := reopened.consumeState(state, "PAYPAL-1"); err != nil {
+		t.Errorf("state after restart: %v", err)
+	}
+	if list := reopened.list(PaymentPending); len(list) != 1 || list[0].OrderID != "ORDER-1" {
+		t.Errorf("list(PENDING) = %+v", list)
+	}
+
+	// StateNonce API yanıtlarında görünmemeli
+	data, _ := json.Marshal(Payment{OrderID: "ORDER-1", StateNonce: "secret-nonce"})
+	if strings.Contains(string(data), "secret-nonce") {
+		t.Errorf("payment JSON leaks the state nonce: %s", data)
+	}
+}
+
+func TestReconcilePayment(t *testing.T) {
+	var captured []string
+	stubPayPal(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/checkout/orders/PAYPAL-APPROVED":
+			fmt.Fprint(w, `{"id": "PAYPAL-APPROVED", "status": "APPROVED"}`)
+		case "/v2/checkout/orders/PAYPAL-APPROVED/capture":
+			captured = append(captured, r.Header.Get("PayPal-Request-Id"))
+			fmt.Fprint(w, `{"id": "PAYPAL-APPROVED", "status": "COMPLETED", "payer": {"payer_id": "PAYER-1"},
+				"purchase_units": [{"payments": {"captures": [{"id": "CAPTURE-1", "status": "COMPLETED", "amount": {"currency_code": "EUR", "value": "10.00"}}]}}]}`)
+		case "/v2/checkout/orders/PAYPAL-CREATED":
+			fmt.Fprint(w, `{"id": "PAYPAL-CREATED", "status": "CREATED"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"name": "RESOURCE_NOT_FOUND"}`)
+		}
+	})
+	payments.create(Payment{OrderID: "ORDER-1", PayPalOrderID: "PAYPAL-APPROVED", Intent: paypal.IntentCapture, Status: PaymentPending, Currency: "EUR", Amount: 1000, StateNonce: "nonce"})
+	payments.create(Payment{OrderID: "ORDER-2", PayPalOrderID: "PAYPAL-GONE", Intent: paypal.IntentCapture, Status: PaymentPending, Currency: "EUR", Amount: 1000})
+	payments.create(Payment{OrderID: "ORDER-3", PayPalOrderID: "PAYPAL-CREATED", Intent: paypal.IntentCapture, Status: PaymentPending, Currency: "EUR", Amount: 1000})
+
+	reconcilePayments(0)
+
+	if p, _ := payments.find("ORDER-1"); p.Status != PaymentCompleted || p.CaptureID != "CAPTURE-1" || p.Captured != 1000 || p.PayerID != "PAYER-1" || p.StateNonce != "" {
+		t.Errorf("approved order = %+v", p)
+	}
+	if len(captured) != 1 || captured[0] != "PAYPAL-APPROVED-capture-0" {
+		t.Errorf("capture requests = %q, want one with the /success request ID", captured)
+	}
+	if p, _ := payments.find("ORDER-2"); p.Status != PaymentAbandoned || p.PayPalStatus != "NOT_FOUND" {
+		t.Errorf("missing order = %+v", p)
+	}
+	// Onay linki henüz geçerli, ödeme beklemede kalmalı
+	if p, _ := payments.find("ORDER-3"); p.Status != PaymentPending || p.PayPalStatus != "CREATED" {
+		t.Errorf("created order = %+v", p)
+	}
+}
+
+func TestHandleCancel(t *testing.T) {
+	saved := payments
+	defer func() { payments = saved }()
+	payments = newTestPayments()
+	pending, completed := newRedirectState("ORDER-1"), newRedirectState("ORDER-2")
+	payments.create(Payment{OrderID: "ORDER-1", PayPalOrderID: "PAYPAL-1", Status: PaymentPending, Currency: "USD", Amount: 1000, StateNonce: pending.Nonce})
+	payments.create(Payment{OrderID: "ORDER-2", PayPalOrderID: "PAYPAL-2", Status: PaymentCompleted, Currency: "USD", Amount: 1000, StateNonce: completed.Nonce})
+
+	tests := []struct {
+		name  string
+		query string
+		code  int
+	}{
+		{"missing token", "", http.StatusBadRequest},
+		{"missing state", "?token=PAYPAL-1", http.StatusBadRequest},
+		{"state of another order", "?token=PAYPAL-2&state=" + pending.encode(), http.StatusConflict},
+		{"pending order", "?token=PAYPAL-1&state=" + pending.encode(), http.StatusOK},
+		{"replayed state", "?token=PAYPAL-1&state=" + pending.encode(), http.StatusConflict},
+		{"completed order", "?token=PAYPAL-2&state=" + completed.encode(), http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handleCancel(rec, httptest.NewRequest(http.MethodGet, "/cancel"+tt.query, nil))
+			if rec.Code != tt.code {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.code, rec.Body)
+			}
+		})
+	}
+
+	if p, _ := payments.findByPayPalOrder("PAYPAL-1"); p.Status != PaymentAbandoned {
+		t.Errorf("status = %s, want %s", p.Status, PaymentAbandoned)
+	}
+}
+
+func TestConsumeState(t *testing.T) {
+	s := newTestPayments()
+	state := newRedirectState("ORDER-1")
+	s.create(Payment{OrderID: "ORDER-1", PayPalOrderID: "PAYPAL-1", Status: PaymentPending, Currency: "USD", Amount: 1000, StateNonce: state.Nonce})
+
+	if _, err := s.consumeState(state, "PAYPAL-OTHER"); err == nil {
+		t.Fatal("state was accepted for another PayPal order")
+	}
+	if _, err := s.consumeState(state, "PAYPAL-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.consumeState(state, "PAYPAL-1"); err == nil {
+		t.Fatal("state was accepted twice")
+	}
+
+	// Tahsilat başarısız olursa kullanıcı aynı dönüş linkiyle tekrar deneyebilir
+	s.restoreState(state)
+	p, err := s.consumeState(state, "PAYPAL-1")
+	if err != nil {
+		t.Errorf("restored state was rejected: %v", err)
+	}
+	if p.Attempts != 1 || p.orderRequestID("capture") != "PAYPAL-1-capture-1" {
+		t.Errorf("attempts after restore = %d", p.Attempts)
+	}
+}
+
+func TestHandleSuccessRetriesDeclinedCapture(t *testing.T) {
+	var requestIDs []string
+	stubPayPal(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/checkout/orders/PAYPAL-1":
+			fmt.Fprint(w, `{"id": "PAYPAL-1", "status": "APPROVED", "links": [{"rel": "approve", "href": "https://paypal.test/approve?token=PAYPAL-1"}]}`)
+		case "/v2/checkout/orders/PAYPAL-1/capture":
+			requestIDs = append(requestIDs, r.Header.Get("PayPal-Request-Id"))
+			if len(requestIDs) == 1 {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				fmt.Fprint(w, `{"name": "UNPROCESSABLE_ENTITY", "details": [{"issue": "INSTRUMENT_DECLINED"}]}`)
+				return
+			}
+			fmt.Fprint(w, `{"id": "PAYPAL-1", "status": "COMPLETED",
+				"purchase_units": [{"payments": {"captures": [{"id": "CAPTURE-1", "status": "COMPLETED"}]}}]}`)
+		}
+	})
+	state := newRedirectState("ORDER-1")
+	payments.create(Payment{OrderID: "ORDER-1", PayPalOrderID: "PAYPAL-1", Intent: paypal.IntentCapture, Status: PaymentPending, Currency: "EUR", Amount: 1000, StateNonce: state.Nonce})
+
+	success := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handleSuccess(rec, httptest.NewRequest(http.MethodGet, "/success?token=PAYPAL-1&state="+state.encode(), nil))
+		return rec
+	}
+	// Reddedilen ödeme yönteminden sonra kullanıcı onay sayfasına geri gönderilir
+	if rec := success(); rec.Code != http.StatusTemporaryRedirect || !strings.Contains(rec.Header().Get("Location"), "approve") {
+		t.Fatalf("declined capture = %d %s", rec.Code, rec.Header().Get("Location"))
+	}
+	// Yeni yöntemle dönüşte capture yeni request ID ile gönderilir, önceki red tekrar dönmez
+	if rec := success(); rec.Code != http.StatusOK {
+		t.Fatalf("second capture = %d: %s", rec.Code, rec.Body)
+	}
+	if len(requestIDs) != 2 || requestIDs[0] != "PAYPAL-1-capture-0" || requestIDs[1] != "PAYPAL-1-capture-1" {
+		t.Errorf("PayPal-Request-Id values = %q", requestIDs)
+	}
+	if p, _ := payments.find("ORDER-1"); p.Status != PaymentCompleted || p.CaptureID != "CAPTURE-1" {
+		t.Errorf("payment = %+v", p)
+	}
+}
+
+func TestHandleRefundValidation(t *testing.T) {
+	saved, savedKey := payments, adminAPIKey
+	defer func() { payments, adminAPIKey = saved, savedKey }()
+	payments = newTestPayments()
+	payments.create(Payment{OrderID: "ORDER-1", PayPalOrderID: "PAYPAL-1", CaptureID: "CAPTURE-1", Status: PaymentPartiallyRefunded, Currency: "USD", Amount: 1000, Refunded: 400})
+	adminAPIKey = "secret"
+
+	tests := []struct {
+		name   string
+		method string
+		key    string
+		body   string
+		code   int
+	}{
+		{"missing api key", http.MethodPost, "", `{"capture_id":"CAPTURE-1"}`, http.StatusUnauthorized},
+		{"wrong api key", http.MethodPost, "wrong", `{"capture_id":"CAPTURE-1"}`, http.StatusUnauthorized},
+		{"wrong method", http.MethodGet, "secret", "", http.StatusMethodNotAllowed},
+		{"invalid json", http.MethodPost, "secret", `{`, http.StatusBadRequest},
+		{"missing capture", http.MethodPost, "secret", `{}`, http.StatusBadRequest},
+		{"unknown capture", http.MethodPost, "secret", `{"capture_id":"CAPTURE-X"}`, http.StatusNotFound},
+		{"invalid amount", http.MethodPost, "secret", `{"capture_id":"CAPTURE-1","amount":"1.234"}`, http.StatusBadRequest},
+		{"more than remaining", http.MethodPost, "secret", `{"capture_id":"CAPTURE-1","amount":"6.01"}`, http.StatusBadRequest},
+		{"zero amount", http.MethodPost, "secret", `{"capture_id":"CAPTURE-1","amount":"0.00"}`, http.StatusBadRequest},
+	}
+	handler := requireAPIKey(handleRefund)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/refunds", strings.NewReader(tt.body))
+			if tt.key != "" {
+				req.Header.Set("API-Key", tt.key)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+			if rec.Code != tt.code {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.code, rec.Body)
+			}
+		})
+	}
+}
+
+func TestHandleRefundRequestIDs(t *testing.T) {
+	var requestIDs []string
+	stubPayPal(t, func(w http.ResponseWriter, r *http.Request) {
+		requestIDs = append(requestIDs, r.Header.Get("PayPal-Request-Id"))
+		switch len(requestIDs) {
+		case 1:
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"id": "REFUND-1", "status": "FAILED"}`)
+		case 2:
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			fmt.Fprint(w, `{"name": "UNPROCESSABLE_ENTITY", "details": [{"issue": "INSUFFICIENT_FUNDS"}]}`)
+		default:
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"id": "REFUND-3", "status": "COMPLETED"}`)
+		}
+	})
+	payments.create(Payment{OrderID: "ORDER-1", PayPalOrderID: "PAYPAL-1", CaptureID: "CAPTURE-1", Status: PaymentCompleted, Currency: "EUR", Amount: 1000, Captured: 1000})
+
+	refund := func() int {
+		req := httptest.NewRequest(http.MethodPost, "/refunds", strings.NewReader(`{"capture_id": "CAPTURE-1", "amount": "5.00"}`))
+		req.Header.Set("API-Key", "secret")
+		rec := httptest.NewRecorder()
+		requireAPIKey(handleRefund)(rec, req)
+		return rec.Code
+	}
+	for i := 0; i < 3; i++ {
+		refund()
+	}
+
+	// Başarısız iadeden sonraki deneme önceki sonucun önbellekteki kopyasını almaz
+	want := []string{"CAPTURE-1-refund-0-500-0", "CAPTURE-1-refund-0-500-1", "CAPTURE-1-refund-0-500-2"}
+	if fmt.Sprint(requestIDs) != fmt.Sprint(want) {
+		t.Errorf("PayPal-Request-Id values = %q, want %q", requestIDs, want)
+	}
+	if p, _ := payments.find("ORDER-1"); p.Refunded != 500 || p.Status != PaymentPartiallyRefunded || len(p.RefundIDs) != 1 {
+		t.Errorf("payment = %+v", p)
+	}
+}
+
+func TestPaymentStoreCreateReplacesOnlyUnpaidOrders(t *testing.T) {
+	s := openPaymentStore("")
+
+	if err := s.create(Payment{OrderID: "order-1", PayPalOrderID: "PP-1", Status: PaymentPending}); err != nil {
+		t.Fatal(err)
+	}
+	// Bekleyen ödeme yeni PayPal order'ı ile değiştirilebilir, eski order artık bulunmaz
+	if err := s.create(Payment{OrderID: "order-1", PayPalOrderID: "PP-2", Status: PaymentPending}); err != nil {
+		t.Fatalf("replacing a pending payment: %v", err)
+	}
+	if _, ok := s.findByPayPalOrder("PP-1"); ok {
+		t.Error("stale PayPal order index entry was not removed")
+	}
+
+	s.update("order-1", func(p *Payment) error {
+		p.Status, p.CaptureID = PaymentCompleted, "CAP-2"
+		return nil
+	})
+	err := s.create(Payment{OrderID: "order-1", PayPalOrderID: "PP-3", Status: PaymentPending})
+	if !errors.Is(err, errPaymentExists) {
+		t.Fatalf("create over a completed payment = %v, want errPaymentExists", err)
+	}
+	if err := s.checkReplaceable("order-1"); !errors.Is(err, errPaymentExists) {
+		t.Errorf("checkReplaceable = %v, want errPaymentExists", err)
+	}
+	if p, ok := s.findByCapture("CAP-2"); !ok || p.PayPalOrderID != "PP-2" {
+		t.Errorf("completed payment was modified: %+v", p)
+	}
+}
@@ -0,0 +1,428 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"paypal/paypal"
+)
+
+// payoutFinalStatuses tekrar değişmeyecek item durumları
+var payoutFinalStatuses = map[string]bool{
+	"SUCCESS":  true,
+	"FAILED":   true,
+	"RETURNED": true,
+	"REFUNDED": true,
+	"REVERSED": true,
+	"BLOCKED":  true,
+	"DENIED":   true,
+}
+
+// unclaimedExpiry sahiplenilmeyen ödemeyi PayPal 30 gün sonra iade eder. Bu süre dolana kadar
+// UNCLAIMED item'lar sorgulanmaz, sonra RETURNED durumunu almak için batch tekrar sorgulanır.
+const unclaimedExpiry = 31 * 24 * time.Hour
+
+// PayoutRecord mutabakat için tutulan yerel payout item kaydı
+type PayoutRecord struct {
+	SenderItemID  string `json:"sender_item_id"`
+	RecipientKind string `json:"recipient_kind"`
+	RecipientID   string `json:"recipient_id"`
+	Receiver      string `json:"receiver"`
+	Amount        string `json:"amount"`
+	Currency      string `json:"currency"`
+	PayoutItemID  string `json:"payout_item_id,omitempty"`
+	TransactionID string `json:"transaction_id,omitempty"`
+	Status        string `json:"status"`
+	Fee           string `json:"fee,omitempty"`
+	Error         string `json:"error,omitempty"`
+	// UnclaimedSince item'ın UNCLAIMED durumuna geçtiği zaman
+	UnclaimedSince time.Time `json:"unclaimed_since,omitempty"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// settled item son durumuna ulaştıysa veya sahiplenilmesi bekleniyorsa true döner
+func (r *PayoutRecord) settled(now time.Time) bool {
+	if r.Status == "UNCLAIMED" {
+		return now.Before(r.UnclaimedSince.Add(unclaimedExpiry))
+	}
+	return payoutFinalStatuses[r.Status]
+}
+
+// PayoutBatchRecord yerel batch kaydı
+type PayoutBatchRecord struct {
+	SenderBatchID string          `json:"sender_batch_id"`
+	PayoutBatchID string          `json:"payout_batch_id"`
+	Status        string          `json:"status"`
+	Items         []*PayoutRecord `json:"items"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+}
+
+// done batch ve tüm item'lar son durumuna ulaştıysa true döner
+func (b *PayoutBatchRecord) done() bool {
+	if b.Status == "DENIED" || b.Status == "CANCELED" {
+		return true
+	}
+	if b.Status != "SUCCESS" {
+		return false
+	}
+	now := time.Now()
+	for _, item := range b.Items {
+		if !item.settled(now) {
+			return false
+		}
+	}
+	return true
+}
+
+// apply PayPal'dan gelen batch durumunu yerel kayda işler
+func (b *PayoutBatchRecord) apply(batch *paypal.PayoutBatch) {
+	b.Status = batch.BatchHeader.BatchStatus
+	for i := range batch.Items {
+		b.applyItem(&batch.Items[i])
+	}
+	b.UpdatedAt = time.Now()
+}
+
+// applyItem tek bir item durumunu sender_item_id ile eşleyerek işler
+func (b *PayoutBatchRecord) applyItem(details *paypal.PayoutItemDetails) {
+	for _, item := range b.Items {
+		if item.SenderItemID != details.PayoutItem.SenderItemID {
+			continue
+		}
+		if details.TransactionStatus == "UNCLAIMED" && item.Status != "UNCLAIMED" {
+			// Alıcının PayPal hesabı yok veya onaylamadı, 30 gün içinde sahiplenilmezse tutar geri döner
+			item.UnclaimedSince = time.Now()
+			log.Printf("payout %s to %s %s is unclaimed", details.PayoutItemID, item.RecipientKind, item.RecipientID)
+		}
+		item.PayoutItemID = details.PayoutItemID
+		item.TransactionID = details.TransactionID
+		item.Status = details.TransactionStatus
+		if details.PayoutItemFee != nil {
+			item.Fee = details.PayoutItemFee.Value
+		}
+		if details.Errors != nil {
+			item.Error = details.Errors.Name + ": " + details.Errors.Message
+		}
+		item.UpdatedAt = time.Now()
+		return
+	}
+}
+
+// payoutStore batch kayıtlarını bellekte tutar. path verilmişse her değişiklikten sonra
+// kayıtlar JSON dosyasına yazılır ve açılışta oradan yüklenir.
+type payoutStore struct {
+	mu      sync.Mutex
+	path    string
+	batches map[string]*PayoutBatchRecord
+}
+
+var payouts = openPayoutStore(os.Getenv("PAYOUTS_FILE"))
+
+// openPayoutStore store'u oluşturur ve varsa dosyadaki kayıtları yükler
+func openPayoutStore(path string) *payoutStore {
+	s := &payoutStore{path: path, batches: make(map[string]*PayoutBatchRecord)}
+	if path == "" {
+		return s
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s
+	}
+	if err != nil {
+		log.Fatalf("payouts: read %s: %v", path, err)
+	}
+	var stored []*PayoutBatchRecord
+	if err := json.Unmarshal(data, &stored); err != nil {
+		log.Fatalf("payouts: decode %s: %v", path, err)
+	}
+	for _, b := range stored {
+		s.batches[b.PayoutBatchID] = b
+	}
+	return s
+}
+
+// persist tüm batch'leri dosyaya yazar, kilit altında çağrılır. Yarım yazılmış
+// dosya kalmaması için önce geçici dosyaya yazılıp yeniden adlandırılır.
+func (s *payoutStore) persist() {
+	if s.path == "" {
+		return
+	}
+	stored := make([]*PayoutBatchRecord, 0, len(s.batches))
+	for _, b := range s.batches {
+		stored = append(stored, b)
+	}
+	sort.Slice(stored, func(i, j int) bool { return stored[i].CreatedAt.Before(stored[j].CreatedAt) })
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		log.Printf("payouts: encode: %v", err)
+		return
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		log.Printf("payouts: write %s: %v", tmp, err)
+		return
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		log.Printf("payouts: rename %s: %v", tmp, err)
+	}
+}
+
+// save batch'i kaydeder
+func (s *payoutStore) save(b *PayoutBatchRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches[b.PayoutBatchID] = b
+	s.persist()
+}
+
+// update batch'i kilit altında günceller
+func (s *payoutStore) update(batchID string, fn func(b *PayoutBatchRecord)) (PayoutBatchRecord, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.batches[batchID]
+	if !ok {
+		return PayoutBatchRecord{}, false
+	}
+	fn(b)
+	s.persist()
+	return b.snapshot(), true
+}
+
+// pending henüz sonuçlanmamış batch ID'lerini döner
+func (s *payoutStore) pending() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ids []string
+	for id, b := range s.batches {
+		if !b.done() {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// snapshot kilit dışında okunabilecek bir kopya döner
+func (b *PayoutBatchRecord) snapshot() PayoutBatchRecord {
+	c := *b
+	c.Items = make([]*PayoutRecord, len(b.Items))
+	for i, item := range b.Items {
+		copied := *item
+		c.Items[i] = &copied
+	}
+	return c
+}
+
+// refreshPayoutBatch batch durumunu PayPal'dan çekip yerel kayda işler
+func refreshPayoutBatch(batchID string) (PayoutBatchRecord, error) {
+	batch, err := client.GetPayoutBatch(batchID)
+	if err != nil {
+		return PayoutBatchRecord{}, err
+	}
+	record, ok := payouts.update(batchID, func(b *PayoutBatchRecord) { b.apply(batch) })
+	if !ok {
+		return PayoutBatchRecord{}, fmt.Errorf("payout batch %s not found", batchID)
+	}
+	return record, nil
+}
+
+// pollPayouts sonuçlanmamış batch'leri periyodik olarak sorgular
+func pollPayouts(interval time.Duration) {
+	for range time.Tick(interval) {
+		ids := payouts.pending()
+		if len(ids) == 0 {
+			continue
+		}
+
+		for _, id := range ids {
+			if _, err := refreshPayoutBatch(id); err != nil {
+				log.Printf("payout poller: batch %s: %v", id, err)
+			}
+		}
+	}
+}
+
+// payoutRecipient POST /payouts isteğindeki alıcı
+type payoutRecipient struct {
+	Kind     string `json:"kind"` // courier veya restaurant
+	ID       string `json:"id"`
+	Email    string `json:"email,omitempty"`
+	PayPalID string `json:"paypal_id,omitempty"`
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+	Note     string `json:"note,omitempty"`
+}
+
+// payoutAPIRequest POST /payouts isteği
+type payoutAPIRequest struct {
+	BatchID    string            `json:"batch_id"`
+	Subject    string            `json:"subject,omitempty"`
+	Recipients []payoutRecipient `json:"recipients"`
+}
+
+// handlePayouts GET ile batch durumunu döner, POST ile yeni batch oluşturur
+func handlePayouts(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		handleGetPayout(w, r)
+	case http.MethodPost:
+		handleCreatePayout(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+/*
+	Kurye ve restoranlara toplu ödeme gönderir (API-Key gerekir):
+	curl -X POST http://localhost:3000/payouts \
+	-H "API-Key: <ADMIN_API_KEY>" -H "Content-Type: application/json" \
+	-d '{"batch_id": "2024-W18-couriers", "recipients": [{"kind": "courier", "id": "c1", "email": "courier@example.com", "amount": "42.00", "currency": "EUR"}]}'
+*/
+
+func handleCreatePayout(w http.ResponseWriter, r *http.Request) {
+	var req payoutAPIRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid payout payload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.BatchID == "" || len(req.Recipients) == 0 {
+		http.Error(w, "batch_id and recipients are required", http.StatusBadRequest)
+		return
+	}
+
+	payout := paypal.PayoutRequest{
+		SenderBatchHeader: paypal.SenderBatchHeader{
+			SenderBatchID: req.BatchID,
+			EmailSubject:  req.Subject,
+		},
+	}
+	record := &PayoutBatchRecord{SenderBatchID: req.BatchID, CreatedAt: time.Now()}
+
+	for i, rcpt := range req.Recipients {
+		currency, err := paypal.NormalizeCurrency(rcpt.Currency)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("recipient %d: %v", i, err), http.StatusBadRequest)
+			return
+		}
+		amount, err := paypal.ParseMinorUnits(rcpt.Amount, currency)
+		if err != nil || amount <= 0 {
+			http.Error(w, fmt.Sprintf("recipient %d: invalid amount", i), http.StatusBadRequest)
+			return
+		}
+
+		item := paypal.PayoutItem{
+			Amount:       paypal.PayoutAmount{Value: paypal.FormatMinorUnits(amount, currency), Currency: currency},
+			Note:         rcpt.Note,
+			SenderItemID: fmt.Sprintf("%s-%d", req.BatchID, i),
+		}
+		switch {
+		case rcpt.PayPalID != "":
+			item.RecipientType, item.Receiver = "PAYPAL_ID", rcpt.PayPalID
+		case rcpt.Email != "":
+			item.RecipientType, item.Receiver = "EMAIL", rcpt.Email
+		default:
+			http.Error(w, fmt.Sprintf("recipient %d: email or paypal_id is required", i), http.StatusBadRequest)
+			return
+		}
+		payout.Items = append(payout.Items, item)
+
+		record.Items = append(record.Items, &PayoutRecord{
+			SenderItemID:  item.SenderItemID,
+			RecipientKind: rcpt.Kind,
+			RecipientID:   rcpt.ID,
+			Receiver:      item.Receiver,
+			Amount:        item.Amount.Value,
+			Currency:      currency,
+			Status:        "PENDING",
+			UpdatedAt:     time.Now(),
+		})
+	}
+
+	batch, err := client.CreatePayoutBatch(payout)
+	if err != nil {
+		writePayPalError(w, "Failed to create payout", err)
+		return
+	}
+
+	record.PayoutBatchID = batch.BatchHeader.PayoutBatchID
+	record.Status = batch.BatchHeader.BatchStatus
+	record.UpdatedAt = time.Now()
+	response := record.snapshot()
+	payouts.save(record)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleGetPayout batch durumunu PayPal'dan yeniler ve yerel kaydı döner
+func handleGetPayout(w http.ResponseWriter, r *http.Request) {
+	batchID := r.URL.Query().Get("batch_id")
+	if batchID == "" {
+		http.Error(w, "Missing batch_id in request", http.StatusBadRequest)
+		return
+	}
+
+	record, err := refreshPayoutBatch(batchID)
+	if err != nil {
+		writePayPalError(w, "Failed to get payout", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(record)
+}
+
+// handleCancelUnclaimed batch'teki sahiplenilmemiş ödemeleri iptal eder
+func handleCancelUnclaimed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	batchID := r.URL.Query().Get("batch_id")
+	if batchID == "" {
+		http.Error(w, "Missing batch_id in request", http.StatusBadRequest)
+		return
+	}
+
+	record, err := refreshPayoutBatch(batchID)
+	if err != nil {
+		writePayPalError(w, "Failed to get payout", err)
+		return
+	}
+
+	for _, item := range record.Items {
+		if item.Status != "UNCLAIMED" {
+			continue
+		}
+		details, err := client.CancelPayoutItem(item.PayoutItemID)
+		if err != nil {
+			log.Printf("cancel payout item %s: %v", item.PayoutItemID, err)
+			continue
+		}
+		record, _ = payouts.update(batchID, func(b *PayoutBatchRecord) { b.applyItem(details) })
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(record)
+}
+
+// processPayoutEvent PAYMENT.PAYOUTS-ITEM.* webhook olaylarını işler
+func processPayoutEvent(event paypal.WebhookEvent) error {
+	var details paypal.PayoutItemDetails
+	if err := json.Unmarshal(event.Resource, &details); err != nil {
+		return err
+	}
+	if _, ok := payouts.update(details.PayoutBatchID, func(b *PayoutBatchRecord) { b.applyItem(&details) }); !ok {
+		log.Printf("webhook %s: no local payout batch %s", event.ID, details.PayoutBatchID)
+	}
+	return nil
+}
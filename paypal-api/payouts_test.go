@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"paypal/paypal"
+)
+
+func TestPayoutBatchRecordDone(t *testing.T) {
+	b := &PayoutBatchRecord{
+		Status: "PENDING",
+		Items:  []*PayoutRecord{{SenderItemID: "b-0", Status: "PENDING"}, {SenderItemID: "b-1", Status: "PENDING"}},
+	}
+	if b.done() {
+		t.Fatal("pending batch is done")
+	}
+
+	batch := &paypal.PayoutBatch{BatchHeader: paypal.PayoutBatchHeader{BatchStatus: "SUCCESS"}}
+	item := paypal.PayoutItemDetails{PayoutItemID: "ITEM-0", TransactionStatus: "SUCCESS", PayoutItemFee: &paypal.PayoutAmount{Value: "0.25", Currency: "EUR"}}
+	item.PayoutItem.SenderItemID = "b-0"
+	batch.Items = append(batch.Items, item)
+	b.apply(batch)
+	if b.done() {
+		t.Fatal("batch with a pending item is done")
+	}
+	if b.Items[0].PayoutItemID != "ITEM-0" || b.Items[0].Fee != "0.25" {
+		t.Errorf("applied item = %+v", b.Items[0])
+	}
+
+	failed := paypal.PayoutItemDetails{PayoutItemID: "ITEM-1", TransactionStatus: "FAILED", Errors: &paypal.PayoutError{Name: "RECEIVER_UNREGISTERED", Message: "Receiver is unregistered"}}
+	failed.PayoutItem.SenderItemID = "b-1"
+	b.applyItem(&failed)
+	if !b.done() {
+		t.Error("batch with final items is not done")
+	}
+	if b.Items[1].Error != "RECEIVER_UNREGISTERED: Receiver is unregistered" {
+		t.Errorf("item error = %q", b.Items[1].Error)
+	}
+
+	if denied := (&PayoutBatchRecord{Status: "DENIED"}); !denied.done() {
+		t.Error("denied batch is not done")
+	}
+}
+
+func TestCreatePayout(t *testing.T) {
+	var sent paypal.PayoutRequest
+	stubPayPal(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&sent)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"batch_header":{"payout_batch_id":"BATCH-1","batch_status":"PENDING"}}`)
+	})
+	savedPayouts := payouts
+	defer func() { payouts = savedPayouts }()
+	payouts = &payoutStore{batches: make(map[string]*PayoutBatchRecord)}
+
+	post := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handlePayouts(rec, httptest.NewRequest(http.MethodPost, "/payouts", strings.NewReader(body)))
+		return rec
+	}
+
+	for _, body := range []string{
+		`{"recipients":[{"email":"a@example.com","amount":"1.00","currency":"EUR"}]}`,
+		`{"batch_id":"W18","recipients":[{"email":"a@example.com","amount":"0","currency":"EUR"}]}`,
+		`{"batch_id":"W18","recipients":[{"email":"a@example.com","amount":"1.00","currency":"XX"}]}`,
+		`{"batch_id":"W18","recipients":[{"amount":"1.00","currency":"EUR"}]}`,
+	} {
+		if rec := post(body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d", body, rec.Code)
+		}
+	}
+
+	rec := post(`{"batch_id":"W18","recipients":[
+		{"kind":"courier","id":"c1","email":"courier@example.com","amount":"42","currency":"eur"},
+		{"kind":"restaurant","id":"r1","paypal_id":"PAYERID1","amount":"100.50","currency":"EUR"}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+
+	if sent.SenderBatchHeader.SenderBatchID != "W18" || len(sent.Items) != 2 {
+		t.Fatalf("sent = %+v", sent)
+	}
+	if it := sent.Items[0]; it.RecipientType != "EMAIL" || it.Amount.Value != "42.00" || it.Amount.Currency != "EUR" || it.SenderItemID != "W18-0" {
+		t.Errorf("item 0 = %+v", it)
+	}
+	if it := sent.Items[1]; it.RecipientType != "PAYPAL_ID" || it.Receiver != "PAYERID1" || it.SenderItemID != "W18-1" {
+		t.Errorf("item 1 = %+v", it)
+	}
+	if ids := payouts.pending(); len(ids) != 1 || ids[0] != "BATCH-1" {
+		t.Errorf("pending() = %v, want [BATCH-1]", ids)
+	}
+}
+
+func TestPayoutBatchDoneWithUnclaimedItems(t *testing.T) {
+	b := &PayoutBatchRecord{
+		Status: "SUCCESS",
+		Items:  []*PayoutRecord{{SenderItemID: "b-0", Status: "PENDING"}, {SenderItemID: "b-1", Status: "SUCCESS"}},
+	}
+	if b.done() {
+		t.Fatal("batch with a pending item is not done")
+	}
+
+	details := paypal.PayoutItemDetails{PayoutItemID: "ITEM-0", TransactionStatus: "UNCLAIMED"}
+	details.PayoutItem.SenderItemID = "b-0"
+	b.applyItem(&details)
+	if b.Items[0].UnclaimedSince.IsZero() {
+		t.Fatal("UnclaimedSince was not set")
+	}
+	if !b.done() {
+		t.Error("unclaimed items must not be polled before they expire")
+	}
+
+	b.Items[0].UnclaimedSince = time.Now().Add(-unclaimedExpiry - time.Hour)
+	if b.done() {
+		t.Error("expired unclaimed item must be polled again to pick up RETURNED")
+	}
+}
+
+func TestPayoutStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "payouts.json")
+	s := openPayoutStore(path)
+	s.save(&PayoutBatchRecord{PayoutBatchID: "BATCH-1", Status: "PENDING", Items: []*PayoutRecord{{SenderItemID: "b-0", Status: "PENDING"}}})
+	s.update("BATCH-1", func(b *PayoutBatchRecord) { b.Status = "SUCCESS" })
+
+	reopened := openPayoutStore(path)
+	record, ok := reopened.update("BATCH-1", func(*PayoutBatchRecord) {})
+	if !ok || record.Status != "SUCCESS" || len(record.Items) != 1 {
+		t.Fatalf("reloaded batch = %+v, %v", record, ok)
+	}
+	if ids := reopened.pending(); len(ids) != 1 || ids[0] != "BATCH-1" {
+		t.Errorf("pending() = %v, want [BATCH-1]", ids)
+	}
+}
@@ -0,0 +1,58 @@
+package paypal
+
+import (
+	"net/url"
+	"time"
+)
+
+const (
+	// AuthorizationHonorPeriod bu süre içinde yapılan capture'lar PayPal tarafından garanti edilir
+	AuthorizationHonorPeriod = 3 * 24 * time.Hour
+	// AuthorizationValidity bu süreden sonra authorization ile capture yapılamaz
+	AuthorizationValidity = 29 * 24 * time.Hour
+)
+
+// CaptureRequest /v2/payments/authorizations/{id}/capture isteği. Amount boş ise kalan tutar tahsil edilir.
+type CaptureRequest struct {
+	RequestID    string `json:"-"` // PayPal-Request-Id, bkz. OrderRequest.RequestID
+	Amount       *Money `json:"amount,omitempty"`
+	InvoiceID    string `json:"invoice_id,omitempty"`
+	FinalCapture bool   `json:"final_capture"`
+	NoteToPayer  string `json:"note_to_payer,omitempty"`
+}
+
+// CaptureAuthorization provizyonun tamamını veya bir kısmını tahsil eder
+func (c *Client) CaptureAuthorization(authorizationID string, capture CaptureRequest) (*Capture, error) {
+	var result Capture
+	if err := c.doIdempotent("POST", "/v2/payments/authorizations/"+url.PathEscape(authorizationID)+"/capture", capture.RequestID, capture, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// VoidAuthorization tahsil edilmemiş provizyonu iptal eder
+func (c *Client) VoidAuthorization(authorizationID string) error {
+	return c.do("POST", "/v2/payments/authorizations/"+url.PathEscape(authorizationID)+"/void", nil, nil)
+}
+
+// Reauthorize honor period dolduktan sonra provizyonu yeniler, yeni bir authorization döner
+func (c *Client) Reauthorize(authorizationID string, amount *Money) (*Authorization, error) {
+	var result Authorization
+	body := map[string]*Money{}
+	if amount != nil {
+		body["amount"] = amount
+	}
+	if err := c.do("POST", "/v2/payments/authorizations/"+url.PathEscape(authorizationID)+"/reauthorize", body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetAuthorization provizyon detaylarını getirir
+func (c *Client) GetAuthorization(authorizationID string) (*Authorization, error) {
+	var result Authorization
+	if err := c.do("GET", "/v2/payments/authorizations/"+url.PathEscape(authorizationID), nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
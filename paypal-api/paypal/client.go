@@ -0,0 +1,129 @@
+// Package paypal PayPal REST API için küçük bir istemcidir. Access token'ı
+// kendisi yönetir, sandbox veya live ortamına BaseURL ile yönlendirilir.
+package paypal
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// PayPal REST API ortamları
+const (
+	SandboxBaseURL = "https://api-m.sandbox.paypal.com"
+	LiveBaseURL    = "https://api-m.paypal.com"
+)
+
+// Client client credentials ile kimlik doğrulayan PayPal istemcisi
+type Client struct {
+	ClientID   string
+	Secret     string
+	BaseURL    string
+	HTTPClient *http.Client
+
+	tokens *tokenSource
+}
+
+// NewClient verilen ortam için istemci oluşturur. baseURL boş ise sandbox kullanılır.
+func NewClient(clientID, secret, baseURL string) *Client {
+	if baseURL == "" {
+		baseURL = SandboxBaseURL
+	}
+	c := &Client{
+		ClientID:   clientID,
+		Secret:     secret,
+		BaseURL:    baseURL,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+	c.tokens = &tokenSource{fetch: c.fetchAccessToken}
+	return c
+}
+
+// AccessToken önbellekteki access token'ı döner, süresi dolmuşsa yeniler
+func (c *Client) AccessToken() (string, error) {
+	return c.tokens.Token()
+}
+
+// do bearer token ile JSON istek atar ve yanıtı out'a çözer. POST isteklerine her seferinde
+// yeni bir PayPal-Request-Id verilir; tekrar denemede aynı işlemin yapılmaması gereken
+// çağrılar doIdempotent kullanır.
+func (c *Client) do(method, path string, body interface{}, out interface{}) error {
+	return c.doIdempotent(method, path, "", body, out)
+}
+
+// doIdempotent do gibidir, ancak POST isteklerinde requestID'yi PayPal-Request-Id olarak gönderir.
+// PayPal aynı ID ile gelen isteği tekrar işlemez, ilk isteğin sonucunu döner. requestID boşsa
+// rastgele bir ID üretilir ve istek idempotent olmaz.
+func (c *Client) doIdempotent(method, path, requestID string, body interface{}, out interface{}) error {
+	token, err := c.AccessToken()
+	if err != nil {
+		return err
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reqBody, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("encode paypal request: %w", err)
+		}
+		reader = bytes.NewBuffer(reqBody)
+	} else if method == "POST" {
+		// capture ve authorize boş JSON gövdesi bekler
+		reader = bytes.NewBufferString("{}")
+	}
+
+	url := c.BaseURL + path
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Prefer", "return=representation")
+	if method == "POST" {
+		if requestID == "" {
+			requestID = newRequestID()
+		}
+		req.Header.Set("PayPal-Request-Id", requestID)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		// Token PayPal tarafında geçersiz kılınmış olabilir
+		c.tokens.Invalidate()
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return newAPIError(resp.StatusCode, respBody)
+	}
+
+	if out == nil || len(respBody) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("decode paypal response for %s %s: %w", method, url, err)
+	}
+	return nil
+}
+
+// newRequestID PayPal-Request-Id başlığı için rastgele bir değer üretir
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,77 @@
+package paypal
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrorDetail PayPal hata yanıtındaki details elemanı
+type ErrorDetail struct {
+	Field       string `json:"field,omitempty"`
+	Value       string `json:"value,omitempty"`
+	Location    string `json:"location,omitempty"`
+	Issue       string `json:"issue"`
+	Description string `json:"description,omitempty"`
+}
+
+// APIError PayPal'ın 2xx dışı yanıtları için döndüğü hata.
+// REST API'leri name/message/debug_id/details, OAuth endpoint'i error/error_description döner.
+type APIError struct {
+	StatusCode       int           `json:"-"`
+	Name             string        `json:"name"`
+	Message          string        `json:"message"`
+	DebugID          string        `json:"debug_id"`
+	Details          []ErrorDetail `json:"details,omitempty"`
+	Links            []Link        `json:"links,omitempty"`
+	OAuthError       string        `json:"error,omitempty"`
+	ErrorDescription string        `json:"error_description,omitempty"`
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "paypal: %d", e.StatusCode)
+	switch {
+	case e.Name != "":
+		fmt.Fprintf(&b, " %s: %s", e.Name, e.Message)
+	case e.OAuthError != "":
+		fmt.Fprintf(&b, " %s: %s", e.OAuthError, e.ErrorDescription)
+	}
+	for _, d := range e.Details {
+		fmt.Fprintf(&b, " [%s", d.Issue)
+		if d.Field != "" {
+			fmt.Fprintf(&b, " %s", d.Field)
+		}
+		if d.Description != "" {
+			fmt.Fprintf(&b, ": %s", d.Description)
+		}
+		b.WriteString("]")
+	}
+	if e.DebugID != "" {
+		fmt.Fprintf(&b, " (debug_id %s)", e.DebugID)
+	}
+	return b.String()
+}
+
+// HasIssue hata detaylarında verilen issue kodunun olup olmadığını döner, örn. INSTRUMENT_DECLINED
+func (e *APIError) HasIssue(issue string) bool {
+	for _, d := range e.Details {
+		if d.Issue == issue {
+			return true
+		}
+	}
+	return false
+}
+
+// newAPIError yanıt gövdesinden APIError oluşturur. Gövde JSON değilse ham metin mesaj olarak kullanılır.
+func newAPIError(statusCode int, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: statusCode}
+	if err := json.Unmarshal(body, apiErr); err != nil {
+		apiErr.Message = strings.TrimSpace(string(body))
+	}
+	if apiErr.Name == "" && apiErr.OAuthError == "" {
+		apiErr.Name = http.StatusText(statusCode)
+	}
+	return apiErr
+}
@@ -0,0 +1,51 @@
+package paypal
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestNewAPIError(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{"rest error", 422, `{"name":"UNPROCESSABLE_ENTITY","message":"The requested action could not be performed.","debug_id":"abc123","details":[{"issue":"INSTRUMENT_DECLINED","description":"The instrument was declined."}]}`,
+			"paypal: 422 UNPROCESSABLE_ENTITY: The requested action could not be performed. [INSTRUMENT_DECLINED: The instrument was declined.] (debug_id abc123)"},
+		{"field detail", 400, `{"name":"INVALID_REQUEST","message":"Request is not well-formed.","details":[{"field":"/purchase_units/0/amount","issue":"MISSING_REQUIRED_PARAMETER"}]}`,
+			"paypal: 400 INVALID_REQUEST: Request is not well-formed. [MISSING_REQUIRED_PARAMETER /purchase_units/0/amount]"},
+		{"oauth error", 401, `{"error":"invalid_client","error_description":"Client Authentication failed"}`,
+			"paypal: 401 invalid_client: Client Authentication failed"},
+		{"plain text body", 503, "upstream unavailable\n",
+			"paypal: 503 Service Unavailable: upstream unavailable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newAPIError(tt.status, []byte(tt.body))
+			if err.StatusCode != tt.status {
+				t.Errorf("StatusCode = %d, want %d", err.StatusCode, tt.status)
+			}
+			if got := err.Error(); got != tt.want {
+				t.Errorf("Error() = %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPIErrorHasIssue(t *testing.T) {
+	err := newAPIError(422, []byte(`{"name":"UNPROCESSABLE_ENTITY","details":[{"issue":"INSTRUMENT_DECLINED"}]}`))
+	if !err.HasIssue("INSTRUMENT_DECLINED") {
+		t.Error("HasIssue(INSTRUMENT_DECLINED) = false")
+	}
+	if err.HasIssue("ORDER_ALREADY_CAPTURED") {
+		t.Error("HasIssue(ORDER_ALREADY_CAPTURED) = true")
+	}
+
+	var apiErr *APIError
+	if !errors.As(fmt.Errorf("capture order: %w", err), &apiErr) {
+		t.Error("wrapped APIError is not found with errors.As")
+	}
+}
@@ -0,0 +1,99 @@
+package paypal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// currencyDecimals PayPal'ın desteklediği para birimleri ve ondalık basamak sayıları
+var currencyDecimals = map[string]int{
+	"AUD": 2,
+	"CAD": 2,
+	"CHF": 2,
+	"CZK": 2,
+	"DKK": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"HKD": 2,
+	"HUF": 0,
+	"JPY": 0,
+	"NOK": 2,
+	"NZD": 2,
+	"PLN": 2,
+	"SEK": 2,
+	"SGD": 2,
+	"TWD": 0,
+	"USD": 2,
+}
+
+// NormalizeCurrency para birimini büyük harfe çevirir ve desteklenip desteklenmediğini kontrol eder
+func NormalizeCurrency(currency string) (string, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if _, ok := currencyDecimals[currency]; !ok {
+		return "", fmt.Errorf("unsupported currency %q", currency)
+	}
+	return currency, nil
+}
+
+// ParseMinorUnits "12.50" gibi bir tutarı para biriminin en küçük birimine (kuruş, cent) çevirir.
+// Float kullanılmaz, böylece toplamlarda yuvarlama hatası oluşmaz.
+func ParseMinorUnits(value, currency string) (int64, error) {
+	decimals, ok := currencyDecimals[currency]
+	if !ok {
+		return 0, fmt.Errorf("unsupported currency %q", currency)
+	}
+
+	value = strings.TrimSpace(value)
+	whole, frac, hasFrac := strings.Cut(value, ".")
+	if whole == "" || (hasFrac && frac == "") {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+	if len(frac) > decimals {
+		return 0, fmt.Errorf("amount %q has more than %d decimals for %s", value, decimals, currency)
+	}
+	for _, c := range whole + frac {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("invalid amount %q", value)
+		}
+	}
+
+	frac += strings.Repeat("0", decimals-len(frac))
+	units, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q: %w", value, err)
+	}
+	return units, nil
+}
+
+// ParseSignedMinorUnits raporlardaki "-12.50" gibi işaretli tutarları da kabul eder
+func ParseSignedMinorUnits(value, currency string) (int64, error) {
+	value = strings.TrimSpace(value)
+	if rest, ok := strings.CutPrefix(value, "-"); ok {
+		units, err := ParseMinorUnits(rest, currency)
+		return -units, err
+	}
+	return ParseMinorUnits(value, currency)
+}
+
+// FormatMinorUnits en küçük birimdeki tutarı PayPal'ın beklediği string formatına çevirir
+func FormatMinorUnits(units int64, currency string) string {
+	if units < 0 {
+		return "-" + FormatMinorUnits(-units, currency)
+	}
+	decimals := currencyDecimals[currency]
+	if decimals == 0 {
+		return strconv.FormatInt(units, 10)
+	}
+
+	s := strconv.FormatInt(units, 10)
+	if len(s) <= decimals {
+		s = strings.Repeat("0", decimals-len(s)+1) + s
+	}
+	return s[:len(s)-decimals] + "." + s[len(s)-decimals:]
+}
+
+// NewMoney en küçük birimdeki tutardan Money oluşturur
+func NewMoney(units int64, currency string) Money {
+	return Money{CurrencyCode: currency, Value: FormatMinorUnits(units, currency)}
+}
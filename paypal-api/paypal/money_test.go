@@ -0,0 +1,79 @@
+package paypal
+
+import "testing"
+
+func TestParseMinorUnits(t *testing.T) {
+	tests := []struct {
+		value    string
+		currency string
+		want     int64
+		wantErr  bool
+	}{
+		{"12.50", "EUR", 1250, false},
+		{"12.5", "EUR", 1250, false},
+		{"12", "EUR", 1200, false},
+		{"0.01", "USD", 1, false},
+		{" 7.00 ", "GBP", 700, false},
+		{"1500", "JPY", 1500, false},
+		{"1500.5", "JPY", 0, true},
+		{"12.345", "EUR", 0, true},
+		{"12.", "EUR", 0, true},
+		{".50", "EUR", 0, true},
+		{"-1.00", "EUR", 0, true},
+		{"1e3", "EUR", 0, true},
+		{"1,50", "EUR", 0, true},
+		{"", "EUR", 0, true},
+		{"10.00", "TRY", 0, true},
+		{"92233720368547758.07", "EUR", 9223372036854775807, false},
+		{"92233720368547758.08", "EUR", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseMinorUnits(tt.value, tt.currency)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseMinorUnits(%q, %s) = %d, %v; want %d, error %v", tt.value, tt.currency, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParseSignedMinorUnits(t *testing.T) {
+	if got, err := ParseSignedMinorUnits("-12.50", "EUR"); err != nil || got != -1250 {
+		t.Errorf("ParseSignedMinorUnits(-12.50) = %d, %v", got, err)
+	}
+	if got, err := ParseSignedMinorUnits("3.10", "EUR"); err != nil || got != 310 {
+		t.Errorf("ParseSignedMinorUnits(3.10) = %d, %v", got, err)
+	}
+}
+
+func TestFormatMinorUnits(t *testing.T) {
+	tests := []struct {
+		units    int64
+		currency string
+		want     string
+	}{
+		{1250, "EUR", "12.50"},
+		{5, "EUR", "0.05"},
+		{0, "USD", "0.00"},
+		{100, "USD", "1.00"},
+		{1500, "JPY", "1500"},
+		{-1250, "EUR", "-12.50"},
+		{-3, "HUF", "-3"},
+	}
+	for _, tt := range tests {
+		if got := FormatMinorUnits(tt.units, tt.currency); got != tt.want {
+			t.Errorf("FormatMinorUnits(%d, %s) = %q, want %q", tt.units, tt.currency, got, tt.want)
+		}
+		// Biçimlenen tutar tekrar aynı değere çözülmeli
+		if back, err := ParseSignedMinorUnits(tt.want, tt.currency); err != nil || back != tt.units {
+			t.Errorf("round trip %d %s = %d, %v", tt.units, tt.currency, back, err)
+		}
+	}
+}
+
+func TestNormalizeCurrency(t *testing.T) {
+	if got, err := NormalizeCurrency(" eur "); err != nil || got != "EUR" {
+		t.Errorf("NormalizeCurrency(eur) = %q, %v", got, err)
+	}
+	if _, err := NormalizeCurrency("TRY"); err == nil {
+		t.Error("TRY is not supported by PayPal and should be rejected")
+	}
+}
@@ -0,0 +1,274 @@
+package paypal
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// Order intent değerleri
+const (
+	IntentCapture   = "CAPTURE"
+	IntentAuthorize = "AUTHORIZE"
+)
+
+// Money PayPal v2 tutar yapısı
+type Money struct {
+	CurrencyCode string `json:"currency_code"`
+	Value        string `json:"value"`
+}
+
+// AmountBreakdown toplam tutarın kalemlere dağılımı
+type AmountBreakdown struct {
+	ItemTotal *Money `json:"item_total,omitempty"`
+	TaxTotal  *Money `json:"tax_total,omitempty"`
+	Shipping  *Money `json:"shipping,omitempty"`
+}
+
+// AmountWithBreakdown purchase unit toplam tutarı
+type AmountWithBreakdown struct {
+	CurrencyCode string           `json:"currency_code"`
+	Value        string           `json:"value"`
+	Breakdown    *AmountBreakdown `json:"breakdown,omitempty"`
+}
+
+// Item purchase unit içindeki ürün kalemi. UnitAmount ve Tax birim başına tutarlardır.
+type Item struct {
+	Name        string `json:"name"`
+	Quantity    string `json:"quantity"`
+	UnitAmount  Money  `json:"unit_amount"`
+	Tax         *Money `json:"tax,omitempty"`
+	SKU         string `json:"sku,omitempty"`
+	Description string `json:"description,omitempty"`
+	Category    string `json:"category,omitempty"`
+}
+
+// PurchaseUnitRequest sipariş oluştururken gönderilen purchase unit
+type PurchaseUnitRequest struct {
+	ReferenceID string              `json:"reference_id,omitempty"`
+	Description string              `json:"description,omitempty"`
+	CustomID    string              `json:"custom_id,omitempty"`
+	InvoiceID   string              `json:"invoice_id,omitempty"`
+	Amount      AmountWithBreakdown `json:"amount"`
+	Items       []Item              `json:"items,omitempty"`
+}
+
+// ApplicationContext onay sayfası ve yönlendirme ayarları
+type ApplicationContext struct {
+	BrandName          string `json:"brand_name,omitempty"`
+	ShippingPreference string `json:"shipping_preference,omitempty"`
+	UserAction         string `json:"user_action,omitempty"`
+	ReturnURL          string `json:"return_url,omitempty"`
+	CancelURL          string `json:"cancel_url,omitempty"`
+}
+
+// OrderRequest /v2/checkout/orders isteği
+type OrderRequest struct {
+	// RequestID PayPal-Request-Id olarak gönderilir, aynı ID ile tekrar denenen istek yeni order açmaz
+	RequestID          string                `json:"-"`
+	Intent             string                `json:"intent"`
+	PurchaseUnits      []PurchaseUnitRequest `json:"purchase_units"`
+	ApplicationContext *ApplicationContext   `json:"application_context,omitempty"`
+}
+
+// Link HATEOAS link yapısı
+type Link struct {
+	Href   string `json:"href"`
+	Rel    string `json:"rel"`
+	Method string `json:"method,omitempty"`
+}
+
+// Capture bir order capture kaydı
+type Capture struct {
+	ID           string `json:"id"`
+	Status       string `json:"status"`
+	Amount       *Money `json:"amount,omitempty"`
+	FinalCapture bool   `json:"final_capture,omitempty"`
+	InvoiceID    string `json:"invoice_id,omitempty"`
+	CustomID     string `json:"custom_id,omitempty"`
+	CreateTime   string `json:"create_time,omitempty"`
+	UpdateTime   string `json:"update_time,omitempty"`
+	Links        []Link `json:"links,omitempty"`
+}
+
+// Authorization bir order authorization kaydı
+type Authorization struct {
+	ID             string `json:"id"`
+	Status         string `json:"status"`
+	Amount         *Money `json:"amount,omitempty"`
+	InvoiceID      string `json:"invoice_id,omitempty"`
+	CustomID       string `json:"custom_id,omitempty"`
+	ExpirationTime string `json:"expiration_time,omitempty"`
+	CreateTime     string `json:"create_time,omitempty"`
+	UpdateTime     string `json:"update_time,omitempty"`
+	Links          []Link `json:"links,omitempty"`
+}
+
+// PaymentCollection purchase unit'e ait capture ve authorization listesi
+type PaymentCollection struct {
+	Captures       []Capture       `json:"captures,omitempty"`
+	Authorizations []Authorization `json:"authorizations,omitempty"`
+}
+
+// PurchaseUnit PayPal'ın döndüğü purchase unit
+type PurchaseUnit struct {
+	ReferenceID string               `json:"reference_id,omitempty"`
+	Description string               `json:"description,omitempty"`
+	CustomID    string               `json:"custom_id,omitempty"`
+	InvoiceID   string               `json:"invoice_id,omitempty"`
+	Amount      *AmountWithBreakdown `json:"amount,omitempty"`
+	Items       []Item               `json:"items,omitempty"`
+	Payments    *PaymentCollection   `json:"payments,omitempty"`
+}
+
+// PayerName ödeyen kişinin adı
+type PayerName struct {
+	GivenName string `json:"given_name,omitempty"`
+	Surname   string `json:"surname,omitempty"`
+}
+
+// Payer ödeyen kişi bilgisi
+type Payer struct {
+	PayerID      string     `json:"payer_id,omitempty"`
+	EmailAddress string     `json:"email_address,omitempty"`
+	Name         *PayerName `json:"name,omitempty"`
+}
+
+// Order /v2/checkout/orders yanıtı
+type Order struct {
+	ID            string         `json:"id"`
+	Status        string         `json:"status"`
+	Intent        string         `json:"intent,omitempty"`
+	PurchaseUnits []PurchaseUnit `json:"purchase_units,omitempty"`
+	Payer         *Payer         `json:"payer,omitempty"`
+	CreateTime    string         `json:"create_time,omitempty"`
+	UpdateTime    string         `json:"update_time,omitempty"`
+	Links         []Link         `json:"links,omitempty"`
+}
+
+// ApprovalURL kullanıcının yönlendirileceği PayPal onay linkini döner
+func (o *Order) ApprovalURL() (string, error) {
+	for _, link := range o.Links {
+		if link.Rel == "approve" || link.Rel == "payer-action" {
+			return link.Href, nil
+		}
+	}
+	return "", fmt.Errorf("approve link not found for order %s", o.ID)
+}
+
+// Captures order'a ait tüm capture kayıtlarını döner
+func (o *Order) Captures() []Capture {
+	var captures []Capture
+	for _, unit := range o.PurchaseUnits {
+		if unit.Payments != nil {
+			captures = append(captures, unit.Payments.Captures...)
+		}
+	}
+	return captures
+}
+
+// Authorizations order'a ait tüm authorization kayıtlarını döner
+func (o *Order) Authorizations() []Authorization {
+	var authorizations []Authorization
+	for _, unit := range o.PurchaseUnits {
+		if unit.Payments != nil {
+			authorizations = append(authorizations, unit.Payments.Authorizations...)
+		}
+	}
+	return authorizations
+}
+
+// CreateOrder yeni bir PayPal order'ı oluşturur
+func (c *Client) CreateOrder(order OrderRequest) (*Order, error) {
+	var result Order
+	if err := c.doIdempotent("POST", "/v2/checkout/orders", order.RequestID, order, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// CaptureOrder kullanıcının onayladığı order'ın ödemesini tahsil eder. requestID
+// PayPal-Request-Id olarak gönderilir, boşsa rastgele üretilir.
+func (c *Client) CaptureOrder(orderID, requestID string) (*Order, error) {
+	var result Order
+	if err := c.doIdempotent("POST", "/v2/checkout/orders/"+url.PathEscape(orderID)+"/capture", requestID, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// AuthorizeOrder kullanıcının onayladığı order için provizyon alır, requestID CaptureOrder'daki gibidir
+func (c *Client) AuthorizeOrder(orderID, requestID string) (*Order, error) {
+	var result Order
+	if err := c.doIdempotent("POST", "/v2/checkout/orders/"+url.PathEscape(orderID)+"/authorize", requestID, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetOrder order detaylarını getirir
+func (c *Client) GetOrder(orderID string) (*Order, error) {
+	var result Order
+	if err := c.do("GET", "/v2/checkout/orders/"+url.PathEscape(orderID), nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// VerifyBreakdown PayPal'a gönderilmeden önce item_total + tax_total + shipping == value
+// ve kalemlerin toplamının item_total/tax_total ile tutarlı olduğunu doğrular
+func (unit PurchaseUnitRequest) VerifyBreakdown() error {
+	currency := unit.Amount.CurrencyCode
+	b := unit.Amount.Breakdown
+	if b == nil {
+		return nil
+	}
+
+	parts := make(map[string]int64)
+	for name, m := range map[string]*Money{"item_total": b.ItemTotal, "tax_total": b.TaxTotal, "shipping": b.Shipping} {
+		if m == nil {
+			continue
+		}
+		if m.CurrencyCode != currency {
+			return fmt.Errorf("%s currency %s does not match %s", name, m.CurrencyCode, currency)
+		}
+		units, err := ParseMinorUnits(m.Value, currency)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		parts[name] = units
+	}
+
+	total, err := ParseMinorUnits(unit.Amount.Value, currency)
+	if err != nil {
+		return fmt.Errorf("amount: %w", err)
+	}
+	if sum := parts["item_total"] + parts["tax_total"] + parts["shipping"]; sum != total {
+		return fmt.Errorf("item_total + tax_total + shipping = %s, want %s",
+			FormatMinorUnits(sum, currency), FormatMinorUnits(total, currency))
+	}
+
+	var items, taxes int64
+	for _, item := range unit.Items {
+		qty, err := strconv.ParseInt(item.Quantity, 10, 64)
+		if err != nil {
+			return fmt.Errorf("item %q: invalid quantity %q", item.Name, item.Quantity)
+		}
+		price, err := ParseMinorUnits(item.UnitAmount.Value, currency)
+		if err != nil {
+			return fmt.Errorf("item %q: %w", item.Name, err)
+		}
+		items += price * qty
+		if item.Tax != nil {
+			tax, err := ParseMinorUnits(item.Tax.Value, currency)
+			if err != nil {
+				return fmt.Errorf("item %q: %w", item.Name, err)
+			}
+			taxes += tax * qty
+		}
+	}
+	if len(unit.Items) > 0 && (items != parts["item_total"] || taxes != parts["tax_total"]) {
+		return fmt.Errorf("items do not add up to item_total/tax_total")
+	}
+	return nil
+}
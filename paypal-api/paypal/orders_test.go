@@ -0,0 +1,109 @@
+package paypal
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOrderLinksAndPayments(t *testing.T) {
+	raw := `{
+		"id": "5O190127TN364715T",
+		"status": "COMPLETED",
+		"links": [
+			{"href": "https://api.sandbox.paypal.com/v2/checkout/orders/5O190127TN364715T", "rel": "self", "method": "GET"},
+			{"href": "https://www.sandbox.paypal.com/checkoutnow?token=5O190127TN364715T", "rel": "payer-action", "method": "GET"}
+		],
+		"purchase_units": [
+			{"payments": {"captures": [{"id": "3C679366HH908993F", "status": "COMPLETED", "amount": {"currency_code": "EUR", "value": "28.00"}}]}},
+			{"payments": {"authorizations": [{"id": "0VF52814937998046", "status": "CREATED"}]}}
+		]
+	}`
+	var order Order
+	if err := json.Unmarshal([]byte(raw), &order); err != nil {
+		t.Fatal(err)
+	}
+
+	url, err := order.ApprovalURL()
+	if err != nil || url != "https://www.sandbox.paypal.com/checkoutnow?token=5O190127TN364715T" {
+		t.Errorf("ApprovalURL = %q, %v", url, err)
+	}
+	if captures := order.Captures(); len(captures) != 1 || captures[0].ID != "3C679366HH908993F" || captures[0].Amount.Value != "28.00" {
+		t.Errorf("Captures = %+v", captures)
+	}
+	if auths := order.Authorizations(); len(auths) != 1 || auths[0].ID != "0VF52814937998046" {
+		t.Errorf("Authorizations = %+v", auths)
+	}
+
+	if _, err := (&Order{ID: "NO-LINKS"}).ApprovalURL(); err == nil {
+		t.Error("ApprovalURL without an approve link should fail")
+	}
+}
+
+func TestClientDo(t *testing.T) {
+	var gotBody string
+	var gotHeaders http.Header
+	var tokenRequests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.URL.Path == "/v1/oauth2/token" {
+			tokenRequests++
+			if user, pass, ok := r.BasicAuth(); !ok || user != "client-id" || pass != "secret" || string(body) != "grant_type=client_credentials" {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"error": "invalid_client", "error_description": "Client Authentication failed"}`))
+				return
+			}
+			w.Write([]byte(`{"access_token": "token-1", "expires_in": 32400}`))
+			return
+		}
+		gotBody, gotHeaders = string(body), r.Header
+		if strings.HasSuffix(r.URL.Path, "/fail") {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write([]byte(`{"name": "UNPROCESSABLE_ENTITY"}`))
+			return
+		}
+		w.Write([]byte(`{"id": "ORDER-1", "status": "COMPLETED"}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient("client-id", "secret", srv.URL)
+	order, err := c.CaptureOrder("ORDER-1", "ORDER-1-capture-0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.ID != "ORDER-1" || order.Status != "COMPLETED" {
+		t.Errorf("decoded order = %+v", order)
+	}
+	// Capture gövdesiz gönderilmez, PayPal boş JSON nesnesi bekler
+	if gotBody != "{}" {
+		t.Errorf("body = %q, want {}", gotBody)
+	}
+	if gotHeaders.Get("Authorization") != "Bearer token-1" || gotHeaders.Get("PayPal-Request-Id") != "ORDER-1-capture-0" {
+		t.Errorf("headers = %v", gotHeaders)
+	}
+
+	err = c.do("POST", "/fail", OrderRequest{RequestID: "ignored", Intent: IntentCapture}, nil)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("error = %v, want a 422 APIError", err)
+	}
+	if !strings.Contains(gotBody, `"intent":"CAPTURE"`) || strings.Contains(gotBody, "ignored") {
+		t.Errorf("body = %q", gotBody)
+	}
+	// Request ID verilmeyen POST isteklerine rastgele ID üretilir
+	if id := gotHeaders.Get("PayPal-Request-Id"); id == "" || id == "ORDER-1-capture-0" {
+		t.Errorf("PayPal-Request-Id = %q, want a fresh ID", id)
+	}
+	if tokenRequests != 1 {
+		t.Errorf("token requested %d times, want 1", tokenRequests)
+	}
+
+	bad := NewClient("client-id", "wrong", srv.URL)
+	if _, err := bad.GetOrder("ORDER-1"); !errors.As(err, &apiErr) || apiErr.OAuthError != "invalid_client" {
+		t.Errorf("error with wrong secret = %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package paypal
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// PayoutPageSize batch detayları sayfalanırken kullanılan sayfa boyutu
+const PayoutPageSize = 100
+
+// PayoutAmount payouts API'sinin v1 tutar yapısı
+type PayoutAmount struct {
+	Value    string `json:"value"`
+	Currency string `json:"currency"`
+}
+
+// SenderBatchHeader batch başlığı. SenderBatchID tekrar gönderimde aynı batch'in iki kez ödenmesini engeller.
+type SenderBatchHeader struct {
+	SenderBatchID string `json:"sender_batch_id"`
+	EmailSubject  string `json:"email_subject,omitempty"`
+	EmailMessage  string `json:"email_message,omitempty"`
+}
+
+// PayoutItem batch içindeki tek bir ödeme
+type PayoutItem struct {
+	RecipientType string       `json:"recipient_type"`
+	Amount        PayoutAmount `json:"amount"`
+	Receiver      string       `json:"receiver"`
+	Note          string       `json:"note,omitempty"`
+	SenderItemID  string       `json:"sender_item_id,omitempty"`
+}
+
+// PayoutRequest /v1/payments/payouts isteği
+type PayoutRequest struct {
+	SenderBatchHeader SenderBatchHeader `json:"sender_batch_header"`
+	Items             []PayoutItem      `json:"items"`
+}
+
+// PayoutError payout item'ının hata bilgisi
+type PayoutError struct {
+	Name    string `json:"name"`
+	Message string `json:"message"`
+}
+
+// PayoutItemDetails PayPal'ın döndüğü payout item durumu
+type PayoutItemDetails struct {
+	PayoutItemID      string        `json:"payout_item_id"`
+	TransactionID     string        `json:"transaction_id,omitempty"`
+	TransactionStatus string        `json:"transaction_status"`
+	PayoutBatchID     string        `json:"payout_batch_id"`
+	PayoutItemFee     *PayoutAmount `json:"payout_item_fee,omitempty"`
+	PayoutItem        PayoutItem    `json:"payout_item"`
+	TimeProcessed     string        `json:"time_processed,omitempty"`
+	Errors            *PayoutError  `json:"errors,omitempty"`
+	Links             []Link        `json:"links,omitempty"`
+}
+
+// PayoutBatchHeader batch'in genel durumu
+type PayoutBatchHeader struct {
+	PayoutBatchID     string            `json:"payout_batch_id"`
+	BatchStatus       string            `json:"batch_status"`
+	TimeCreated       string            `json:"time_created,omitempty"`
+	TimeCompleted     string            `json:"time_completed,omitempty"`
+	SenderBatchHeader SenderBatchHeader `json:"sender_batch_header"`
+	Amount            *PayoutAmount     `json:"amount,omitempty"`
+	Fees              *PayoutAmount     `json:"fees,omitempty"`
+}
+
+// PayoutBatch /v1/payments/payouts yanıtı
+type PayoutBatch struct {
+	BatchHeader PayoutBatchHeader   `json:"batch_header"`
+	Items       []PayoutItemDetails `json:"items,omitempty"`
+	TotalItems  int                 `json:"total_items,omitempty"`
+	TotalPages  int                 `json:"total_pages,omitempty"`
+	Links       []Link              `json:"links,omitempty"`
+}
+
+// CreatePayoutBatch alıcılara toplu ödeme gönderir
+func (c *Client) CreatePayoutBatch(payout PayoutRequest) (*PayoutBatch, error) {
+	var result PayoutBatch
+	if err := c.do("POST", "/v1/payments/payouts", payout, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetPayoutBatch batch durumunu tüm sayfaları dolaşarak getirir
+func (c *Client) GetPayoutBatch(batchID string) (*PayoutBatch, error) {
+	var batch *PayoutBatch
+	for page := 1; ; page++ {
+		var result PayoutBatch
+		path := fmt.Sprintf("/v1/payments/payouts/%s?page=%d&page_size=%d&total_required=true",
+			url.PathEscape(batchID), page, PayoutPageSize)
+		if err := c.do("GET", path, nil, &result); err != nil {
+			return nil, err
+		}
+
+		if batch == nil {
+			batch = &result
+		} else {
+			batch.Items = append(batch.Items, result.Items...)
+		}
+		if len(result.Items) < PayoutPageSize || page >= result.TotalPages {
+			return batch, nil
+		}
+	}
+}
+
+// GetPayoutItem tek bir payout item'ının durumunu getirir
+func (c *Client) GetPayoutItem(payoutItemID string) (*PayoutItemDetails, error) {
+	var result PayoutItemDetails
+	if err := c.do("GET", "/v1/payments/payouts-item/"+url.PathEscape(payoutItemID), nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// CancelPayoutItem sahiplenilmemiş (UNCLAIMED) bir ödemeyi iptal eder, tutar hesaba geri döner
+func (c *Client) CancelPayoutItem(payoutItemID string) (*PayoutItemDetails, error) {
+	var result PayoutItemDetails
+	if err := c.do("POST", "/v1/payments/payouts-item/"+url.PathEscape(payoutItemID)+"/cancel", nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,37 @@
+package paypal
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetPayoutBatchPages(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/oauth2/token" {
+			fmt.Fprint(w, `{"access_token": "test-token", "expires_in": 32400}`)
+			return
+		}
+		page := r.URL.Query().Get("page")
+		count := PayoutPageSize
+		if page == "2" {
+			count = 1
+		}
+		batch := PayoutBatch{BatchHeader: PayoutBatchHeader{PayoutBatchID: "BATCH-1", BatchStatus: "SUCCESS"}, TotalPages: 2}
+		for i := 0; i < count; i++ {
+			batch.Items = append(batch.Items, PayoutItemDetails{PayoutItemID: fmt.Sprintf("ITEM-%s-%d", page, i)})
+		}
+		json.NewEncoder(w).Encode(batch)
+	}))
+	defer srv.Close()
+
+	batch, err := NewClient("client-id", "secret", srv.URL).GetPayoutBatch("BATCH-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch.Items) != PayoutPageSize+1 || batch.Items[PayoutPageSize].PayoutItemID != "ITEM-2-0" {
+		t.Errorf("got %d items", len(batch.Items))
+	}
+}
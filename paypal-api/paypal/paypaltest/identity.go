@@ -0,0 +1,289 @@
+package paypaltest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// authCode giriş sayfasının verdiği tek kullanımlık yetkilendirme kodu
+type authCode struct {
+	redirectURI   string
+	scope         string
+	codeChallenge string // PKCE, verilmişse token isteğinde code_verifier zorunludur
+	nonce         string // id_token'a aynen yazılır
+	expires       time.Time
+}
+
+// handleToken client_credentials, authorization_code ve refresh_token grant'lerini destekler
+func (f *Fake) handleToken(w http.ResponseWriter, r *http.Request) {
+	id, secret, ok := r.BasicAuth()
+	if !ok || !f.validClient(id, secret) {
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", "Client Authentication failed")
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Invalid form body")
+		return
+	}
+
+	switch r.PostForm.Get("grant_type") {
+	case "client_credentials":
+		token := f.issueToken(nil, "https://uri.paypal.com/services/payments/payment", "")
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"scope":        "https://uri.paypal.com/services/payments/payment",
+			"access_token": token,
+			"token_type":   "Bearer",
+			"app_id":       "APP-FAKE0000000000",
+			"expires_in":   int(f.TokenTTL.Seconds()),
+			"nonce":        now() + newID(""),
+		})
+
+	case "authorization_code":
+		f.mu.Lock()
+		code, ok := f.codes[r.PostForm.Get("code")]
+		delete(f.codes, r.PostForm.Get("code"))
+		f.mu.Unlock()
+		if !ok || time.Now().After(code.expires) {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "Invalid authorization code")
+			return
+		}
+		if code.redirectURI != r.PostForm.Get("redirect_uri") {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "redirect_uri mismatch")
+			return
+		}
+		if code.codeChallenge != "" && pkceChallenge(r.PostForm.Get("code_verifier")) != code.codeChallenge {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "code_verifier does not match code_challenge")
+			return
+		}
+		var idToken string
+		if strings.Contains(" "+code.scope+" ", " openid ") {
+			idToken = f.signIDToken(issuer(r, f.Issuer), id, code.nonce)
+		}
+		f.writeUserToken(w, code.scope, idToken)
+
+	case "refresh_token":
+		refresh := r.PostForm.Get("refresh_token")
+		f.mu.Lock()
+		scope, ok := f.refreshTokens[refresh]
+		f.mu.Unlock()
+		if !ok {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "Invalid refresh token")
+			return
+		}
+		user := f.User
+		token := f.issueToken(&user, scope, refresh)
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"scope":        scope,
+			"access_token": token,
+			"token_type":   "Bearer",
+			"expires_in":   int(f.TokenTTL.Seconds()),
+		})
+
+	default:
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "Grant type is not supported")
+	}
+}
+
+// writeUserToken kullanıcı adına access ve refresh token verir, idToken boş değilse yanıta eklenir
+func (f *Fake) writeUserToken(w http.ResponseWriter, scope, idToken string) {
+	user := f.User
+	refresh := newID("R")
+	token := f.issueToken(&user, scope, refresh)
+
+	f.mu.Lock()
+	f.refreshTokens[refresh] = scope
+	f.mu.Unlock()
+
+	resp := map[string]interface{}{
+		"scope":         scope,
+		"access_token":  token,
+		"refresh_token": refresh,
+		"token_type":    "Bearer",
+		"expires_in":    int(f.TokenTTL.Seconds()),
+	}
+	if idToken != "" {
+		resp["id_token"] = idToken
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// signIDToken oturumdaki kullanıcı için RS256 imzalı id_token üretir
+func (f *Fake) signIDToken(iss, audience, nonce string) string {
+	sub := f.User.Sub
+	if sub == "" {
+		sub = f.User.UserID
+	}
+	issued := time.Now()
+	claims := map[string]interface{}{
+		"iss":            iss,
+		"sub":            sub,
+		"aud":            audience,
+		"iat":            issued.Unix(),
+		"auth_time":      issued.Unix(),
+		"exp":            issued.Add(f.TokenTTL).Unix(),
+		"name":           f.User.Name,
+		"email":          f.User.Email,
+		"email_verified": f.User.EmailVerified,
+	}
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": f.keyID})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, f.signingKey, crypto.SHA256, digest[:])
+	if err != nil {
+		panic("paypaltest: sign id_token: " + err.Error())
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// handleCerts id_token imzasını doğrulamak için açık anahtarı JWKS olarak döner
+func (f *Fake) handleCerts(w http.ResponseWriter, r *http.Request) {
+	pub := f.signingKey.PublicKey
+	w.Header().Set("Cache-Control", "max-age=3600")
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": f.keyID,
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+// issuer verilmemişse sahte sunucunun adresi kullanılır, giriş sayfası da aynı adrestedir
+func issuer(r *http.Request, configured string) string {
+	if configured != "" {
+		return configured
+	}
+	if r.TLS != nil {
+		return "https://" + r.Host
+	}
+	return "http://" + r.Host
+}
+
+func (f *Fake) validClient(id, secret string) bool {
+	if f.ClientID == "" && f.Secret == "" {
+		return true
+	}
+	return subtle.ConstantTimeCompare([]byte(id), []byte(f.ClientID)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(secret), []byte(f.Secret)) == 1
+}
+
+func (f *Fake) issueToken(user *UserInfo, scope, refresh string) string {
+	token := newID("A21AA")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tokens[token] = accessToken{user: user, scope: scope, refresh: refresh, expires: time.Now().Add(f.TokenTTL)}
+	return token
+}
+
+// handleRevoke access veya refresh token'ı iptal eder. Refresh token iptal edilince onunla
+// alınmış access token'lar da geçersiz olur. Bilinmeyen token için de 200 döner (RFC 7009).
+func (f *Fake) handleRevoke(w http.ResponseWriter, r *http.Request) {
+	id, secret, ok := r.BasicAuth()
+	if !ok || !f.validClient(id, secret) {
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", "Client Authentication failed")
+		return
+	}
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("token") == "" {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "token is required")
+		return
+	}
+
+	token := r.PostForm.Get("token")
+	f.mu.Lock()
+	delete(f.tokens, token)
+	if _, ok := f.refreshTokens[token]; ok {
+		delete(f.refreshTokens, token)
+		for access, t := range f.tokens {
+			if t.refresh == token {
+				delete(f.tokens, access)
+			}
+		}
+	}
+	f.mu.Unlock()
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleUserInfo yalnızca kullanıcı adına verilmiş token'ları kabul eder
+func (f *Fake) handleUserInfo(w http.ResponseWriter, r *http.Request) {
+	token, ok := f.bearer(r)
+	if !ok || token.user == nil {
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_token", "The token passed in was not found in the system")
+		return
+	}
+	writeJSON(w, http.StatusOK, token.user)
+}
+
+/*
+	Log in with PayPal giriş sayfası. Kullanıcı hemen onay vermiş sayılır ve
+	redirect_uri'ye code ve state ile geri gönderilir:
+	GET /signin/authorize?client_id=...&response_type=code&scope=openid&redirect_uri=...&state=...
+	code_challenge verilirse (S256) token isteğinde eşleşen code_verifier beklenir.
+	scope openid içeriyorsa token yanıtında nonce'u taşıyan imzalı bir id_token döner,
+	açık anahtar GET /v1/oauth2/certs'tedir.
+*/
+
+func (f *Fake) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if f.ClientID != "" && q.Get("client_id") != f.ClientID {
+		http.Error(w, "unknown client_id", http.StatusBadRequest)
+		return
+	}
+	if q.Get("response_type") != "code" {
+		http.Error(w, "response_type must be code", http.StatusBadRequest)
+		return
+	}
+	if q.Get("code_challenge") != "" && q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "code_challenge_method must be S256", http.StatusBadRequest)
+		return
+	}
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || redirect.Scheme == "" || redirect.Host == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	code := newID("C21AA")
+	f.mu.Lock()
+	f.codes[code] = authCode{
+		redirectURI:   q.Get("redirect_uri"),
+		scope:         q.Get("scope"),
+		codeChallenge: q.Get("code_challenge"),
+		nonce:         q.Get("nonce"),
+		expires:       time.Now().Add(10 * time.Minute),
+	}
+	f.mu.Unlock()
+
+	params := redirect.Query()
+	params.Set("code", code)
+	params.Set("scope", q.Get("scope"))
+	if state := q.Get("state"); state != "" {
+		params.Set("state", state)
+	}
+	redirect.RawQuery = params.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+// pkceChallenge RFC 7636 S256: BASE64URL(SHA256(code_verifier))
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,307 @@
+package paypaltest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"paypal/paypal"
+)
+
+// order sahte v2 order'ı ve onay sonrası dönülecek adresler
+type order struct {
+	paypal.Order
+	returnURL string
+	cancelURL string
+}
+
+// capture iade edilebilir tutarı takip eden capture kaydı
+type capture struct {
+	paypal.Capture
+	currency string
+	amount   int64
+	refunded int64
+}
+
+// refund verilmiş iade
+type refund struct {
+	paypal.Refund
+}
+
+func (f *Fake) handleCreateOrder(w http.ResponseWriter, r *http.Request) {
+	var req paypal.OrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST", "Request is not well-formed, syntactically incorrect, or violates schema.", "MALFORMED_REQUEST_JSON")
+		return
+	}
+	if req.Intent != paypal.IntentCapture && req.Intent != paypal.IntentAuthorize {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST", "Request is not well-formed, syntactically incorrect, or violates schema.", "INVALID_PARAMETER_VALUE")
+		return
+	}
+	if len(req.PurchaseUnits) == 0 {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST", "Request is not well-formed, syntactically incorrect, or violates schema.", "MISSING_REQUIRED_PARAMETER")
+		return
+	}
+	for _, unit := range req.PurchaseUnits {
+		if err := unit.VerifyBreakdown(); err != nil {
+			writeError(w, http.StatusUnprocessableEntity, "UNPROCESSABLE_ENTITY", err.Error(), "AMOUNT_MISMATCH")
+			return
+		}
+	}
+
+	id := newID("")
+	o := &order{Order: paypal.Order{
+		ID:         id,
+		Status:     "CREATED",
+		Intent:     req.Intent,
+		CreateTime: now(),
+		Links: []paypal.Link{
+			{Href: baseURL(r) + "/v2/checkout/orders/" + id, Rel: "self", Method: "GET"},
+			{Href: baseURL(r) + "/checkoutnow?token=" + id, Rel: "approve", Method: "GET"},
+			{Href: baseURL(r) + "/v2/checkout/orders/" + id + "/capture", Rel: "capture", Method: "POST"},
+		},
+	}}
+	for _, unit := range req.PurchaseUnits {
+		amount := unit.Amount
+		o.PurchaseUnits = append(o.PurchaseUnits, paypal.PurchaseUnit{
+			ReferenceID: unit.ReferenceID,
+			Description: unit.Description,
+			CustomID:    unit.CustomID,
+			InvoiceID:   unit.InvoiceID,
+			Amount:      &amount,
+			Items:       unit.Items,
+		})
+	}
+	if req.ApplicationContext != nil {
+		o.returnURL = req.ApplicationContext.ReturnURL
+		o.cancelURL = req.ApplicationContext.CancelURL
+	}
+
+	f.mu.Lock()
+	f.orders[id] = o
+	resp := o.Order
+	f.mu.Unlock()
+
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+func (f *Fake) handleGetOrder(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	o, ok := f.orders[r.PathValue("id")]
+	var resp paypal.Order
+	if ok {
+		resp = o.Order
+	}
+	f.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "RESOURCE_NOT_FOUND", "The specified resource does not exist.", "INVALID_RESOURCE_ID")
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (f *Fake) handleCaptureOrder(w http.ResponseWriter, r *http.Request) {
+	f.completeOrder(w, r, paypal.IntentCapture)
+}
+
+func (f *Fake) handleAuthorizeOrder(w http.ResponseWriter, r *http.Request) {
+	f.completeOrder(w, r, paypal.IntentAuthorize)
+}
+
+// completeOrder onaylanmış order'ı intent'e göre capture veya authorize eder
+func (f *Fake) completeOrder(w http.ResponseWriter, r *http.Request, intent string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	o, ok := f.orders[r.PathValue("id")]
+	switch {
+	case !ok:
+		writeError(w, http.StatusNotFound, "RESOURCE_NOT_FOUND", "The specified resource does not exist.", "INVALID_RESOURCE_ID")
+		return
+	case o.Intent != intent:
+		writeError(w, http.StatusUnprocessableEntity, "UNPROCESSABLE_ENTITY", "The requested action could not be performed.", "ACTION_DOES_NOT_MATCH_INTENT")
+		return
+	case o.Status == "COMPLETED" && intent == paypal.IntentCapture:
+		writeError(w, http.StatusUnprocessableEntity, "UNPROCESSABLE_ENTITY", "The requested action could not be performed.", "ORDER_ALREADY_CAPTURED")
+		return
+	case o.Status == "COMPLETED":
+		writeError(w, http.StatusUnprocessableEntity, "UNPROCESSABLE_ENTITY", "The requested action could not be performed.", "ORDER_ALREADY_AUTHORIZED")
+		return
+	case o.Status != "APPROVED":
+		writeError(w, http.StatusUnprocessableEntity, "UNPROCESSABLE_ENTITY", "The requested action could not be performed.", "ORDER_NOT_APPROVED")
+		return
+	}
+
+	base := baseURL(r)
+	for i := range o.PurchaseUnits {
+		unit := &o.PurchaseUnits[i]
+		money := &paypal.Money{CurrencyCode: unit.Amount.CurrencyCode, Value: unit.Amount.Value}
+		if intent == paypal.IntentAuthorize {
+			unit.Payments = &paypal.PaymentCollection{Authorizations: []paypal.Authorization{{
+				ID:             newID(""),
+				Status:         "CREATED",
+				Amount:         money,
+				InvoiceID:      unit.InvoiceID,
+				CustomID:       unit.CustomID,
+				ExpirationTime: time.Now().Add(paypal.AuthorizationValidity).UTC().Format(time.RFC3339),
+				CreateTime:     now(),
+			}}}
+			continue
+		}
+
+		c := &capture{Capture: paypal.Capture{
+			ID:           newID(""),
+			Status:       "COMPLETED",
+			Amount:       money,
+			FinalCapture: true,
+			InvoiceID:    unit.InvoiceID,
+			CustomID:     unit.CustomID,
+			CreateTime:   now(),
+		}, currency: money.CurrencyCode}
+		c.amount, _ = paypal.ParseMinorUnits(money.Value, money.CurrencyCode)
+		c.Links = []paypal.Link{
+			{Href: base + "/v2/payments/captures/" + c.ID, Rel: "self", Method: "GET"},
+			{Href: base + "/v2/payments/captures/" + c.ID + "/refund", Rel: "refund", Method: "POST"},
+			{Href: base + "/v2/checkout/orders/" + o.ID, Rel: "up", Method: "GET"},
+		}
+		f.captures[c.ID] = c
+		unit.Payments = &paypal.PaymentCollection{Captures: []paypal.Capture{c.Capture}}
+	}
+	o.Status = "COMPLETED"
+	o.UpdateTime = now()
+
+	writeJSON(w, http.StatusCreated, o.Order)
+}
+
+func (f *Fake) handleRefundCapture(w http.ResponseWriter, r *http.Request) {
+	var req paypal.RefundRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST", "Request is not well-formed, syntactically incorrect, or violates schema.", "MALFORMED_REQUEST_JSON")
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, ok := f.captures[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "RESOURCE_NOT_FOUND", "The specified resource does not exist.", "INVALID_RESOURCE_ID")
+		return
+	}
+
+	amount := c.amount - c.refunded
+	if req.Amount != nil {
+		if req.Amount.CurrencyCode != c.currency {
+			writeError(w, http.StatusUnprocessableEntity, "UNPROCESSABLE_ENTITY", "The requested action could not be performed.", "REFUND_CURRENCY_MISMATCH")
+			return
+		}
+		var err error
+		if amount, err = paypal.ParseMinorUnits(req.Amount.Value, c.currency); err != nil {
+			writeError(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error(), "INVALID_PARAMETER_VALUE")
+			return
+		}
+	}
+	if amount <= 0 || amount > c.amount-c.refunded {
+		writeError(w, http.StatusUnprocessableEntity, "UNPROCESSABLE_ENTITY", "The requested action could not be performed.", "REFUND_AMOUNT_EXCEEDED")
+		return
+	}
+
+	c.refunded += amount
+	if c.refunded == c.amount {
+		c.Status = "REFUNDED"
+	} else {
+		c.Status = "PARTIALLY_REFUNDED"
+	}
+
+	money := paypal.NewMoney(amount, c.currency)
+	ref := &refund{Refund: paypal.Refund{
+		ID:          newID(""),
+		Status:      "COMPLETED",
+		Amount:      &money,
+		InvoiceID:   req.InvoiceID,
+		NoteToPayer: req.NoteToPayer,
+		CreateTime:  now(),
+		UpdateTime:  now(),
+	}}
+	ref.Links = []paypal.Link{
+		{Href: baseURL(r) + "/v2/payments/refunds/" + ref.ID, Rel: "self", Method: "GET"},
+		{Href: baseURL(r) + "/v2/payments/captures/" + c.ID, Rel: "up", Method: "GET"},
+	}
+	f.refunds[ref.ID] = ref
+
+	writeJSON(w, http.StatusCreated, ref.Refund)
+}
+
+func (f *Fake) handleGetRefund(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	ref, ok := f.refunds[r.PathValue("id")]
+	var resp paypal.Refund
+	if ok {
+		resp = ref.Refund
+	}
+	f.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "RESOURCE_NOT_FOUND", "The specified resource does not exist.", "INVALID_RESOURCE_ID")
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+/*
+	Onay sayfası. Alıcı hemen onay vermiş sayılır ve return_url'e yönlendirilir,
+	cancel=true verilirse iptal etmiş sayılır ve cancel_url'e yönlendirilir:
+	v2 order:   GET /checkoutnow?token=<ORDER_ID>  -> return_url?token=<ORDER_ID>&PayerID=<PAYER_ID>
+	v1 payment: GET /checkoutnow?token=<EC-TOKEN>  -> return_url?paymentId=<PAY-ID>&token=<EC-TOKEN>&PayerID=<PAYER_ID>
+*/
+
+func (f *Fake) handleApprove(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	cancel := r.URL.Query().Get("cancel") == "true"
+
+	f.mu.Lock()
+	var target string
+	params := url.Values{"token": {token}}
+	if o, ok := f.orders[token]; ok && o.Status == "CREATED" {
+		target = o.returnURL
+		if cancel {
+			target = o.cancelURL
+		} else {
+			o.Status = "APPROVED"
+			o.Payer = &paypal.Payer{PayerID: f.User.PayerID, EmailAddress: f.User.Email}
+			params.Set("PayerID", f.User.PayerID)
+		}
+	} else if p, ok := f.payments[f.paymentTokens[token]]; ok && p.State == "created" {
+		target = p.RedirectURLs.ReturnURL
+		params.Set("paymentId", p.ID)
+		if cancel {
+			target = p.RedirectURLs.CancelURL
+		} else {
+			p.approved = true
+			params.Set("PayerID", f.User.PayerID)
+		}
+	}
+	f.mu.Unlock()
+
+	if target == "" {
+		http.Error(w, "This checkout session has expired or is not valid", http.StatusNotFound)
+		return
+	}
+	http.Redirect(w, r, appendQuery(target, params), http.StatusFound)
+}
+
+// appendQuery URL'deki mevcut parametreleri koruyarak yenilerini ekler
+func appendQuery(rawURL string, params url.Values) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	q := u.Query()
+	for key, values := range params {
+		q[key] = values
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
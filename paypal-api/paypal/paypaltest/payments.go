@@ -0,0 +1,230 @@
+package paypaltest
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"paypal/paypal"
+)
+
+// v1Amount v1 payments API'sinin tutar yapısı
+type v1Amount struct {
+	Total    string `json:"total"`
+	Currency string `json:"currency"`
+}
+
+// v1Sale execute sonrası oluşan tahsilat
+type v1Sale struct {
+	ID            string   `json:"id"`
+	State         string   `json:"state"`
+	Amount        v1Amount `json:"amount"`
+	ParentPayment string   `json:"parent_payment"`
+	CreateTime    string   `json:"create_time"`
+}
+
+type v1RelatedResource struct {
+	Sale *v1Sale `json:"sale,omitempty"`
+}
+
+type v1Transaction struct {
+	Amount           v1Amount            `json:"amount"`
+	Description      string              `json:"description,omitempty"`
+	InvoiceNumber    string              `json:"invoice_number,omitempty"`
+	RelatedResources []v1RelatedResource `json:"related_resources,omitempty"`
+}
+
+type v1RedirectURLs struct {
+	ReturnURL string `json:"return_url"`
+	CancelURL string `json:"cancel_url"`
+}
+
+type v1PayerInfo struct {
+	PayerID string `json:"payer_id"`
+	Email   string `json:"email,omitempty"`
+}
+
+type v1Payer struct {
+	PaymentMethod string       `json:"payment_method"`
+	Status        string       `json:"status,omitempty"`
+	PayerInfo     *v1PayerInfo `json:"payer_info,omitempty"`
+}
+
+// v1Payment /v1/payments/payment isteği ve yanıtı
+type v1Payment struct {
+	ID           string          `json:"id,omitempty"`
+	Intent       string          `json:"intent"`
+	State        string          `json:"state,omitempty"`
+	Payer        v1Payer         `json:"payer"`
+	Transactions []v1Transaction `json:"transactions"`
+	RedirectURLs v1RedirectURLs  `json:"redirect_urls"`
+	CreateTime   string          `json:"create_time,omitempty"`
+	Links        []paypal.Link   `json:"links,omitempty"`
+}
+
+// payment onay sayfasında onaylanıp onaylanmadığı da tutulan v1 ödeme
+type payment struct {
+	v1Payment
+	approved bool
+}
+
+// sale iade edilebilir tutarı takip eden v1 tahsilat
+type sale struct {
+	v1Sale
+	amount   int64
+	refunded int64
+}
+
+func (f *Fake) handleCreatePayment(w http.ResponseWriter, r *http.Request) {
+	var req v1Payment
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "MALFORMED_REQUEST", "Incoming JSON request does not map to API request")
+		return
+	}
+	if req.Intent != "sale" || len(req.Transactions) == 0 || req.RedirectURLs.ReturnURL == "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request - see details")
+		return
+	}
+	for _, tx := range req.Transactions {
+		if _, err := paypal.ParseMinorUnits(tx.Amount.Total, tx.Amount.Currency); err != nil {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+			return
+		}
+	}
+
+	p := &payment{v1Payment: req}
+	p.ID = newID("PAYID-")
+	p.State = "created"
+	p.CreateTime = now()
+	ecToken := newID("EC-")
+	p.Links = []paypal.Link{
+		{Href: baseURL(r) + "/v1/payments/payment/" + p.ID, Rel: "self", Method: "GET"},
+		{Href: baseURL(r) + "/checkoutnow?token=" + ecToken, Rel: "approval_url", Method: "REDIRECT"},
+		{Href: baseURL(r) + "/v1/payments/payment/" + p.ID + "/execute", Rel: "execute", Method: "POST"},
+	}
+
+	f.mu.Lock()
+	f.payments[p.ID] = p
+	f.paymentTokens[ecToken] = p.ID
+	resp := p.v1Payment
+	f.mu.Unlock()
+
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+func (f *Fake) handleGetPayment(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	p, ok := f.payments[r.PathValue("id")]
+	var resp v1Payment
+	if ok {
+		resp = p.v1Payment
+	}
+	f.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "INVALID_RESOURCE_ID", "Requested resource ID was not found.")
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (f *Fake) handleExecutePayment(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PayerID string `json:"payer_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "MALFORMED_REQUEST", "Incoming JSON request does not map to API request")
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p, ok := f.payments[r.PathValue("id")]
+	switch {
+	case !ok:
+		writeError(w, http.StatusNotFound, "INVALID_RESOURCE_ID", "Requested resource ID was not found.")
+		return
+	case p.State == "approved":
+		writeError(w, http.StatusBadRequest, "PAYMENT_ALREADY_DONE", "Payment has been done already for this cart.")
+		return
+	case !p.approved:
+		writeError(w, http.StatusBadRequest, "PAYMENT_NOT_APPROVED_FOR_EXECUTION", "Payer has not approved payment")
+		return
+	case req.PayerID != f.User.PayerID:
+		writeError(w, http.StatusBadRequest, "INVALID_PAYER_ID", "Payer ID is invalid")
+		return
+	}
+
+	for i := range p.Transactions {
+		tx := &p.Transactions[i]
+		s := &sale{v1Sale: v1Sale{
+			ID:            newID(""),
+			State:         "completed",
+			Amount:        tx.Amount,
+			ParentPayment: p.ID,
+			CreateTime:    now(),
+		}}
+		s.amount, _ = paypal.ParseMinorUnits(tx.Amount.Total, tx.Amount.Currency)
+		f.sales[s.ID] = s
+		sold := s.v1Sale
+		tx.RelatedResources = []v1RelatedResource{{Sale: &sold}}
+	}
+	p.State = "approved"
+	p.Payer.Status = "VERIFIED"
+	p.Payer.PayerInfo = &v1PayerInfo{PayerID: f.User.PayerID, Email: f.User.Email}
+
+	writeJSON(w, http.StatusOK, p.v1Payment)
+}
+
+func (f *Fake) handleRefundSale(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Amount *v1Amount `json:"amount,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "MALFORMED_REQUEST", "Incoming JSON request does not map to API request")
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s, ok := f.sales[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "INVALID_RESOURCE_ID", "Requested resource ID was not found.")
+		return
+	}
+
+	currency := s.Amount.Currency
+	amount := s.amount - s.refunded
+	if req.Amount != nil {
+		var err error
+		if req.Amount.Currency != currency {
+			writeError(w, http.StatusBadRequest, "CURRENCY_MISMATCH", "Refund currency does not match the sale")
+			return
+		}
+		if amount, err = paypal.ParseMinorUnits(req.Amount.Total, currency); err != nil {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+			return
+		}
+	}
+	if amount <= 0 || amount > s.amount-s.refunded {
+		writeError(w, http.StatusBadRequest, "TRANSACTION_REFUSED", "The request was refused")
+		return
+	}
+
+	s.refunded += amount
+	if s.refunded == s.amount {
+		s.State = "refunded"
+	} else {
+		s.State = "partially_refunded"
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"id":             newID(""),
+		"state":          "completed",
+		"amount":         v1Amount{Total: paypal.FormatMinorUnits(amount, currency), Currency: currency},
+		"sale_id":        s.ID,
+		"parent_payment": s.ParentPayment,
+		"create_time":    now(),
+	})
+}
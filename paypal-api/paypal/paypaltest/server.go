@@ -0,0 +1,221 @@
+// Package paypaltest PayPal sandbox'ını taklit eden, ağ bağlantısı gerektirmeyen bir sahte sunucudur.
+// OAuth token, v2 orders, v1 payment create/execute, iade, identity userinfo ve JWKS endpoint'lerini,
+// ayrıca onay ve giriş sayfalarını sunar. Onay sayfası ödemeyi hemen onaylayıp kullanıcıyı
+// return_url'e paymentId/token/PayerID ile geri yönlendirir.
+//
+//	fake := paypaltest.NewServer("client-id", "secret")
+//	defer fake.Close()
+//	client := paypal.NewClient("client-id", "secret", fake.URL)
+//
+// Ayrı bir süreç olarak çalıştırmak için fakepaypal komutuna bakın.
+package paypaltest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTokenTTL sahte access token'ların varsayılan geçerlilik süresi
+const DefaultTokenTTL = time.Hour
+
+// UserInfo identity userinfo endpoint'inin döndüğü sahte kullanıcı
+type UserInfo struct {
+	UserID        string `json:"user_id"`
+	Sub           string `json:"sub"`
+	Name          string `json:"name"`
+	GivenName     string `json:"given_name"`
+	FamilyName    string `json:"family_name"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	PayerID       string `json:"payer_id"`
+}
+
+// DefaultUser onay ve giriş sayfalarında oturum açmış sayılan kullanıcı
+var DefaultUser = UserInfo{
+	UserID:        "https://www.paypal.com/webapps/auth/identity/user/FAKEUSER0000000000000000000",
+	Sub:           "https://www.paypal.com/webapps/auth/identity/user/FAKEUSER0000000000000000000",
+	Name:          "Test Buyer",
+	GivenName:     "Test",
+	FamilyName:    "Buyer",
+	Email:         "buyer@example.com",
+	EmailVerified: true,
+	PayerID:       "FAKEPAYER0001",
+}
+
+// accessToken verilen token ve sahibi. user boş ise client credentials token'ıdır.
+type accessToken struct {
+	user    *UserInfo
+	scope   string
+	refresh string // token bir refresh token ile alındıysa, o iptal edilince bu da geçersiz olur
+	expires time.Time
+}
+
+// Fake sahte PayPal API'si. http.Handler olarak doğrudan ListenAndServe ile de kullanılabilir.
+type Fake struct {
+	ClientID string
+	Secret   string
+	User     UserInfo
+	TokenTTL time.Duration // token yenileme akışlarını denemek için kısaltılabilir
+	// Issuer id_token'ların iss alanı, boşsa isteğin geldiği adres (örn. http://localhost:8081)
+	Issuer string
+
+	signingKey *rsa.PrivateKey
+	keyID      string
+
+	mux *http.ServeMux
+
+	mu            sync.Mutex
+	tokens        map[string]accessToken
+	refreshTokens map[string]string
+	codes         map[string]authCode
+	orders        map[string]*order
+	captures      map[string]*capture
+	refunds       map[string]*refund
+	payments      map[string]*payment
+	paymentTokens map[string]string
+	sales         map[string]*sale
+}
+
+// New sahte API'yi oluşturur. clientID ve secret boş ise her Basic auth kabul edilir.
+func New(clientID, secret string) *Fake {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic("paypaltest: generate signing key: " + err.Error())
+	}
+
+	f := &Fake{
+		ClientID:      clientID,
+		Secret:        secret,
+		User:          DefaultUser,
+		TokenTTL:      DefaultTokenTTL,
+		signingKey:    key,
+		keyID:         newID("KEY"),
+		mux:           http.NewServeMux(),
+		tokens:        make(map[string]accessToken),
+		refreshTokens: make(map[string]string),
+		codes:         make(map[string]authCode),
+		orders:        make(map[string]*order),
+		captures:      make(map[string]*capture),
+		refunds:       make(map[string]*refund),
+		payments:      make(map[string]*payment),
+		paymentTokens: make(map[string]string),
+		sales:         make(map[string]*sale),
+	}
+
+	f.mux.HandleFunc("POST /v1/oauth2/token", f.handleToken)
+	f.mux.HandleFunc("POST /v1/oauth2/revoke", f.handleRevoke)
+	f.mux.HandleFunc("GET /v1/oauth2/certs", f.handleCerts)
+	f.mux.HandleFunc("GET /v1/identity/openidconnect/userinfo", f.handleUserInfo)
+	f.mux.HandleFunc("GET /signin/authorize", f.handleAuthorize)
+	f.mux.HandleFunc("GET /checkoutnow", f.handleApprove)
+
+	f.mux.HandleFunc("POST /v2/checkout/orders", f.requireToken(f.handleCreateOrder))
+	f.mux.HandleFunc("GET /v2/checkout/orders/{id}", f.requireToken(f.handleGetOrder))
+	f.mux.HandleFunc("POST /v2/checkout/orders/{id}/capture", f.requireToken(f.handleCaptureOrder))
+	f.mux.HandleFunc("POST /v2/checkout/orders/{id}/authorize", f.requireToken(f.handleAuthorizeOrder))
+	f.mux.HandleFunc("POST /v2/payments/captures/{id}/refund", f.requireToken(f.handleRefundCapture))
+	f.mux.HandleFunc("GET /v2/payments/refunds/{id}", f.requireToken(f.handleGetRefund))
+
+	f.mux.HandleFunc("POST /v1/payments/payment", f.requireToken(f.handleCreatePayment))
+	f.mux.HandleFunc("GET /v1/payments/payment/{id}", f.requireToken(f.handleGetPayment))
+	f.mux.HandleFunc("POST /v1/payments/payment/{id}/execute", f.requireToken(f.handleExecutePayment))
+	f.mux.HandleFunc("POST /v1/payments/sale/{id}/refund", f.requireToken(f.handleRefundSale))
+	return f
+}
+
+// Server httptest sunucusu üzerinde çalışan sahte API
+type Server struct {
+	*Fake
+	*httptest.Server
+}
+
+// NewServer sahte API'yi rastgele bir yerel portta başlatır. Adresi URL alanındadır,
+// iş bitince Close çağrılmalıdır.
+func NewServer(clientID, secret string) *Server {
+	f := New(clientID, secret)
+	return &Server{Fake: f, Server: httptest.NewServer(f)}
+}
+
+func (f *Fake) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mux.ServeHTTP(w, r)
+}
+
+// requireToken Bearer token'ın bu sunucu tarafından verildiğini ve süresinin dolmadığını kontrol eder
+func (f *Fake) requireToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := f.bearer(r); !ok {
+			writeError(w, http.StatusUnauthorized, "AUTHENTICATION_FAILURE", "Authentication failed due to invalid authentication credentials or a missing Authorization header.")
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (f *Fake) bearer(r *http.Request) (accessToken, bool) {
+	value, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return accessToken{}, false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	token, ok := f.tokens[value]
+	if !ok || time.Now().After(token.expires) {
+		return accessToken{}, false
+	}
+	return token, true
+}
+
+// baseURL isteğin geldiği adres, onay linkleri bu adrese göre üretilir
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// newID PayPal ID'lerine benzeyen 17 karakterlik büyük harfli rastgele bir ID üretir
+func newID(prefix string) string {
+	b := make([]byte, 9)
+	rand.Read(b)
+	return prefix + strings.ToUpper(hex.EncodeToString(b))[:17]
+}
+
+func now() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError PayPal REST hata gövdesini yazar
+func writeError(w http.ResponseWriter, status int, name, message string, issues ...string) {
+	body := map[string]interface{}{
+		"name":     name,
+		"message":  message,
+		"debug_id": newID("")[:13],
+	}
+	if len(issues) > 0 {
+		details := make([]map[string]string, len(issues))
+		for i, issue := range issues {
+			details[i] = map[string]string{"issue": issue}
+		}
+		body["details"] = details
+	}
+	writeJSON(w, status, body)
+}
+
+// writeOAuthError OAuth endpoint'lerinin error/error_description gövdesini yazar
+func writeOAuthError(w http.ResponseWriter, status int, code, description string) {
+	writeJSON(w, status, map[string]string{"error": code, "error_description": description})
+}
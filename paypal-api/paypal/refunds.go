@@ -0,0 +1,58 @@
+package paypal
+
+import (
+	"net/url"
+	"strings"
+)
+
+// RefundRequest /v2/payments/captures/{id}/refund isteği. Amount boş ise tamamı iade edilir.
+type RefundRequest struct {
+	RequestID   string `json:"-"` // PayPal-Request-Id, bkz. OrderRequest.RequestID
+	Amount      *Money `json:"amount,omitempty"`
+	InvoiceID   string `json:"invoice_id,omitempty"`
+	NoteToPayer string `json:"note_to_payer,omitempty"`
+}
+
+// Refund PayPal iade yanıtı
+type Refund struct {
+	ID          string `json:"id"`
+	Status      string `json:"status"`
+	Amount      *Money `json:"amount,omitempty"`
+	InvoiceID   string `json:"invoice_id,omitempty"`
+	NoteToPayer string `json:"note_to_payer,omitempty"`
+	CreateTime  string `json:"create_time,omitempty"`
+	UpdateTime  string `json:"update_time,omitempty"`
+	Links       []Link `json:"links,omitempty"`
+}
+
+// CaptureID iadenin bağlı olduğu capture ID'sini "up" linkinden çıkarır
+func (r *Refund) CaptureID() string {
+	const prefix = "/v2/payments/captures/"
+	for _, link := range r.Links {
+		if link.Rel != "up" {
+			continue
+		}
+		if i := strings.Index(link.Href, prefix); i >= 0 {
+			return link.Href[i+len(prefix):]
+		}
+	}
+	return ""
+}
+
+// RefundCapture tahsil edilmiş bir capture'ı tamamen veya kısmen iade eder
+func (c *Client) RefundCapture(captureID string, refund RefundRequest) (*Refund, error) {
+	var result Refund
+	if err := c.doIdempotent("POST", "/v2/payments/captures/"+url.PathEscape(captureID)+"/refund", refund.RequestID, refund, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetRefund iade detaylarını getirir
+func (c *Client) GetRefund(refundID string) (*Refund, error) {
+	var result Refund
+	if err := c.do("GET", "/v2/payments/refunds/"+url.PathEscape(refundID), nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,18 @@
+package paypal
+
+import "testing"
+
+func TestRefundCaptureID(t *testing.T) {
+	refund := Refund{ID: "R1", Links: []Link{
+		{Href: "https://api-m.sandbox.paypal.com/v2/payments/refunds/R1", Rel: "self"},
+		{Href: "https://api-m.sandbox.paypal.com/v2/payments/captures/C1", Rel: "up"},
+	}}
+	if got := refund.CaptureID(); got != "C1" {
+		t.Errorf("CaptureID = %q, want C1", got)
+	}
+
+	refund.Links = refund.Links[:1]
+	if got := refund.CaptureID(); got != "" {
+		t.Errorf("CaptureID without up link = %q", got)
+	}
+}
@@ -0,0 +1,152 @@
+package paypal
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// settlementDateLayout STL raporlarındaki tarih biçimi, örn. 2024/01/15 10:22:33 -0800
+const settlementDateLayout = "2006/01/02 15:04:05 -0700"
+
+// SettlementEntry STL (settlement) raporundaki bir işlem satırı (SB). Tutarlar raporda olduğu
+// gibi en küçük birimdedir ve DR (borç) kayıtlarında eksi işaretlidir.
+type SettlementEntry struct {
+	TransactionID     string    `json:"transaction_id"`
+	InvoiceID         string    `json:"invoice_id,omitempty"`
+	ReferenceID       string    `json:"reference_id,omitempty"`
+	ReferenceIDType   string    `json:"reference_id_type,omitempty"`
+	EventCode         string    `json:"event_code"`
+	InitiatedAt       time.Time `json:"initiated_at"`
+	CompletedAt       time.Time `json:"completed_at,omitempty"`
+	Gross             int64     `json:"gross"`
+	Currency          string    `json:"currency"`
+	Fee               int64     `json:"fee"`
+	FeeCurrency       string    `json:"fee_currency,omitempty"`
+	CustomField       string    `json:"custom_field,omitempty"`
+	ConsumerID        string    `json:"consumer_id,omitempty"`
+	PaymentTrackingID string    `json:"payment_tracking_id,omitempty"`
+}
+
+// ParseSettlementReport PayPal'ın indirilebilir STL CSV raporunu okur. Rapor satırları ilk
+// sütundaki kayıt tipiyle ayrılır: CH sütun başlıklarını, SB işlem satırlarını taşır;
+// RH, FH, SH gibi başlık ve SF, SC, RF gibi özet satırları atlanır.
+func ParseSettlementReport(r io.Reader) ([]SettlementEntry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	var columns map[string]int
+	var entries []SettlementEntry
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("settlement report line %d: %w", line, err)
+		}
+		if len(record) == 0 {
+			continue
+		}
+
+		switch strings.TrimSpace(record[0]) {
+		case "CH":
+			columns = make(map[string]int, len(record))
+			for i, name := range record {
+				columns[normalizeColumn(name)] = i
+			}
+		case "SB":
+			if columns == nil {
+				return nil, fmt.Errorf("settlement report line %d: body row before column header", line)
+			}
+			entry, err := parseSettlementRow(record, columns)
+			if err != nil {
+				return nil, fmt.Errorf("settlement report line %d: %w", line, err)
+			}
+			entries = append(entries, entry)
+		}
+	}
+	if columns == nil {
+		return nil, fmt.Errorf("settlement report has no column header")
+	}
+	return entries, nil
+}
+
+func parseSettlementRow(record []string, columns map[string]int) (SettlementEntry, error) {
+	field := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	entry := SettlementEntry{
+		TransactionID:     field("transaction id"),
+		InvoiceID:         field("invoice id"),
+		ReferenceID:       field("paypal reference id"),
+		ReferenceIDType:   field("paypal reference id type"),
+		EventCode:         field("transaction event code"),
+		Currency:          field("gross transaction currency"),
+		FeeCurrency:       field("fee currency"),
+		CustomField:       field("custom field"),
+		ConsumerID:        field("consumer id"),
+		PaymentTrackingID: field("payment tracking id"),
+	}
+	if entry.TransactionID == "" {
+		return entry, fmt.Errorf("missing transaction id")
+	}
+
+	var err error
+	if entry.InitiatedAt, err = parseSettlementDate(field("transaction initiation date")); err != nil {
+		return entry, err
+	}
+	if entry.CompletedAt, err = parseSettlementDate(field("transaction completion date")); err != nil {
+		return entry, err
+	}
+	if entry.Gross, err = signedSettlementAmount(field("gross transaction amount"), field("transaction debit or credit")); err != nil {
+		return entry, fmt.Errorf("gross amount: %w", err)
+	}
+	if entry.Fee, err = signedSettlementAmount(field("fee amount"), field("fee debit or credit")); err != nil {
+		return entry, fmt.Errorf("fee amount: %w", err)
+	}
+	return entry, nil
+}
+
+// signedSettlementAmount en küçük birimdeki tutarı CR/DR işaretine göre pozitif/negatif yapar
+func signedSettlementAmount(value, direction string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	amount, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+	switch strings.ToUpper(direction) {
+	case "DR":
+		return -amount, nil
+	case "CR", "":
+		return amount, nil
+	}
+	return 0, fmt.Errorf("invalid debit/credit marker %q", direction)
+}
+
+func parseSettlementDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(settlementDateLayout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+	return t, nil
+}
+
+// normalizeColumn sütun adını küçük harfe çevirir ve fazla boşlukları siler;
+// raporlarda "Transaction  Debit or Credit" gibi çift boşluklu başlıklar bulunur
+func normalizeColumn(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
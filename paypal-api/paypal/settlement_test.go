@@ -0,0 +1,67 @@
+package paypal
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const sampleSettlementReport = `"RH","2024/01/16 04:00:00 -0800","A","SELLER123",009
+"FH",01
+"SH","2024/01/15 00:00:00 -0800","2024/01/15 23:59:59 -0800","SELLER123",""
+"CH","Transaction ID","Invoice ID","PayPal Reference ID","PayPal Reference ID Type","Transaction Event Code","Transaction Initiation Date","Transaction Completion Date","Transaction  Debit or Credit","Gross Transaction Amount","Gross Transaction Currency","Fee Debit or Credit","Fee Amount","Fee Currency","Custom Field","Consumer ID","Payment Tracking ID"
+"SB","3C679366HH908993F","order-1","","","T0006","2024/01/15 10:22:33 -0800","2024/01/15 10:22:35 -0800","CR","2800","EUR","DR","116","EUR","","buyer@example.com",""
+"SB","1JU08902781691411","","3C679366HH908993F","TXN","T1107","2024/01/15 12:00:00 -0800","","DR","500","EUR","CR","15","EUR","","",""
+"SF","EUR","CR",2800,"DR",500,"DR",116,"CR",15,2
+"SC","EUR","CR",2800,"DR",500,"DR",116,"CR",15,2
+"RF",2
+`
+
+func TestParseSettlementReport(t *testing.T) {
+	entries, err := ParseSettlementReport(strings.NewReader(sampleSettlementReport))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+
+	capture := entries[0]
+	if capture.TransactionID != "3C679366HH908993F" || capture.InvoiceID != "order-1" || capture.EventCode != "T0006" ||
+		capture.Gross != 2800 || capture.Fee != -116 || capture.Currency != "EUR" {
+		t.Errorf("capture = %+v", capture)
+	}
+	want := time.Date(2024, 1, 15, 18, 22, 33, 0, time.UTC)
+	if !capture.InitiatedAt.Equal(want) {
+		t.Errorf("InitiatedAt = %v, want %v", capture.InitiatedAt, want)
+	}
+
+	refund := entries[1]
+	if refund.ReferenceID != "3C679366HH908993F" || refund.Gross != -500 || refund.Fee != 15 || !refund.CompletedAt.IsZero() {
+		t.Errorf("refund = %+v", refund)
+	}
+}
+
+func TestParseSettlementReportErrors(t *testing.T) {
+	header := `"CH","Transaction ID","Transaction Initiation Date","Transaction  Debit or Credit","Gross Transaction Amount"` + "\n"
+	tests := []struct {
+		name   string
+		report string
+		want   string
+	}{
+		{"no header", `"RH","2024/01/16 04:00:00 -0800"` + "\n", "no column header"},
+		{"body before header", `"SB","TX1"` + "\n" + header, "body row before column header"},
+		{"missing transaction id", header + `"SB","","2024/01/15 10:22:33 -0800","CR","100"` + "\n", "missing transaction id"},
+		{"bad date", header + `"SB","TX1","15.01.2024","CR","100"` + "\n", "invalid date"},
+		{"bad amount", header + `"SB","TX1","2024/01/15 10:22:33 -0800","CR","1.00"` + "\n", "invalid amount"},
+		{"bad marker", header + `"SB","TX1","2024/01/15 10:22:33 -0800","XX","100"` + "\n", "invalid debit/credit marker"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseSettlementReport(strings.NewReader(tt.report))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package paypal
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// Product /v1/catalogs/products kaydı
+type Product struct {
+	ID          string `json:"id,omitempty"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Type        string `json:"type"`
+	Category    string `json:"category,omitempty"`
+	CreateTime  string `json:"create_time,omitempty"`
+	Links       []Link `json:"links,omitempty"`
+}
+
+// Frequency faturalama periyodu, örn. her 1 MONTH
+type Frequency struct {
+	IntervalUnit  string `json:"interval_unit"`
+	IntervalCount int    `json:"interval_count"`
+}
+
+// PricingScheme bir faturalama döngüsünün fiyatı
+type PricingScheme struct {
+	FixedPrice Money `json:"fixed_price"`
+}
+
+// BillingCycle planın faturalama döngüsü. TotalCycles 0 ise süresiz devam eder.
+type BillingCycle struct {
+	Frequency     Frequency     `json:"frequency"`
+	TenureType    string        `json:"tenure_type"`
+	Sequence      int           `json:"sequence"`
+	TotalCycles   int           `json:"total_cycles"`
+	PricingScheme PricingScheme `json:"pricing_scheme"`
+}
+
+// PaymentPreferences başarısız tahsilatlarda planın davranışı
+type PaymentPreferences struct {
+	AutoBillOutstanding     bool   `json:"auto_bill_outstanding"`
+	SetupFeeFailureAction   string `json:"setup_fee_failure_action,omitempty"`
+	PaymentFailureThreshold int    `json:"payment_failure_threshold"`
+}
+
+// Plan /v1/billing/plans kaydı
+type Plan struct {
+	ID                 string              `json:"id,omitempty"`
+	ProductID          string              `json:"product_id"`
+	Name               string              `json:"name"`
+	Description        string              `json:"description,omitempty"`
+	Status             string              `json:"status,omitempty"`
+	BillingCycles      []BillingCycle      `json:"billing_cycles"`
+	PaymentPreferences *PaymentPreferences `json:"payment_preferences,omitempty"`
+	CreateTime         string              `json:"create_time,omitempty"`
+	Links              []Link              `json:"links,omitempty"`
+}
+
+// Subscriber abone bilgisi
+type Subscriber struct {
+	EmailAddress string     `json:"email_address,omitempty"`
+	Name         *PayerName `json:"name,omitempty"`
+	PayerID      string     `json:"payer_id,omitempty"`
+}
+
+// SubscriptionRequest /v1/billing/subscriptions isteği. CustomID yerel müşteri ID'sidir.
+type SubscriptionRequest struct {
+	PlanID             string              `json:"plan_id"`
+	CustomID           string              `json:"custom_id,omitempty"`
+	Subscriber         *Subscriber         `json:"subscriber,omitempty"`
+	ApplicationContext *ApplicationContext `json:"application_context,omitempty"`
+}
+
+// LastPayment aboneliğin son başarılı tahsilatı
+type LastPayment struct {
+	Amount Money  `json:"amount"`
+	Time   string `json:"time"`
+}
+
+// BillingInfo aboneliğin faturalama durumu
+type BillingInfo struct {
+	NextBillingTime     string       `json:"next_billing_time,omitempty"`
+	LastPayment         *LastPayment `json:"last_payment,omitempty"`
+	FailedPaymentsCount int          `json:"failed_payments_count"`
+	OutstandingBalance  *Money       `json:"outstanding_balance,omitempty"`
+}
+
+// Subscription PayPal abonelik kaydı
+type Subscription struct {
+	ID          string       `json:"id"`
+	Status      string       `json:"status"`
+	PlanID      string       `json:"plan_id"`
+	CustomID    string       `json:"custom_id,omitempty"`
+	StartTime   string       `json:"start_time,omitempty"`
+	Subscriber  *Subscriber  `json:"subscriber,omitempty"`
+	BillingInfo *BillingInfo `json:"billing_info,omitempty"`
+	CreateTime  string       `json:"create_time,omitempty"`
+	UpdateTime  string       `json:"update_time,omitempty"`
+	Links       []Link       `json:"links,omitempty"`
+}
+
+// ApprovalURL abonenin yönlendirileceği PayPal onay linkini döner
+func (s *Subscription) ApprovalURL() (string, error) {
+	for _, link := range s.Links {
+		if link.Rel == "approve" {
+			return link.Href, nil
+		}
+	}
+	return "", fmt.Errorf("approve link not found for subscription %s", s.ID)
+}
+
+// CreateProduct katalogda yeni bir ürün oluşturur
+func (c *Client) CreateProduct(product Product) (*Product, error) {
+	var result Product
+	if err := c.do("POST", "/v1/catalogs/products", product, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// CreatePlan ürün için bir faturalama planı oluşturur
+func (c *Client) CreatePlan(plan Plan) (*Plan, error) {
+	var result Plan
+	if err := c.do("POST", "/v1/billing/plans", plan, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetPlan plan detaylarını getirir
+func (c *Client) GetPlan(planID string) (*Plan, error) {
+	var result Plan
+	if err := c.do("GET", "/v1/billing/plans/"+url.PathEscape(planID), nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// SetPlanActive planı yeni aboneliklere açar veya kapatır
+func (c *Client) SetPlanActive(planID string, active bool) error {
+	action := "deactivate"
+	if active {
+		action = "activate"
+	}
+	return c.do("POST", "/v1/billing/plans/"+url.PathEscape(planID)+"/"+action, nil, nil)
+}
+
+// CreateSubscription yeni bir abonelik oluşturur, abone onay linkine yönlendirilmelidir
+func (c *Client) CreateSubscription(subscription SubscriptionRequest) (*Subscription, error) {
+	var result Subscription
+	if err := c.do("POST", "/v1/billing/subscriptions", subscription, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetSubscription abonelik detaylarını getirir
+func (c *Client) GetSubscription(subscriptionID string) (*Subscription, error) {
+	var result Subscription
+	if err := c.do("GET", "/v1/billing/subscriptions/"+url.PathEscape(subscriptionID), nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ChangeSubscriptionStatus aboneliği activate, suspend veya cancel eder
+func (c *Client) ChangeSubscriptionStatus(subscriptionID, action, reason string) error {
+	switch action {
+	case "activate", "suspend", "cancel":
+	default:
+		return fmt.Errorf("unknown subscription action %q", action)
+	}
+
+	path := "/v1/billing/subscriptions/" + url.PathEscape(subscriptionID) + "/" + action
+	return c.do("POST", path, map[string]string{"reason": reason}, nil)
+}
@@ -0,0 +1,129 @@
+package paypal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// tokenExpiryMargin token süresi dolmadan bu kadar önce yenilenir
+const tokenExpiryMargin = 60 * time.Second
+
+// accessTokenResponse /v1/oauth2/token yanıtı
+type accessTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	AppID       string `json:"app_id"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Nonce       string `json:"nonce"`
+}
+
+// tokenCall devam eden bir token isteği; aynı anda gelen çağrılar sonucu bekler
+type tokenCall struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+// tokenSource client credentials token'ını önbellekte tutar ve süresi dolmadan
+// yeniler. Eşzamanlı çağrılarda PayPal'a yalnızca tek bir istek gider.
+type tokenSource struct {
+	fetch func() (*accessTokenResponse, error)
+
+	mu       sync.Mutex
+	token    string
+	expiry   time.Time
+	inflight *tokenCall
+}
+
+// Token geçerli bir access token döner, gerekirse yeniler
+func (s *tokenSource) Token() (string, error) {
+	s.mu.Lock()
+	if s.token != "" && time.Now().Before(s.expiry) {
+		token := s.token
+		s.mu.Unlock()
+		return token, nil
+	}
+
+	if call := s.inflight; call != nil {
+		s.mu.Unlock()
+		<-call.done
+		return call.token, call.err
+	}
+
+	call := &tokenCall{done: make(chan struct{})}
+	s.inflight = call
+	s.mu.Unlock()
+
+	resp, err := s.fetch()
+
+	s.mu.Lock()
+	if err == nil {
+		s.token = resp.AccessToken
+		s.expiry = tokenExpiry(time.Now(), resp.ExpiresIn)
+		call.token = resp.AccessToken
+	}
+	call.err = err
+	s.inflight = nil
+	s.mu.Unlock()
+
+	close(call.done)
+	return call.token, call.err
+}
+
+// tokenExpiry token'ın önbellekten düşeceği zamanı hesaplar. Kısa ömürlü token'larda margin
+// süreyi aşmasın diye en fazla sürenin yarısı kadar erken yenilenir.
+func tokenExpiry(now time.Time, expiresIn int64) time.Time {
+	ttl := time.Duration(expiresIn) * time.Second
+	return now.Add(ttl - min(tokenExpiryMargin, ttl/2))
+}
+
+// Invalidate önbellekteki token'ı siler, bir sonraki çağrıda yeni token alınır
+func (s *tokenSource) Invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = ""
+	s.expiry = time.Time{}
+}
+
+// fetchAccessToken PayPal'dan yeni bir client credentials token'ı alır
+func (c *Client) fetchAccessToken() (*accessTokenResponse, error) {
+	url := c.BaseURL + "/v1/oauth2/token"
+	reqBody := []byte("grant_type=client_credentials")
+
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return nil, err
+	}
+
+	req.SetBasicAuth(c.ClientID, c.Secret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp.StatusCode, body)
+	}
+
+	var result accessTokenResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("decode token response: %w", err)
+	}
+	if result.AccessToken == "" {
+		return nil, fmt.Errorf("could not get access token")
+	}
+
+	return &result, nil
+}
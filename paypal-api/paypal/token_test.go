@@ -0,0 +1,102 @@
+package paypal
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTokenSourceCachesToken(t *testing.T) {
+	var fetches int32
+	s := &tokenSource{fetch: func() (*accessTokenResponse, error) {
+		n := atomic.AddInt32(&fetches, 1)
+		return &accessTokenResponse{AccessToken: fmt.Sprintf("token-%d", n), ExpiresIn: 32400}, nil
+	}}
+
+	for i := 0; i < 3; i++ {
+		if token, err := s.Token(); err != nil || token != "token-1" {
+			t.Fatalf("Token = %q, %v", token, err)
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("fetched %d times, want 1", fetches)
+	}
+
+	s.Invalidate()
+	if token, err := s.Token(); err != nil || token != "token-2" {
+		t.Errorf("Token after Invalidate = %q, %v", token, err)
+	}
+}
+
+func TestTokenSourceSingleFlight(t *testing.T) {
+	var fetches int32
+	release := make(chan struct{})
+	s := &tokenSource{fetch: func() (*accessTokenResponse, error) {
+		atomic.AddInt32(&fetches, 1)
+		<-release
+		return &accessTokenResponse{AccessToken: "shared", ExpiresIn: 32400}, nil
+	}}
+
+	var wg sync.WaitGroup
+	tokens := make([]string, 10)
+	for i := range tokens {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tokens[i], _ = s.Token()
+		}(i)
+	}
+	// Tüm çağrılar ilk isteği beklesin
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if fetches != 1 {
+		t.Errorf("concurrent calls fetched %d times, want 1", fetches)
+	}
+	for i, token := range tokens {
+		if token != "shared" {
+			t.Errorf("call %d got %q", i, token)
+		}
+	}
+}
+
+func TestTokenSourceDoesNotCacheErrors(t *testing.T) {
+	fail := true
+	s := &tokenSource{fetch: func() (*accessTokenResponse, error) {
+		if fail {
+			return nil, errors.New("unavailable")
+		}
+		return &accessTokenResponse{AccessToken: "ok", ExpiresIn: 32400}, nil
+	}}
+
+	if _, err := s.Token(); err == nil {
+		t.Fatal("expected an error")
+	}
+	fail = false
+	if token, err := s.Token(); err != nil || token != "ok" {
+		t.Errorf("Token after a failed fetch = %q, %v", token, err)
+	}
+}
+
+func TestTokenExpiry(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		expiresIn int64
+		want      time.Duration
+	}{
+		{32400, 32400*time.Second - tokenExpiryMargin},
+		{120, 60 * time.Second},
+		{60, 30 * time.Second},
+		{10, 5 * time.Second},
+		{0, 0},
+	}
+	for _, tt := range tests {
+		if got := tokenExpiry(now, tt.expiresIn).Sub(now); got != tt.want {
+			t.Errorf("tokenExpiry(expires_in=%d) = now+%v, want now+%v", tt.expiresIn, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package paypal
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Transaction Search API sınırları: tek istekte en fazla 31 günlük aralık ve 500 kayıtlık sayfa
+const (
+	TransactionSearchMaxRange = 31 * 24 * time.Hour
+	TransactionPageSize       = 500
+)
+
+// TransactionAmount raporlardaki tutar, gider kayıtlarında değer eksi işaretlidir
+type TransactionAmount struct {
+	CurrencyCode string `json:"currency_code"`
+	Value        string `json:"value"`
+}
+
+// TransactionInfo bir işlemin temel bilgileri
+type TransactionInfo struct {
+	TransactionID             string             `json:"transaction_id"`
+	PayPalReferenceID         string             `json:"paypal_reference_id,omitempty"`
+	PayPalReferenceIDType     string             `json:"paypal_reference_id_type,omitempty"`
+	TransactionEventCode      string             `json:"transaction_event_code"`
+	TransactionInitiationDate string             `json:"transaction_initiation_date"`
+	TransactionUpdatedDate    string             `json:"transaction_updated_date,omitempty"`
+	TransactionAmount         *TransactionAmount `json:"transaction_amount,omitempty"`
+	FeeAmount                 *TransactionAmount `json:"fee_amount,omitempty"`
+	TransactionStatus         string             `json:"transaction_status"`
+	InvoiceID                 string             `json:"invoice_id,omitempty"`
+	CustomField               string             `json:"custom_field,omitempty"`
+}
+
+// TransactionPayerInfo işlemi yapan hesap
+type TransactionPayerInfo struct {
+	AccountID    string `json:"account_id,omitempty"`
+	EmailAddress string `json:"email_address,omitempty"`
+}
+
+// TransactionDetail arama sonucundaki tek bir işlem
+type TransactionDetail struct {
+	TransactionInfo TransactionInfo       `json:"transaction_info"`
+	PayerInfo       *TransactionPayerInfo `json:"payer_info,omitempty"`
+}
+
+// TransactionSearchResponse /v1/reporting/transactions yanıtının bir sayfası
+type TransactionSearchResponse struct {
+	TransactionDetails []TransactionDetail `json:"transaction_details"`
+	AccountNumber      string              `json:"account_number,omitempty"`
+	StartDate          string              `json:"start_date"`
+	EndDate            string              `json:"end_date"`
+	Page               int                 `json:"page"`
+	TotalItems         int                 `json:"total_items"`
+	TotalPages         int                 `json:"total_pages"`
+}
+
+// SearchTransactions [start, end) aralığındaki işlemleri getirir. Aralık 31 günden uzunsa
+// parçalara bölünür, her parçanın tüm sayfaları dolaşılır.
+func (c *Client) SearchTransactions(start, end time.Time) ([]TransactionDetail, error) {
+	if !end.After(start) {
+		return nil, fmt.Errorf("end date must be after start date")
+	}
+
+	var details []TransactionDetail
+	for from := start; from.Before(end); from = from.Add(TransactionSearchMaxRange) {
+		to := from.Add(TransactionSearchMaxRange)
+		if to.After(end) {
+			to = end
+		}
+		for page := 1; ; page++ {
+			result, err := c.searchTransactionsPage(from, to, page)
+			if err != nil {
+				return nil, err
+			}
+			details = append(details, result.TransactionDetails...)
+			if page >= result.TotalPages {
+				break
+			}
+		}
+	}
+	return details, nil
+}
+
+func (c *Client) searchTransactionsPage(start, end time.Time, page int) (*TransactionSearchResponse, error) {
+	q := url.Values{}
+	q.Set("start_date", start.UTC().Format(time.RFC3339))
+	q.Set("end_date", end.UTC().Format(time.RFC3339))
+	q.Set("fields", "transaction_info,payer_info")
+	q.Set("page_size", fmt.Sprint(TransactionPageSize))
+	q.Set("page", fmt.Sprint(page))
+
+	var result TransactionSearchResponse
+	if err := c.do("GET", "/v1/reporting/transactions?"+q.Encode(), nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
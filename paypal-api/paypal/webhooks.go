@@ -0,0 +1,100 @@
+package paypal
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// WebhookEvent PayPal webhook olayı. Resource olay tipine göre çözülür.
+type WebhookEvent struct {
+	ID           string          `json:"id"`
+	EventType    string          `json:"event_type"`
+	ResourceType string          `json:"resource_type"`
+	Summary      string          `json:"summary"`
+	CreateTime   string          `json:"create_time"`
+	Resource     json.RawMessage `json:"resource"`
+}
+
+// verifyWebhookSignatureRequest /v1/notifications/verify-webhook-signature isteği
+type verifyWebhookSignatureRequest struct {
+	AuthAlgo         string          `json:"auth_algo"`
+	CertURL          string          `json:"cert_url"`
+	TransmissionID   string          `json:"transmission_id"`
+	TransmissionSig  string          `json:"transmission_sig"`
+	TransmissionTime string          `json:"transmission_time"`
+	WebhookID        string          `json:"webhook_id"`
+	WebhookEvent     json.RawMessage `json:"webhook_event"`
+}
+
+// verifyWebhookSignatureResponse doğrulama yanıtı, SUCCESS veya FAILURE
+type verifyWebhookSignatureResponse struct {
+	VerificationStatus string `json:"verification_status"`
+}
+
+// WebhookCapture PAYMENT.CAPTURE.* olaylarının resource alanı
+type WebhookCapture struct {
+	ID                string `json:"id"`
+	Status            string `json:"status"`
+	Amount            *Money `json:"amount,omitempty"`
+	InvoiceID         string `json:"invoice_id,omitempty"`
+	FinalCapture      bool   `json:"final_capture,omitempty"`
+	SupplementaryData struct {
+		RelatedIDs struct {
+			OrderID string `json:"order_id"`
+		} `json:"related_ids"`
+	} `json:"supplementary_data"`
+	Links []Link `json:"links,omitempty"`
+}
+
+// WebhookDispute CUSTOMER.DISPUTE.* olaylarının resource alanı
+type WebhookDispute struct {
+	DisputeID            string `json:"dispute_id"`
+	Status               string `json:"status"`
+	Reason               string `json:"reason"`
+	DisputedTransactions []struct {
+		SellerTransactionID string `json:"seller_transaction_id"`
+	} `json:"disputed_transactions"`
+}
+
+// WebhookSale aboneliklerde her dönemde gelen PAYMENT.SALE.COMPLETED resource'u (v1 formatı)
+type WebhookSale struct {
+	ID                 string `json:"id"`
+	State              string `json:"state"`
+	BillingAgreementID string `json:"billing_agreement_id"`
+	CreateTime         string `json:"create_time"`
+	Amount             struct {
+		Total    string `json:"total"`
+		Currency string `json:"currency"`
+	} `json:"amount"`
+}
+
+// VerifyWebhookSignature olayın gerçekten PayPal'dan geldiğini PayPal'ın doğrulama API'si ile kontrol eder.
+// body webhook isteğinin ham gövdesidir, yeniden serialize edilirse imza tutmaz.
+func (c *Client) VerifyWebhookSignature(webhookID string, header http.Header, body []byte) error {
+	if webhookID == "" {
+		return fmt.Errorf("webhook ID is not configured")
+	}
+
+	req := verifyWebhookSignatureRequest{
+		AuthAlgo:         header.Get("PAYPAL-AUTH-ALGO"),
+		CertURL:          header.Get("PAYPAL-CERT-URL"),
+		TransmissionID:   header.Get("PAYPAL-TRANSMISSION-ID"),
+		TransmissionSig:  header.Get("PAYPAL-TRANSMISSION-SIG"),
+		TransmissionTime: header.Get("PAYPAL-TRANSMISSION-TIME"),
+		WebhookID:        webhookID,
+		WebhookEvent:     body,
+	}
+	if req.TransmissionID == "" || req.TransmissionSig == "" || req.CertURL == "" {
+		return fmt.Errorf("missing PayPal transmission headers")
+	}
+
+	var result verifyWebhookSignatureResponse
+	if err := c.do("POST", "/v1/notifications/verify-webhook-signature", req, &result); err != nil {
+		return err
+	}
+	if result.VerificationStatus != "SUCCESS" {
+		return fmt.Errorf("webhook signature verification status: %s", result.VerificationStatus)
+	}
+	return nil
+}
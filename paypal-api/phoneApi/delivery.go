@@ -0,0 +1,469 @@
+package phoneapi
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Teslim durumları. Gateway ve istemci aynı değerleri kullanır.
+const (
+	StatusQueued    = "queued"
+	StatusSending   = "sending"
+	StatusSent      = "sent"      // operatöre iletildi
+	StatusDelivered = "delivered" // teslim raporu geldi
+	StatusFailed    = "failed"
+)
+
+var statusRank = map[string]int{
+	StatusQueued:    1,
+	StatusSending:   2,
+	StatusSent:      3,
+	StatusDelivered: 4,
+	StatusFailed:    4,
+}
+
+// StatusAdvances next durumunun current'tan sonra geldiğini döner. Sırası karışık gelen
+// callback'ler teslim edilmiş bir mesajı tekrar "sent" durumuna çekmez.
+func StatusAdvances(current, next string) bool {
+	return statusRank[next] > statusRank[current]
+}
+
+// ValidStatus bilinen bir teslim durumu olup olmadığını döner
+func ValidStatus(status string) bool {
+	_, ok := statusRank[status]
+	return ok
+}
+
+var (
+	ErrDeliveryNotFound = errors.New("message not found")
+	// ErrReceiptPending mesajın kaydı henüz yok, rapor kayıt oluşunca işlenmek üzere bekletildi
+	ErrReceiptPending = errors.New("message is not recorded yet, the receipt is held until it is")
+)
+
+// PendingReceiptTTL kaydı henüz oluşmamış mesajların raporlarının bekletildiği süre
+const PendingReceiptTTL = 5 * time.Minute
+
+// maxPendingReceipts bekletilen en fazla rapor sayısı
+const maxPendingReceipts = 1000
+
+// expireInterval saklama süresi dolan kayıtların silinme sıklığı
+const expireInterval = time.Hour
+
+// Receipt gateway'in callback_url'e gönderdiği ve sağlayıcıların teslim raporu için kullandığı gövde
+type Receipt struct {
+	MessageID string    `json:"message_id"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PendingReceipts kaydı henüz oluşmamış mesajların raporlarını PendingReceiptTTL boyunca tutar.
+// Gönderim yanıtı ile mesaj ID'si kaydedilmeden önce gelen rapor böylece kaybolmaz. Sıfır değeri
+// kullanılabilir; kendi kilidi yoktur, kullanan yapının kilidi altında çağrılır.
+type PendingReceipts struct {
+	receipts map[string][]heldReceipt
+	count    int
+}
+
+type heldReceipt struct {
+	receipt Receipt
+	heldAt  time.Time
+}
+
+// Hold raporu saklar. Sınıra ulaşıldıysa süresi dolanlar silinir, yine yer yoksa false döner.
+func (p *PendingReceipts) Hold(r Receipt, now time.Time) bool {
+	if p.receipts == nil {
+		p.receipts = make(map[string][]heldReceipt)
+	}
+	if p.count >= maxPendingReceipts {
+		p.expire(now)
+		if p.count >= maxPendingReceipts {
+			return false
+		}
+	}
+	p.receipts[r.MessageID] = append(p.receipts[r.MessageID], heldReceipt{receipt: r, heldAt: now})
+	p.count++
+	return true
+}
+
+// Take id'ye ait bekleyen raporları geliş sırasıyla döner ve siler
+func (p *PendingReceipts) Take(id string, now time.Time) []Receipt {
+	held, ok := p.receipts[id]
+	if !ok {
+		return nil
+	}
+	delete(p.receipts, id)
+	p.count -= len(held)
+	var receipts []Receipt
+	for _, h := range held {
+		if now.Sub(h.heldAt) <= PendingReceiptTTL {
+			receipts = append(receipts, h.receipt)
+		}
+	}
+	return receipts
+}
+
+// expire süresi dolan raporları siler
+func (p *PendingReceipts) expire(now time.Time) {
+	for id, held := range p.receipts {
+		kept := held[:0]
+		for _, h := range held {
+			if now.Sub(h.heldAt) <= PendingReceiptTTL {
+				kept = append(kept, h)
+			}
+		}
+		p.count -= len(held) - len(kept)
+		if len(kept) == 0 {
+			delete(p.receipts, id)
+		} else {
+			p.receipts[id] = kept
+		}
+	}
+}
+
+// StatusEvent bir mesajın durum geçmişindeki tek kayıt
+type StatusEvent struct {
+	Status string    `json:"status"`
+	Error  string    `json:"error,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+// Delivery gönderilen bir SMS'in teslim kaydı. Doğrulama kodu içerebildiği için metin saklanmaz.
+type Delivery struct {
+	ID        string        `json:"id"`
+	Number    string        `json:"number"`
+	Template  string        `json:"template,omitempty"`
+	Reference string        `json:"reference,omitempty"` // örn. sipariş ID'si
+	Segments  int           `json:"segments"`
+	Status    string        `json:"status"`
+	Error     string        `json:"error,omitempty"`
+	History   []StatusEvent `json:"history"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// DeliveryLog teslim kayıtlarını bellekte tutar ve her değişikliği path'teki dosyaya satır
+// olarak ekler. Açılışta dosya okunup her mesajın son hali ile yeniden yazılır. Gönderimi
+// bitmiş kayıtlar son güncellemeden retention kadar sonra bellekten ve dosyadan silinir.
+type DeliveryLog struct {
+	retention time.Duration
+
+	mu         sync.Mutex
+	path       string
+	file       *os.File
+	deliveries map[string]*Delivery
+	pending    PendingReceipts
+}
+
+// OpenDeliveryLog kayıtları path'ten yükler. path boşsa kayıtlar yalnızca bellekte tutulur.
+// retention 0 ise kayıtlar silinmez.
+func OpenDeliveryLog(path string, retention time.Duration) (*DeliveryLog, error) {
+	l := &DeliveryLog{path: path, retention: retention, deliveries: make(map[string]*Delivery)}
+	if retention > 0 {
+		go func() {
+			for range time.Tick(expireInterval) {
+				l.expire(time.Now())
+			}
+		}()
+	}
+	if path == "" {
+		return l, nil
+	}
+
+	f, err := os.Open(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64<<10), 1<<20)
+		for line := 1; scanner.Scan(); line++ {
+			var d Delivery
+			if err := json.Unmarshal(scanner.Bytes(), &d); err != nil {
+				f.Close()
+				return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+			}
+			if l.expired(&d, time.Now()) {
+				// Daha eski satırlarda kalan hali de silinir
+				delete(l.deliveries, d.ID)
+				continue
+			}
+			l.deliveries[d.ID] = &d
+		}
+		f.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := l.compact(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// compact dosyayı her mesajın son hali ile yeniden yazar ve ekleme için açar, kilit altında çağrılır
+func (l *DeliveryLog) compact() error {
+	tmp := l.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, d := range l.sorted() {
+		if err := enc.Encode(d); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		return err
+	}
+
+	if l.file != nil {
+		l.file.Close()
+	}
+	l.file, err = os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	return err
+}
+
+// expired kaydın gönderimi bitmiş ve saklama süresi dolmuşsa true döner. "sent" de bitmiş sayılır,
+// teslim raporu olmayan sağlayıcılarda kayıtlar bu durumda kalır.
+func (l *DeliveryLog) expired(d *Delivery, now time.Time) bool {
+	if l.retention <= 0 || d.Status == StatusQueued || d.Status == StatusSending {
+		return false
+	}
+	return now.Sub(d.UpdatedAt) > l.retention
+}
+
+// expire saklama süresi dolan kayıtları siler ve dosyayı sıkıştırır
+func (l *DeliveryLog) expire(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	removed := 0
+	for id, d := range l.deliveries {
+		if l.expired(d, now) {
+			delete(l.deliveries, id)
+			removed++
+		}
+	}
+	if removed == 0 || l.path == "" {
+		return
+	}
+	if err := l.compact(); err != nil {
+		log.Printf("sms delivery log: compact %s: %v", l.path, err)
+	}
+}
+
+// Record yeni bir gönderimi kaydeder. ID boşsa (teslim raporu olmayan sağlayıcılar) yerel ID verilir.
+// Kayıttan önce gelmiş raporlar kayda işlenir.
+func (l *DeliveryLog) Record(d Delivery) Delivery {
+	now := time.Now()
+	if d.ID == "" {
+		d.ID = "local_" + randomID()
+	}
+	if d.Status == "" {
+		d.Status = StatusQueued
+	}
+	d.History = []StatusEvent{{Status: d.Status, Error: d.Error, At: now}}
+	d.CreatedAt, d.UpdatedAt = now, now
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.deliveries[d.ID] = &d
+	l.append(&d)
+	for _, r := range l.pending.Take(d.ID, now) {
+		l.advance(&d, r.Status, r.Error, r.UpdatedAt)
+	}
+	return d
+}
+
+// Update mesajın durumunu değiştirir. Geride kalan bir durum gelirse kayıt değişmeden döner.
+// Mesaj henüz kaydedilmediyse (gateway raporu Send dönmeden gönderebilir) rapor bekletilir
+// ve ErrReceiptPending döner.
+func (l *DeliveryLog) Update(id, status, errMsg string, at time.Time) (Delivery, error) {
+	now := time.Now()
+	if at.IsZero() {
+		at = now
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	d, ok := l.deliveries[id]
+	if !ok {
+		if l.pending.Hold(Receipt{MessageID: id, Status: status, Error: errMsg, UpdatedAt: at}, now) {
+			return Delivery{}, ErrReceiptPending
+		}
+		return Delivery{}, ErrDeliveryNotFound
+	}
+	l.advance(d, status, errMsg, at)
+	return *d, nil
+}
+
+// advance durum ileri gidiyorsa kaydı günceller, kilit altında çağrılır
+func (l *DeliveryLog) advance(d *Delivery, status, errMsg string, at time.Time) {
+	if !StatusAdvances(d.Status, status) {
+		return
+	}
+	d.Status, d.Error, d.UpdatedAt = status, errMsg, at
+	d.History = append(d.History, StatusEvent{Status: status, Error: errMsg, At: at})
+	l.append(d)
+}
+
+// Find mesaj ID'sine göre kaydı döner
+func (l *DeliveryLog) Find(id string) (Delivery, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	d, ok := l.deliveries[id]
+	if !ok {
+		return Delivery{}, false
+	}
+	return *d, true
+}
+
+// List numara ve duruma göre filtrelenmiş kayıtları en yeniden eskiye döner. Boş filtre hepsini kapsar.
+func (l *DeliveryLog) List(number, status string, limit int) []Delivery {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	list := []Delivery{}
+	sorted := l.sorted()
+	for i := len(sorted) - 1; i >= 0; i-- {
+		d := sorted[i]
+		if (number != "" && d.Number != number) || (status != "" && d.Status != status) {
+			continue
+		}
+		list = append(list, *d)
+		if limit > 0 && len(list) == limit {
+			break
+		}
+	}
+	return list
+}
+
+// sorted kayıtları oluşturulma sırasına göre döner, kilit altında çağrılır
+func (l *DeliveryLog) sorted() []*Delivery {
+	list := make([]*Delivery, 0, len(l.deliveries))
+	for _, d := range l.deliveries {
+		list = append(list, d)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list
+}
+
+// append kaydın son halini dosyaya ekler, kilit altında çağrılır
+func (l *DeliveryLog) append(d *Delivery) {
+	if l.file == nil {
+		return
+	}
+	data, err := json.Marshal(d)
+	if err != nil {
+		log.Printf("sms delivery log: encode %s: %v", d.ID, err)
+		return
+	}
+	if _, err := l.file.Write(append(data, '\n')); err != nil {
+		log.Printf("sms delivery log: write %s: %v", l.path, err)
+	}
+}
+
+/*
+	Gateway'in callback_url'e gönderdiği teslim raporunu işler:
+	POST <callback_url> {"message_id": "msg_...", "status": "delivered", "updated_at": "..."}
+	Kimlik doğrulaması çağıran tarafın işidir, örn. callback URL'sindeki gizli token.
+	Henüz kaydedilmemiş mesajın raporu 202 ile kabul edilir ve PendingReceiptTTL boyunca bekletilir.
+*/
+
+func (l *DeliveryLog) HandleReceipt(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var receipt Receipt
+	if err := json.NewDecoder(r.Body).Decode(&receipt); err != nil || receipt.MessageID == "" {
+		writeError(w, http.StatusBadRequest, "Invalid receipt payload")
+		return
+	}
+	if !ValidStatus(receipt.Status) {
+		writeError(w, http.StatusBadRequest, "Unknown status: "+receipt.Status)
+		return
+	}
+
+	d, err := l.Update(receipt.MessageID, receipt.Status, receipt.Error, receipt.UpdatedAt)
+	if errors.Is(err, ErrReceiptPending) {
+		writeJSON(w, http.StatusAccepted, receipt)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, d)
+}
+
+/*
+	Destek ekibi için teslim kayıtları:
+	GET ?id=<MESSAGE_ID>                          -> tek kayıt ve durum geçmişi
+	GET ?number=+905321234567&status=failed&limit=20 -> numaraya giden son mesajlar
+	number ulusal biçimde de verilebilir, defaultRegion'a göre E.164'e çevrilir.
+*/
+
+func (l *DeliveryLog) HandleQuery(defaultRegion string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		q := r.URL.Query()
+
+		if id := q.Get("id"); id != "" {
+			d, ok := l.Find(id)
+			if !ok {
+				writeError(w, http.StatusNotFound, ErrDeliveryNotFound.Error())
+				return
+			}
+			writeJSON(w, http.StatusOK, d)
+			return
+		}
+
+		number := q.Get("number")
+		if number != "" {
+			phone, err := ParsePhone(number, defaultRegion)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			number = phone.E164
+		}
+		status := q.Get("status")
+		if status != "" && !ValidStatus(status) {
+			writeError(w, http.StatusBadRequest, "Unknown status: "+status)
+			return
+		}
+		limit, _ := strconv.Atoi(q.Get("limit"))
+		if limit <= 0 {
+			limit = 50
+		}
+		writeJSON(w, http.StatusOK, l.List(number, status, limit))
+	}
+}
+
+func randomID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,224 @@
+package phoneapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatusAdvances(t *testing.T) {
+	tests := []struct {
+		current, next string
+		want          bool
+	}{
+		{StatusQueued, StatusSent, true},
+		{StatusSent, StatusDelivered, true},
+		{StatusSent, StatusFailed, true},
+		{StatusDelivered, StatusSent, false},
+		{StatusDelivered, StatusFailed, false},
+		{StatusSent, StatusSent, false},
+		{StatusSent, "read", false},
+	}
+	for _, tt := range tests {
+		if got := StatusAdvances(tt.current, tt.next); got != tt.want {
+			t.Errorf("StatusAdvances(%q, %q) = %v, want %v", tt.current, tt.next, got, tt.want)
+		}
+	}
+}
+
+func TestDeliveryLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deliveries.jsonl")
+	l, err := OpenDeliveryLog(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := l.Record(Delivery{ID: "msg_1", Number: "+905321234567", Template: TemplateOTP, Segments: 1})
+	if d.Status != StatusQueued || len(d.History) != 1 {
+		t.Errorf("recorded = %+v", d)
+	}
+	local := l.Record(Delivery{Number: "+905321234568", Status: StatusSent})
+	if !strings.HasPrefix(local.ID, "local_") {
+		t.Errorf("delivery without a provider ID got %q", local.ID)
+	}
+
+	if d, err = l.Update("msg_1", StatusDelivered, "", time.Time{}); err != nil || d.Status != StatusDelivered {
+		t.Fatalf("Update = %+v, %v", d, err)
+	}
+	if d, _ = l.Update("msg_1", StatusSent, "", time.Time{}); d.Status != StatusDelivered || len(d.History) != 2 {
+		t.Errorf("late receipt changed the delivery: %+v", d)
+	}
+	// Send dönmeden gelen rapor bekletilir ve kayıtla birlikte işlenir
+	if _, err := l.Update("msg_2", StatusDelivered, "", time.Time{}); !errors.Is(err, ErrReceiptPending) {
+		t.Errorf("Update of an unrecorded message = %v, want %v", err, ErrReceiptPending)
+	}
+	early := l.Record(Delivery{ID: "msg_2", Number: "+905321234569", Status: StatusQueued})
+	if early.Status != StatusDelivered || len(early.History) != 2 {
+		t.Errorf("held receipt was not applied: %+v", early)
+	}
+
+	reopened, err := OpenDeliveryLog(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, ok := reopened.Find("msg_1"); !ok || d.Status != StatusDelivered || len(d.History) != 2 {
+		t.Errorf("reloaded delivery = %+v, %v", d, ok)
+	}
+	if got := reopened.List("", "", 0); len(got) != 3 || got[0].ID != "msg_2" || got[1].ID != local.ID {
+		t.Errorf("List = %+v, want newest first", got)
+	}
+	if got := reopened.List("+905321234567", StatusDelivered, 0); len(got) != 1 {
+		t.Errorf("filtered List = %+v", got)
+	}
+
+	// Açılışta dosya her mesajın son hali ile yeniden yazılır
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), "\n"); n != 3 {
+		t.Errorf("compacted log has %d lines, want 3", n)
+	}
+}
+
+func TestDeliveryLogHandlers(t *testing.T) {
+	l, _ := OpenDeliveryLog("", 0)
+	l.Record(Delivery{ID: "msg_1", Number: "+905321234567"})
+
+	receipt := func(body string) int {
+		rec := httptest.NewRecorder()
+		l.HandleReceipt(rec, httptest.NewRequest(http.MethodPost, "/sms/receipts", strings.NewReader(body)))
+		return rec.Code
+	}
+	if code := receipt(`{"status": "delivered"}`); code != http.StatusBadRequest {
+		t.Errorf("receipt without message_id = %d", code)
+	}
+	if code := receipt(`{"message_id": "msg_1", "status": "read"}`); code != http.StatusBadRequest {
+		t.Errorf("receipt with an unknown status = %d", code)
+	}
+	if code := receipt(`{"message_id": "msg_2", "status": "delivered"}`); code != http.StatusAccepted {
+		t.Errorf("receipt for an unrecorded message = %d, want %d", code, http.StatusAccepted)
+	}
+	if code := receipt(`{"message_id": "msg_1", "status": "delivered"}`); code != http.StatusOK {
+		t.Errorf("receipt = %d", code)
+	}
+
+	query := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		l.HandleQuery("TR")(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+	if rec := query("/sms/deliveries?id=msg_1"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"status":"delivered"`) {
+		t.Errorf("query by id = %d: %s", rec.Code, rec.Body)
+	}
+	if rec := query("/sms/deliveries?number=0532%20123%2045%2067"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "msg_1") {
+		t.Errorf("query by national number = %d: %s", rec.Code, rec.Body)
+	}
+	if rec := query("/sms/deliveries?status=read"); rec.Code != http.StatusBadRequest {
+		t.Errorf("query with an unknown status = %d", rec.Code)
+	}
+}
+
+func TestOTPRecordsDelivery(t *testing.T) {
+	deliveries, _ := OpenDeliveryLog("", 0)
+	cfg := DefaultOTPConfig
+	cfg.Deliveries = deliveries
+	s, _ := newTestOTP(t, cfg)
+
+	id, err := s.Send("+4915123456789", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, ok := deliveries.Find(id)
+	if !ok || id != "msg_+4915123456789" || d.Template != TemplateOTP || d.Status != StatusQueued {
+		t.Errorf("delivery %q = %+v, %v", id, d, ok)
+	}
+}
+
+func TestPendingReceipts(t *testing.T) {
+	var p PendingReceipts
+	now := time.Now()
+	if !p.Hold(Receipt{MessageID: "msg_1", Status: StatusSent}, now) || !p.Hold(Receipt{MessageID: "msg_1", Status: StatusDelivered}, now) {
+		t.Fatal("Hold rejected a receipt")
+	}
+	p.Hold(Receipt{MessageID: "msg_2", Status: StatusDelivered}, now.Add(-PendingReceiptTTL-time.Second))
+
+	if got := p.Take("msg_1", now); len(got) != 2 || got[0].Status != StatusSent || got[1].Status != StatusDelivered {
+		t.Errorf("Take = %+v", got)
+	}
+	if got := p.Take("msg_1", now); len(got) != 0 {
+		t.Errorf("second Take = %+v", got)
+	}
+	if got := p.Take("msg_2", now); len(got) != 0 {
+		t.Errorf("expired receipt returned: %+v", got)
+	}
+
+	for i := 0; i < maxPendingReceipts; i++ {
+		p.Hold(Receipt{MessageID: fmt.Sprintf("msg_%d", i), Status: StatusDelivered}, now)
+	}
+	if p.Hold(Receipt{MessageID: "msg_x", Status: StatusDelivered}, now) {
+		t.Error("Hold accepted a receipt over the limit")
+	}
+	// Süresi dolan raporlar yer açar
+	if !p.Hold(Receipt{MessageID: "msg_x", Status: StatusDelivered}, now.Add(PendingReceiptTTL+time.Second)) {
+		t.Error("Hold did not drop expired receipts")
+	}
+}
+
+func TestDeliveryLogExpire(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deliveries.jsonl")
+	l, err := OpenDeliveryLog(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Record(Delivery{ID: "msg_1", Number: "+905321234567", Status: StatusSent})
+	l.Record(Delivery{ID: "msg_2", Number: "+905321234568", Status: StatusQueued})
+
+	l.expire(time.Now())
+	if _, ok := l.Find("msg_1"); !ok {
+		t.Fatal("delivery was removed before its retention period")
+	}
+	l.expire(time.Now().Add(2 * time.Hour))
+	if _, ok := l.Find("msg_1"); ok {
+		t.Error("expired delivery is still in memory")
+	}
+	// Gönderimi bitmemiş kayıt saklanır
+	if _, ok := l.Find("msg_2"); !ok {
+		t.Error("queued delivery was removed")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "msg_1") || !strings.Contains(string(data), "msg_2") {
+		t.Errorf("log was not compacted:\n%s", data)
+	}
+
+	// Süresi dolmuş satırlar açılışta yüklenmez
+	old := Delivery{ID: "msg_3", Status: StatusDelivered, UpdatedAt: time.Now().Add(-2 * time.Hour)}
+	line, _ := json.Marshal(old)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(append(line, '\n'))
+	f.Close()
+	reopened, err := OpenDeliveryLog(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reopened.Find("msg_3"); ok {
+		t.Error("expired delivery was loaded")
+	}
+	if got := reopened.List("", "", 0); len(got) != 1 || got[0].ID != "msg_2" {
+		t.Errorf("reloaded deliveries = %+v", got)
+	}
+}
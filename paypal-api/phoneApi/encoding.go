@@ -0,0 +1,86 @@
+package phoneapi
+
+import "strings"
+
+// SMS karakter kodlamaları
+const (
+	EncodingGSM7 = "GSM-7"
+	EncodingUCS2 = "UCS-2"
+)
+
+// Tek ve çok parçalı mesajlarda bir parçaya sığan karakter sayısı. Çok parçalı mesajlarda
+// her parçanın başındaki UDH birleştirme bilgisi yer kaplar.
+const (
+	gsm7Single = 160
+	gsm7Multi  = 153
+	ucs2Single = 70
+	ucs2Multi  = 67
+)
+
+// gsm7Basic GSM 03.38 temel karakter tablosu, her karakter 1 septet
+const gsm7Basic = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+	"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+
+// gsm7Extension kaçış karakteriyle yazılan karakterler, her biri 2 septet
+const gsm7Extension = "\f^{}\\[~]|€"
+
+// Segments bir mesajın kodlaması ve kaç SMS'e bölüneceği. Operatörler her parçayı ayrı ücretlendirir.
+type Segments struct {
+	Encoding   string `json:"encoding"`
+	Units      int    `json:"units"` // GSM-7'de septet, UCS-2'de UTF-16 birimi
+	Count      int    `json:"segments"`
+	PerSegment int    `json:"per_segment"`
+}
+
+// CountSegments metnin GSM-7 ile yazılıp yazılamayacağını belirler ve parça sayısını hesaplar.
+// Tek bir karakter GSM-7 dışındaysa (örn. Türkçe ı, ğ, ş veya emoji) tüm mesaj UCS-2 gönderilir.
+// Kaçış dizileri ve vekil çiftler iki parçaya bölünmediği için sayım karakter bazında yapılır.
+func CountSegments(text string) Segments {
+	encoding := EncodingGSM7
+	for _, r := range text {
+		if !strings.ContainsRune(gsm7Basic, r) && !strings.ContainsRune(gsm7Extension, r) {
+			encoding = EncodingUCS2
+			break
+		}
+	}
+
+	single, multi := gsm7Single, gsm7Multi
+	if encoding == EncodingUCS2 {
+		single, multi = ucs2Single, ucs2Multi
+	}
+
+	var widths []int
+	units := 0
+	for _, r := range text {
+		w := 1
+		switch {
+		case encoding == EncodingGSM7 && strings.ContainsRune(gsm7Extension, r):
+			w = 2
+		case encoding == EncodingUCS2 && r > 0xFFFF:
+			w = 2
+		}
+		widths = append(widths, w)
+		units += w
+	}
+
+	s := Segments{Encoding: encoding, Units: units, PerSegment: single}
+	switch {
+	case units == 0:
+		return s
+	case units <= single:
+		s.Count = 1
+		return s
+	}
+
+	s.PerSegment = multi
+	s.Count = 1
+	used := 0
+	for _, w := range widths {
+		if used+w > multi {
+			s.Count++
+			used = 0
+		}
+		used += w
+	}
+	return s
+}
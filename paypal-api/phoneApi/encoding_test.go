@@ -0,0 +1,45 @@
+package phoneapi
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCountSegments(t *testing.T) {
+	tests := []struct {
+		name       string
+		text       string
+		encoding   string
+		units      int
+		count      int
+		perSegment int
+	}{
+		{"empty", "", EncodingGSM7, 0, 0, gsm7Single},
+		{"short", "Hello", EncodingGSM7, 5, 1, gsm7Single},
+		{"GSM-7 single limit", strings.Repeat("a", 160), EncodingGSM7, 160, 1, gsm7Single},
+		{"GSM-7 first multipart", strings.Repeat("a", 161), EncodingGSM7, 161, 2, gsm7Multi},
+		{"GSM-7 two full parts", strings.Repeat("a", 306), EncodingGSM7, 306, 2, gsm7Multi},
+		{"GSM-7 three parts", strings.Repeat("a", 307), EncodingGSM7, 307, 3, gsm7Multi},
+		{"extension chars count twice", strings.Repeat("€", 80), EncodingGSM7, 160, 1, gsm7Single},
+		{"extension char over single limit", strings.Repeat("a", 159) + "€", EncodingGSM7, 161, 2, gsm7Multi},
+		// Kaçış dizisi iki parçaya bölünmez: 152 + 2 septet ikinci parçaya taşar
+		{"escape not split", strings.Repeat("a", 152) + "€" + strings.Repeat("a", 152), EncodingGSM7, 306, 3, gsm7Multi},
+		{"Turkish switches to UCS-2", "Doğrulama kodunuz: 123456", EncodingUCS2, 25, 1, ucs2Single},
+		{"UCS-2 single limit", strings.Repeat("ş", 70), EncodingUCS2, 70, 1, ucs2Single},
+		{"UCS-2 first multipart", strings.Repeat("ş", 71), EncodingUCS2, 71, 2, ucs2Multi},
+		{"UCS-2 two full parts", strings.Repeat("ş", 134), EncodingUCS2, 134, 2, ucs2Multi},
+		{"UCS-2 three parts", strings.Repeat("ş", 135), EncodingUCS2, 135, 3, ucs2Multi},
+		{"emoji is a surrogate pair", "😀", EncodingUCS2, 2, 1, ucs2Single},
+		// Vekil çift iki parçaya bölünmez: 66 + 2 birim ikinci parçaya taşar
+		{"surrogate not split", strings.Repeat("ş", 66) + "😀" + strings.Repeat("ş", 66), EncodingUCS2, 134, 3, ucs2Multi},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CountSegments(tt.text)
+			want := Segments{Encoding: tt.encoding, Units: tt.units, Count: tt.count, PerSegment: tt.perSegment}
+			if got != want {
+				t.Errorf("CountSegments = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
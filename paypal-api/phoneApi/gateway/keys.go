@@ -0,0 +1,161 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// APIKey bir istemciye verilmiş anahtar. Anahtarın kendisi değil SHA-256 özeti saklanır.
+type APIKey struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"created_at"`
+	RevokedAt time.Time `json:"revoked_at,omitempty"`
+}
+
+// keyStore anahtarları bellekte tutar, path verilmişse dosyaya da yazar
+type keyStore struct {
+	mu   sync.Mutex
+	path string
+	keys map[string]*APIKey
+}
+
+// openKeyStore dosyadaki anahtarları yükler
+func openKeyStore(path string) *keyStore {
+	s := &keyStore{path: path, keys: make(map[string]*APIKey)}
+	if path == "" {
+		return s
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s
+	}
+	if err != nil {
+		log.Fatalf("keys: read %s: %v", path, err)
+	}
+	var keys []*APIKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		log.Fatalf("keys: decode %s: %v", path, err)
+	}
+	for _, k := range keys {
+		s.keys[k.ID] = k
+	}
+	return s
+}
+
+// create yeni bir anahtar üretir. Düz anahtar yalnızca burada döner, sonra elde edilemez.
+func (s *keyStore) create(name string) (APIKey, string) {
+	secret := randomHex(24)
+	return s.add(name, secret), secret
+}
+
+// add verilen düz anahtarı kaydeder, ortam değişkeninden gelen anahtarlar için kullanılır
+func (s *keyStore) add(name, secret string) APIKey {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash := hashKey(secret)
+	for _, k := range s.keys {
+		if k.Hash == hash {
+			return *k
+		}
+	}
+	// ID özetten türetilir, böylece SMS_KEYS_FILE olmadan da ortam anahtarları yeniden
+	// başlatmada aynı ID'yi alır ve durum kayıtlarındaki mesajlarını görmeye devam eder
+	k := &APIKey{ID: "key_" + hash[:16], Name: name, Hash: hash, CreatedAt: time.Now()}
+	s.keys[k.ID] = k
+	s.persist()
+	return *k
+}
+
+// revoke anahtarı iptal eder
+func (s *keyStore) revoke(id string) (APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.keys[id]
+	if !ok {
+		return APIKey{}, fmt.Errorf("key %s not found", id)
+	}
+	if k.RevokedAt.IsZero() {
+		k.RevokedAt = time.Now()
+		s.persist()
+	}
+	return *k, nil
+}
+
+// authenticate düz anahtara ait, iptal edilmemiş kaydı döner
+func (s *keyStore) authenticate(secret string) (APIKey, bool) {
+	if secret == "" {
+		return APIKey{}, false
+	}
+	hash := []byte(hashKey(secret))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, k := range s.keys {
+		if subtle.ConstantTimeCompare([]byte(k.Hash), hash) == 1 && k.RevokedAt.IsZero() {
+			return *k, true
+		}
+	}
+	return APIKey{}, false
+}
+
+// list anahtarları oluşturulma sırasına göre döner
+func (s *keyStore) list() []APIKey {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]APIKey, 0, len(s.keys))
+	for _, k := range s.keys {
+		keys = append(keys, *k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+	return keys
+}
+
+// persist anahtarları dosyaya yazar, kilit altında çağrılır
+func (s *keyStore) persist() {
+	if s.path == "" {
+		return
+	}
+	keys := make([]*APIKey, 0, len(s.keys))
+	for _, k := range s.keys {
+		keys = append(keys, k)
+	}
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		log.Printf("keys: encode: %v", err)
+		return
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		log.Printf("keys: write %s: %v", tmp, err)
+		return
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		log.Printf("keys: rename %s: %v", tmp, err)
+	}
+}
+
+func hashKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
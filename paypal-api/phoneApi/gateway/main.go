@@ -0,0 +1,355 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"paypal/phoneApi"
+)
+
+/*
+	phoneApi/main.go'da dokümante edilen SMS gateway sunucusu:
+	SMS_GATEWAY_ADDR=:5000 SMS_ADMIN_KEY=<ADMIN_KEY> go run ./phoneApi/gateway
+
+	SMS_API_KEYS        -> virgülle ayrılmış başlangıç anahtarları
+	SMS_KEYS_FILE       -> anahtarların saklandığı JSON dosyası
+	SMS_QUEUE_SIZE      -> kuyruk kapasitesi (varsayılan 1000)
+	SMS_WORKERS         -> eşzamanlı gönderim yapan worker sayısı (varsayılan 4)
+	SMS_DEFAULT_REGION  -> "+" ile başlamayan numaralar için bölge (varsayılan TR)
+	SMS_STATUS_LOG      -> mesaj durumlarının saklandığı dosya, verilmezse kayıtlar yeniden başlatmada kaybolur.
+	                       Mesaj metinleri yazılmaz, yeniden başlatmada kuyrukta bekleyen mesajlar failed olur.
+	SMS_RETENTION_DAYS  -> gönderimi biten mesajların saklandığı gün sayısı (varsayılan 30)
+	SMS_DLR_TOKEN       -> sağlayıcıların /delivery-receipt?token=<SMS_DLR_TOKEN> ile gönderdiği teslim raporları için
+	SMS_CALLBACK_HOSTS  -> callback_url'de izin verilen host'lar, virgülle ayrılmış (örn. api.example.com).
+	                       Verilmezse API anahtarı olan herkes gateway'e iç ağdaki adreslere (örn. 127.0.0.1,
+	                       169.254.169.254) POST isteği attırabilir; üretimde mutlaka verilmelidir.
+	Sağlayıcı seçimi için provider.go'daki newProvider'a bakın.
+*/
+
+// maxSMSBody /send-sms gövdesi için üst sınır
+const maxSMSBody = 64 << 10
+
+var (
+	keys     *keyStore
+	queue    *messageQueue
+	adminKey = os.Getenv("SMS_ADMIN_KEY")
+	dlrToken = os.Getenv("SMS_DLR_TOKEN")
+
+	defaultRegion = envOrDefault("SMS_DEFAULT_REGION", "TR")
+	callbackHosts = envList("SMS_CALLBACK_HOSTS")
+)
+
+func main() {
+	provider, err := newProvider()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	keys = openKeyStore(os.Getenv("SMS_KEYS_FILE"))
+	for i, secret := range strings.Split(os.Getenv("SMS_API_KEYS"), ",") {
+		if secret = strings.TrimSpace(secret); secret != "" {
+			keys.add("env-"+strconv.Itoa(i+1), secret)
+		}
+	}
+	if len(callbackHosts) == 0 {
+		log.Println("SMS_CALLBACK_HOSTS is not set, callback_url may point to any host")
+	}
+	queue, err = newMessageQueue(provider, os.Getenv("SMS_STATUS_LOG"), envInt("SMS_QUEUE_SIZE", 1000), envInt("SMS_WORKERS", 4),
+		time.Duration(envInt("SMS_RETENTION_DAYS", 30))*24*time.Hour)
+	if err != nil {
+		log.Fatalf("status log: %v", err)
+	}
+
+	http.HandleFunc("/send-sms", requireKey(handleSendSMS))
+	http.HandleFunc("/status", requireKey(handleStatus))
+	http.HandleFunc("/messages", requireKey(handleMessages))
+	http.HandleFunc("/delivery-receipt", handleDeliveryReceipt)
+	http.HandleFunc("/admin/keys", requireAdmin(handleKeys))
+	http.HandleFunc("/admin/keys/revoke", requireAdmin(handleRevokeKey))
+
+	addr := os.Getenv("SMS_GATEWAY_ADDR")
+	if addr == "" {
+		addr = ":5000"
+	}
+	log.Println("SMS gateway starting at", addr)
+	log.Fatal(http.ListenAndServe(addr, nil))
+}
+
+// keyHandler API anahtarı doğrulanmış istek
+type keyHandler func(w http.ResponseWriter, r *http.Request, key APIKey)
+
+// requireKey API-Key başlığını doğrular, geçersizse dokümandaki 401 yanıtını döner
+func requireKey(next keyHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, ok := keys.authenticate(r.Header.Get("API-Key"))
+		if !ok {
+			writeError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		next(w, r, key)
+	}
+}
+
+// requireAdmin anahtar yönetimi endpoint'lerini SMS_ADMIN_KEY ile korur
+func requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("API-Key")
+		if adminKey == "" || subtle.ConstantTimeCompare([]byte(key), []byte(adminKey)) != 1 {
+			writeError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// sendSMSRequest POST /send-sms gövdesi
+type sendSMSRequest struct {
+	Number      string `json:"number"`
+	Message     string `json:"message"`
+	CallbackURL string `json:"callback_url,omitempty"` // durum değiştikçe phoneapi.Receipt POST edilir
+}
+
+// handleSendSMS mesajı kuyruğa alır. Gönderim arka planda yapılır, durum /status ile sorgulanır.
+func handleSendSMS(w http.ResponseWriter, r *http.Request, key APIKey) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req sendSMSRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSMSBody)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+	req.Number = strings.TrimSpace(req.Number)
+	if req.Number == "" || strings.TrimSpace(req.Message) == "" {
+		writeError(w, http.StatusBadRequest, "Number and message are required")
+		return
+	}
+
+	// Geçersiz ve SMS alamayan numaralar kuyruğa alınmadan reddedilir
+	phone, err := phoneapi.ParseSMSNumber(req.Number, defaultRegion)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid phone number: "+err.Error())
+		return
+	}
+
+	if req.CallbackURL != "" {
+		u, err := url.Parse(req.CallbackURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			writeError(w, http.StatusBadRequest, "callback_url must be an absolute http(s) URL")
+			return
+		}
+		if !allowedCallbackHost(u.Hostname()) {
+			writeError(w, http.StatusBadRequest, "callback_url host is not allowed")
+			return
+		}
+	}
+
+	m, err := queue.enqueue(key.ID, phone.E164, req.Message, req.CallbackURL)
+	if errors.Is(err, errQueueFull) {
+		w.Header().Set("Retry-After", "5")
+		writeError(w, http.StatusServiceUnavailable, "Queue is full, try again later")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success":    true,
+		"message":    "SMS queued for delivery",
+		"message_id": m.ID,
+		"status":     m.Status,
+		"encoding":   m.Encoding,
+		"segments":   m.Segments,
+	})
+}
+
+// handleStatus GET /status?id=<MESSAGE_ID> ile mesajın teslim durumunu döner
+func handleStatus(w http.ResponseWriter, r *http.Request, key APIKey) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeError(w, http.StatusBadRequest, "id is required")
+		return
+	}
+
+	m, ok := queue.find(id, key.ID)
+	if !ok {
+		writeError(w, http.StatusNotFound, "Message not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, m)
+}
+
+// handleMessages GET /messages?number=&status=&limit= ile anahtarın son mesajlarını döner
+func handleMessages(w http.ResponseWriter, r *http.Request, key APIKey) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	q := r.URL.Query()
+
+	number := q.Get("number")
+	if number != "" {
+		phone, err := phoneapi.ParsePhone(number, defaultRegion)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid phone number: "+err.Error())
+			return
+		}
+		number = phone.E164
+	}
+	status := q.Get("status")
+	if status != "" && !phoneapi.ValidStatus(status) {
+		writeError(w, http.StatusBadRequest, "Unknown status: "+status)
+		return
+	}
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	if limit <= 0 {
+		limit = 50
+	}
+	writeJSON(w, http.StatusOK, queue.list(key.ID, number, status, limit))
+}
+
+/*
+	Sağlayıcının teslim raporu. message_id sağlayıcının verdiği referanstır (provider_ref):
+	curl -X POST "http://localhost:5000/delivery-receipt?token=<SMS_DLR_TOKEN>" \
+	-d '{"message_id": "<PROVIDER_REF>", "status": "delivered"}'
+*/
+
+func handleDeliveryReceipt(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	token := r.URL.Query().Get("token")
+	if dlrToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(dlrToken)) != 1 {
+		writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var receipt phoneapi.Receipt
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSMSBody)).Decode(&receipt); err != nil || receipt.MessageID == "" {
+		writeError(w, http.StatusBadRequest, "Invalid receipt payload")
+		return
+	}
+	switch receipt.Status {
+	case phoneapi.StatusSent, phoneapi.StatusDelivered, phoneapi.StatusFailed:
+	default:
+		writeError(w, http.StatusBadRequest, "Unknown status: "+receipt.Status)
+		return
+	}
+
+	m, err := queue.receipt(receipt.MessageID, receipt.Status, receipt.Error, receipt.UpdatedAt)
+	if errors.Is(err, phoneapi.ErrReceiptPending) {
+		writeJSON(w, http.StatusAccepted, map[string]interface{}{"provider_ref": receipt.MessageID, "status": "held"})
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"id": m.ID, "status": m.Status})
+}
+
+/*
+	API anahtarı yönetimi (API-Key: <SMS_ADMIN_KEY>):
+	GET  /admin/keys                    -> anahtarlar (düz anahtar gösterilmez)
+	POST /admin/keys {"name": "shop"}   -> yeni anahtar, düz anahtar yalnızca bu yanıtta döner
+	POST /admin/keys/revoke?id=<KEY_ID> -> anahtarı iptal eder
+*/
+
+func handleKeys(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, keys.list())
+	case http.MethodPost:
+		var req struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" {
+			writeError(w, http.StatusBadRequest, "name is required")
+			return
+		}
+		key, secret := keys.create(req.Name)
+		writeJSON(w, http.StatusCreated, map[string]interface{}{
+			"id":         key.ID,
+			"name":       key.Name,
+			"api_key":    secret,
+			"created_at": key.CreatedAt,
+		})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+func handleRevokeKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	key, err := keys.revoke(r.URL.Query().Get("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, key)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError dokümandaki {"error": "..."} biçiminde hata yazar
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+func envOrDefault(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// allowedCallbackHost host'un SMS_CALLBACK_HOSTS listesinde olup olmadığını döner. Liste boşsa her host kabul edilir.
+func allowedCallbackHost(host string) bool {
+	if len(callbackHosts) == 0 {
+		return true
+	}
+	for _, allowed := range callbackHosts {
+		if strings.EqualFold(allowed, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// envList virgülle ayrılmış ortam değişkenini boş olmayan elemanlara böler
+func envList(key string) []string {
+	var list []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func envInt(key string, fallback int) int {
+	if n, err := strconv.Atoi(os.Getenv(key)); err == nil && n > 0 {
+		return n
+	}
+	return fallback
+}
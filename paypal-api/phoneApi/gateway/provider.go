@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"paypal/phoneApi"
+)
+
+// Provider mesajı fiilen ileten arka uç: GSM modem, başka bir SMS API'si veya log.
+// Dönen ID sağlayıcının mesaj referansıdır, yoksa boş olabilir. Mesajın kesin olarak
+// gönderilmediği hatalar *notSentError ile sarılır, kuyruk yalnızca bunları tekrar dener.
+type Provider interface {
+	Send(number, text string) (string, error)
+}
+
+// notSentError sağlayıcının mesajı göndermediği kesin olan hata. Zaman aşımı gibi diğer hatalarda
+// mesaj gönderilmiş olabilir, tekrar denemek çift SMS'e yol açar.
+type notSentError struct {
+	err error
+}
+
+func (e *notSentError) Error() string { return e.err.Error() }
+func (e *notSentError) Unwrap() error { return e.err }
+
+// retryable hatanın mesajın gönderilmediğini kesin olarak bildirip bildirmediğini döner
+func retryable(err error) bool {
+	var notSent *notSentError
+	return errors.As(err, &notSent)
+}
+
+// logProvider mesajları yalnızca loglar, modem olmadan geliştirme içindir
+type logProvider struct{}
+
+func (logProvider) Send(number, text string) (string, error) {
+	log.Printf("sms to %s: %q", number, text)
+	return "", nil
+}
+
+// senderProvider phoneapi.SMSSender'ı Provider olarak kullanır, örn. başka bir /send-sms gateway'i.
+// Dönen referans karşı gateway'in mesaj ID'sidir, teslim raporları bu ID ile gelir.
+type senderProvider struct {
+	sender phoneapi.SMSSender
+}
+
+// Send bağlantı reddedildiğinde veya karşı gateway gövdesiz 5xx döndüğünde mesajın kuyruğa
+// alınmadığını bilir. Diğer hatalarda (4xx, zaman aşımı) tekrar denenmez.
+func (p senderProvider) Send(number, text string) (string, error) {
+	result, err := p.sender.Send(number, text)
+	var sendErr *phoneapi.SendError
+	if errors.Is(err, syscall.ECONNREFUSED) ||
+		(errors.As(err, &sendErr) && sendErr.StatusCode >= 500 && sendErr.Message == "") {
+		return "", &notSentError{err}
+	}
+	return result.MessageID, err
+}
+
+// modemTimeout modemin bir komuta yanıt vermesi için beklenen süre
+const modemTimeout = 30 * time.Second
+
+// modemProvider seri porta bağlı bir GSM modeme AT komutlarıyla SMS gönderir.
+// Port hızı gibi ayarların önceden yapılmış olması gerekir, örn. stty -F /dev/ttyUSB0 115200 raw.
+// Modem aynı anda tek mesaj gönderebildiği için gönderimler sıraya alınır.
+type modemProvider struct {
+	mu     sync.Mutex
+	port   *os.File
+	reader *bufio.Reader
+	dcs    int // modemde en son AT+CSMP ile ayarlanan veri kodlama şeması, -1 ise henüz ayarlanmadı
+}
+
+// Metin modu veri kodlama şemaları (3GPP TS 23.038)
+const (
+	dcsGSM7 = 0
+	dcsUCS2 = 8
+)
+
+// openModem seri portu açar ve modemi metin moduna alır
+func openModem(device string) (*modemProvider, error) {
+	port, err := os.OpenFile(device, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	m := &modemProvider{port: port, reader: bufio.NewReader(port), dcs: -1}
+	for _, cmd := range []string{"ATE0", "AT+CMGF=1", `AT+CSCS="UCS2"`} {
+		if _, err := m.command(cmd+"\r", "OK"); err != nil {
+			port.Close()
+			return nil, fmt.Errorf("modem init %s: %w", cmd, err)
+		}
+	}
+	return m, nil
+}
+
+// Send AT+CMGS ile mesajı gönderir ve modemin verdiği mesaj referansını döner. Metin modeme
+// yazılmadan önceki hatalar ve modemin ERROR yanıtları mesajın gönderilmediğini gösterir.
+// Numara ve metin modeme her zaman UCS2 karakter setinde hex olarak yazılır. Mesajın ağda hangi
+// kodlamayla gideceğini AT+CSMP belirler: GSM-7'ye sığan metinlerde dcs=0 ile modem metni GSM
+// alfabesine çevirir, Türkçe karakter veya emoji içeren metinler dcs=8 ile UCS-2 gönderilir.
+func (m *modemProvider) Send(number, text string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dcs := dcsGSM7
+	if phoneapi.CountSegments(text).Encoding == phoneapi.EncodingUCS2 {
+		dcs = dcsUCS2
+	}
+	if dcs != m.dcs {
+		// 17: SMS-SUBMIT, göreli geçerlilik süresi var; 167: 24 saat; 0: protokol
+		if _, err := m.command(fmt.Sprintf("AT+CSMP=17,167,0,%d\r", dcs), "OK"); err != nil {
+			m.dcs = -1
+			return "", &notSentError{err}
+		}
+		m.dcs = dcs
+	}
+
+	if _, err := m.command(fmt.Sprintf("AT+CMGS=\"%s\"\r", ucs2Hex(number)), ">"); err != nil {
+		return "", &notSentError{err}
+	}
+	resp, err := m.command(ucs2Hex(text)+"\x1a", "OK")
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(resp, "\n") {
+		if ref, ok := strings.CutPrefix(strings.TrimSpace(line), "+CMGS:"); ok {
+			return strings.TrimSpace(ref), nil
+		}
+	}
+	return "", nil
+}
+
+// command komutu yazar ve want ile başlayan satır gelene kadar okur
+func (m *modemProvider) command(cmd, want string) (string, error) {
+	if _, err := m.port.WriteString(cmd); err != nil {
+		return "", err
+	}
+	m.port.SetReadDeadline(time.Now().Add(modemTimeout))
+
+	var resp strings.Builder
+	for {
+		if want == ">" {
+			// İstem satır sonu olmadan gelir
+			b, err := m.reader.ReadByte()
+			if err != nil {
+				return resp.String(), err
+			}
+			if b == '>' {
+				return resp.String(), nil
+			}
+			resp.WriteByte(b)
+			continue
+		}
+
+		line, err := m.reader.ReadString('\n')
+		resp.WriteString(line)
+		if err != nil {
+			return resp.String(), err
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == want:
+			return resp.String(), nil
+		case line == "ERROR", strings.HasPrefix(line, "+CMS ERROR"), strings.HasPrefix(line, "+CME ERROR"):
+			return resp.String(), &notSentError{fmt.Errorf("modem: %s", line)}
+		}
+	}
+}
+
+// ucs2Hex metni modemin UCS2 modunda beklediği UTF-16BE hex biçimine çevirir
+func ucs2Hex(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r > 0xFFFF {
+			// BMP dışındaki karakterler (emoji) vekil çift olarak yazılır
+			r -= 0x10000
+			fmt.Fprintf(&b, "%04X%04X", 0xD800+(r>>10), 0xDC00+(r&0x3FF))
+			continue
+		}
+		fmt.Fprintf(&b, "%04X", r)
+	}
+	return b.String()
+}
+
+/*
+	Sağlayıcı ortam değişkenleri ile seçilir:
+	SMS_PROVIDER=log                                             -> mesajlar loglanır (varsayılan)
+	SMS_PROVIDER=modem  SMS_MODEM_DEVICE=/dev/ttyUSB0            -> GSM modem
+	SMS_PROVIDER=http   SMS_UPSTREAM_URL=... SMS_UPSTREAM_API_KEY -> başka bir /send-sms API'si
+	                    SMS_UPSTREAM_CALLBACK_URL                -> karşı gateway'in teslim raporlarını
+	                                                                göndereceği /delivery-receipt adresi
+	Modem sağlayıcısı teslim raporu okumaz, mesajlar "sent" durumunda kalır.
+*/
+
+func newProvider() (Provider, error) {
+	switch os.Getenv("SMS_PROVIDER") {
+	case "", "log":
+		return logProvider{}, nil
+	case "modem":
+		device := os.Getenv("SMS_MODEM_DEVICE")
+		if device == "" {
+			device = "/dev/ttyUSB0"
+		}
+		return openModem(device)
+	case "http":
+		url := os.Getenv("SMS_UPSTREAM_URL")
+		if url == "" {
+			return nil, fmt.Errorf("SMS_UPSTREAM_URL is required for the http provider")
+		}
+		client := phoneapi.NewClient(url, os.Getenv("SMS_UPSTREAM_API_KEY"))
+		client.CallbackURL = os.Getenv("SMS_UPSTREAM_CALLBACK_URL")
+		return senderProvider{client}, nil
+	}
+	return nil, fmt.Errorf("unknown SMS_PROVIDER %q", os.Getenv("SMS_PROVIDER"))
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"paypal/phoneApi"
+)
+
+func TestSenderProviderRetryable(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		retryable bool
+	}{
+		{"accepted", http.StatusOK, `{"message_id": "msg_1"}`, false},
+		{"unavailable without a body", http.StatusServiceUnavailable, "", true},
+		{"server error with a reason", http.StatusInternalServerError, `{"error": "upstream timeout"}`, false},
+		{"rejected", http.StatusBadRequest, `{"error": "Invalid phone number"}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer srv.Close()
+
+			ref, err := senderProvider{phoneapi.NewClient(srv.URL, "key")}.Send("+905321234567", "hi")
+			if tt.status == http.StatusOK {
+				if err != nil || ref != "msg_1" {
+					t.Fatalf("Send = %q, %v", ref, err)
+				}
+				return
+			}
+			if err == nil || retryable(err) != tt.retryable {
+				t.Errorf("Send error = %v, retryable %v, want %v", err, retryable(err), tt.retryable)
+			}
+		})
+	}
+
+	// Kapalı porta bağlantı reddedilir, mesaj hiç gönderilmemiştir
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+	if _, err := (senderProvider{phoneapi.NewClient(url, "key")}).Send("+905321234567", "hi"); !retryable(err) {
+		t.Errorf("connection refused = %v, want a retryable error", err)
+	}
+}
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"paypal/phoneApi"
+)
+
+// maxSendAttempts sağlayıcı mesajı göndermediğini bildirdiğinde bir mesaj için en fazla deneme
+const maxSendAttempts = 3
+
+// maxCallbackAttempts callback_url'e teslim raporu için en fazla deneme
+const maxCallbackAttempts = 3
+
+// expireInterval saklama süresi dolan mesajların silinme sıklığı
+const expireInterval = time.Hour
+
+var errQueueFull = errors.New("queue is full")
+
+// Message gateway'e gelen bir SMS ve teslim durumu. Status değerleri phoneapi.Status* sabitleridir.
+// Metin doğrulama kodu içerebildiği için dosyaya yazılmaz, API yanıtlarında dönmez ve gönderimden
+// sonra bellekten de silinir.
+type Message struct {
+	ID          string                 `json:"id"`
+	KeyID       string                 `json:"key_id"`
+	Number      string                 `json:"number"`
+	Text        string                 `json:"-"`
+	Encoding    string                 `json:"encoding"`
+	Segments    int                    `json:"segments"` // operatörün ücretlendireceği SMS parça sayısı
+	Status      string                 `json:"status"`
+	Attempts    int                    `json:"attempts"`
+	ProviderRef string                 `json:"provider_ref,omitempty"`
+	CallbackURL string                 `json:"callback_url,omitempty"`
+	Error       string                 `json:"error,omitempty"`
+	History     []phoneapi.StatusEvent `json:"history"`
+	CreatedAt   time.Time              `json:"created_at"`
+	UpdatedAt   time.Time              `json:"updated_at"`
+	// Removed kuyruk dolu olduğu için kabul edilmeyen mesajın durum kaydını işaretler, yüklemede atlanır
+	Removed bool `json:"removed,omitempty"`
+}
+
+// setStatus durumu değiştirir ve geçmişe ekler
+func (m *Message) setStatus(status, errMsg string, at time.Time) {
+	m.Status, m.Error = status, errMsg
+	m.History = append(m.History, phoneapi.StatusEvent{Status: status, Error: errMsg, At: at})
+}
+
+// messageQueue mesajları kuyruğa alır ve worker'lar ile sağlayıcıya iletir.
+// path verilmişse her durum değişikliği dosyaya satır olarak eklenir, yeniden başlatmada okunur.
+// Gönderimi bitmiş mesajlar son güncellemeden retention kadar sonra bellekten ve dosyadan silinir.
+type messageQueue struct {
+	provider  Provider
+	jobs      chan string
+	client    *http.Client
+	retention time.Duration
+
+	mu            sync.Mutex
+	path          string
+	file          *os.File
+	messages      map[string]*Message
+	byProviderRef map[string]string // sağlayıcı referansı -> mesaj ID'si, teslim raporları için
+	pending       phoneapi.PendingReceipts
+}
+
+// newCallbackClient callback_url'lere istek atan istemci. Yönlendirmeler izlenmez, aksi halde
+// izinli bir host callback'i SMS_CALLBACK_HOSTS dışındaki bir adrese taşıyabilir.
+func newCallbackClient() *http.Client {
+	return &http.Client{
+		Timeout:       10 * time.Second,
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+}
+
+// newMessageQueue size kapasiteli kuyruk oluşturur, path'teki kayıtları yükler ve workers adet worker başlatır
+func newMessageQueue(provider Provider, path string, size, workers int, retention time.Duration) (*messageQueue, error) {
+	q := &messageQueue{
+		provider:      provider,
+		jobs:          make(chan string, size),
+		client:        newCallbackClient(),
+		retention:     retention,
+		path:          path,
+		messages:      make(map[string]*Message),
+		byProviderRef: make(map[string]string),
+	}
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, m := range q.sorted() {
+		switch m.Status {
+		case phoneapi.StatusQueued:
+			// Metin saklanmadığı için kuyrukta bekleyen mesaj yeniden gönderilemez
+			m.setStatus(phoneapi.StatusFailed, "message text was lost on gateway restart", now)
+			m.UpdatedAt = now
+			q.append(m)
+		case phoneapi.StatusSending:
+			// Sağlayıcıya iletilip iletilmediği bilinmiyor, tekrar göndermek çift SMS'e yol açabilir
+			m.setStatus(phoneapi.StatusFailed, "interrupted by gateway restart", now)
+			m.UpdatedAt = now
+			q.append(m)
+		}
+	}
+
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+	if retention > 0 {
+		go func() {
+			for range time.Tick(expireInterval) {
+				q.expire(time.Now())
+			}
+		}()
+	}
+	return q, nil
+}
+
+// load dosyadaki kayıtları okur ve dosyayı her mesajın son hali ile yeniden yazar
+func (q *messageQueue) load() error {
+	if q.path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(q.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		var m Message
+		if err := json.Unmarshal(scanner.Bytes(), &m); err != nil {
+			return fmt.Errorf("%s:%d: %w", q.path, line, err)
+		}
+		if m.Removed {
+			delete(q.messages, m.ID)
+			continue
+		}
+		q.messages[m.ID] = &m
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for id, m := range q.messages {
+		if q.expired(m, now) {
+			delete(q.messages, id)
+		} else if m.ProviderRef != "" {
+			q.byProviderRef[m.ProviderRef] = id
+		}
+	}
+	return q.compact()
+}
+
+// compact dosyayı her mesajın son hali ile yeniden yazar ve ekleme için açar, kilit altında çağrılır
+func (q *messageQueue) compact() error {
+	var compacted bytes.Buffer
+	enc := json.NewEncoder(&compacted)
+	for _, m := range q.sorted() {
+		enc.Encode(m)
+	}
+	tmp := q.path + ".tmp"
+	if err := os.WriteFile(tmp, compacted.Bytes(), 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, q.path); err != nil {
+		return err
+	}
+	if q.file != nil {
+		q.file.Close()
+	}
+	var err error
+	q.file, err = os.OpenFile(q.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	return err
+}
+
+// expired mesajın gönderimi bitmiş ve saklama süresi dolmuşsa true döner. "sent" de bitmiş sayılır,
+// teslim raporu desteklemeyen sağlayıcılarda mesajlar bu durumda kalır.
+func (q *messageQueue) expired(m *Message, now time.Time) bool {
+	if q.retention <= 0 || m.Status == phoneapi.StatusQueued || m.Status == phoneapi.StatusSending {
+		return false
+	}
+	return now.Sub(m.UpdatedAt) > q.retention
+}
+
+// expire saklama süresi dolan mesajları siler ve dosyayı sıkıştırır
+func (q *messageQueue) expire(now time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	removed := 0
+	for id, m := range q.messages {
+		if q.expired(m, now) {
+			delete(q.messages, id)
+			delete(q.byProviderRef, m.ProviderRef)
+			removed++
+		}
+	}
+	if removed == 0 || q.path == "" {
+		return
+	}
+	if err := q.compact(); err != nil {
+		log.Printf("sms status log: compact %s: %v", q.path, err)
+	}
+}
+
+// enqueue mesajı kaydeder ve kuyruğa ekler. Kuyruk doluysa errQueueFull döner.
+func (q *messageQueue) enqueue(keyID, number, text, callbackURL string) (Message, error) {
+	now := time.Now()
+	segments := phoneapi.CountSegments(text)
+	m := &Message{
+		ID:          "msg_" + randomHex(12),
+		KeyID:       keyID,
+		Number:      number,
+		Text:        text,
+		Encoding:    segments.Encoding,
+		Segments:    segments.Count,
+		CallbackURL: callbackURL,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	m.setStatus(phoneapi.StatusQueued, "", now)
+
+	// "queued" kaydı worker'ın "sending" kaydından önce yazılsın diye kuyruğa kilit altında eklenir
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.messages[m.ID] = m
+	q.append(m)
+	select {
+	case q.jobs <- m.ID:
+		return *m, nil
+	default:
+		delete(q.messages, m.ID)
+		m.Removed = true
+		q.append(m)
+		return Message{}, errQueueFull
+	}
+}
+
+// find mesajı döner. keyID verilmişse yalnızca o anahtarla gönderilen mesajlar görünür.
+func (q *messageQueue) find(id, keyID string) (Message, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	m, ok := q.messages[id]
+	if !ok || (keyID != "" && m.KeyID != keyID) {
+		return Message{}, false
+	}
+	return *m, true
+}
+
+// list anahtarın mesajlarını numara ve duruma göre filtreleyip en yeniden eskiye döner
+func (q *messageQueue) list(keyID, number, status string, limit int) []Message {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	list := []Message{}
+	sorted := q.sorted()
+	for i := len(sorted) - 1; i >= 0 && (limit <= 0 || len(list) < limit); i-- {
+		m := sorted[i]
+		if m.KeyID != keyID || (number != "" && m.Number != number) || (status != "" && m.Status != status) {
+			continue
+		}
+		list = append(list, *m)
+	}
+	return list
+}
+
+// update mesajı kilit altında günceller ve dosyaya yazar
+func (q *messageQueue) update(id string, fn func(m *Message)) Message {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	m := q.messages[id]
+	fn(m)
+	m.UpdatedAt = time.Now()
+	q.append(m)
+	return *m
+}
+
+// receipt sağlayıcının teslim raporunu referansa göre mesaja işler. Geride kalan
+// durumlar (örn. delivered'dan sonra gelen sent) yok sayılır. Sağlayıcı raporu referans
+// kaydedilmeden önce gönderebilir; bilinmeyen referansın raporu bekletilir ve
+// phoneapi.ErrReceiptPending döner.
+func (q *messageQueue) receipt(providerRef, status, errMsg string, at time.Time) (Message, error) {
+	now := time.Now()
+	if at.IsZero() {
+		at = now
+	}
+	if providerRef == "" {
+		return Message{}, phoneapi.ErrDeliveryNotFound
+	}
+
+	q.mu.Lock()
+	m, ok := q.messages[q.byProviderRef[providerRef]]
+	if !ok {
+		held := q.pending.Hold(phoneapi.Receipt{MessageID: providerRef, Status: status, Error: errMsg, UpdatedAt: at}, now)
+		q.mu.Unlock()
+		if held {
+			return Message{}, phoneapi.ErrReceiptPending
+		}
+		return Message{}, phoneapi.ErrDeliveryNotFound
+	}
+	changed := phoneapi.StatusAdvances(m.Status, status)
+	if changed {
+		m.setStatus(status, errMsg, at)
+		m.UpdatedAt = at
+		q.append(m)
+	}
+	snapshot := *m
+	q.mu.Unlock()
+
+	if changed {
+		go q.notify(snapshot)
+	}
+	return snapshot, nil
+}
+
+// work kuyruktan mesaj alıp gönderir, hata olursa artan beklemeyle tekrar dener
+func (q *messageQueue) work() {
+	for id := range q.jobs {
+		m := q.update(id, func(m *Message) { m.setStatus(phoneapi.StatusSending, "", time.Now()) })
+
+		var ref string
+		var err error
+		for attempt := 1; attempt <= maxSendAttempts; attempt++ {
+			q.update(id, func(m *Message) { m.Attempts = attempt })
+			if ref, err = q.provider.Send(m.Number, m.Text); err == nil {
+				break
+			}
+			log.Printf("sms %s: attempt %d: %v", id, attempt, err)
+			if !retryable(err) {
+				// Mesaj sağlayıcıya ulaşmış olabilir, tekrar göndermek yerine failed işaretlenir
+				break
+			}
+			if attempt < maxSendAttempts {
+				time.Sleep(time.Duration(attempt) * 2 * time.Second)
+			}
+		}
+
+		m = q.update(id, func(m *Message) {
+			m.Text = ""
+			if err != nil {
+				m.setStatus(phoneapi.StatusFailed, err.Error(), time.Now())
+				return
+			}
+			now := time.Now()
+			m.ProviderRef = ref
+			m.setStatus(phoneapi.StatusSent, "", now)
+			if ref == "" {
+				return
+			}
+			q.byProviderRef[ref] = m.ID
+			// Gönderim dönmeden gelmiş raporlar
+			for _, r := range q.pending.Take(ref, now) {
+				if phoneapi.StatusAdvances(m.Status, r.Status) {
+					m.setStatus(r.Status, r.Error, r.UpdatedAt)
+				}
+			}
+		})
+		q.notify(m)
+	}
+}
+
+// notify mesajın yeni durumunu callback_url'e gönderir
+func (q *messageQueue) notify(m Message) {
+	if m.CallbackURL == "" {
+		return
+	}
+	body, _ := json.Marshal(phoneapi.Receipt{
+		MessageID: m.ID,
+		Status:    m.Status,
+		Error:     m.Error,
+		UpdatedAt: m.UpdatedAt,
+	})
+
+	for attempt := 1; attempt <= maxCallbackAttempts; attempt++ {
+		resp, err := q.client.Post(m.CallbackURL, "application/json", bytes.NewReader(body))
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode < 300 {
+				return
+			}
+			err = fmt.Errorf("status %d", resp.StatusCode)
+		}
+		log.Printf("sms %s: callback attempt %d: %v", m.ID, attempt, err)
+		time.Sleep(time.Duration(attempt) * 2 * time.Second)
+	}
+}
+
+// sorted mesajları oluşturulma sırasına göre döner, kilit altında çağrılır
+func (q *messageQueue) sorted() []*Message {
+	list := make([]*Message, 0, len(q.messages))
+	for _, m := range q.messages {
+		list = append(list, m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list
+}
+
+// append mesajın son halini dosyaya ekler, kilit altında çağrılır
+func (q *messageQueue) append(m *Message) {
+	if q.file == nil {
+		return
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		log.Printf("sms %s: encode: %v", m.ID, err)
+		return
+	}
+	if _, err := q.file.Write(append(data, '\n')); err != nil {
+		log.Printf("sms status log: write %s: %v", q.path, err)
+	}
+}
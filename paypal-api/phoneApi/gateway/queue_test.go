@@ -0,0 +1,395 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"paypal/phoneApi"
+)
+
+// stubProvider ilk fail gönderimde hata döner, sonrakileri kaydeder. err verilmezse
+// mesajın gönderilmediği kesin olan bir hata döner.
+type stubProvider struct {
+	mu    sync.Mutex
+	fail  int
+	err   error
+	calls int
+	sent  []string
+}
+
+func (p *stubProvider) Send(number, text string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	if p.calls <= p.fail {
+		if p.err != nil {
+			return "", p.err
+		}
+		return "", &notSentError{errors.New("modem: +CMS ERROR: 304")}
+	}
+	p.sent = append(p.sent, text)
+	return "ref-" + number, nil
+}
+
+// waitStatus mesaj status durumuna gelene kadar bekler
+func waitStatus(t *testing.T, q *messageQueue, id, status string) Message {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		m, ok := q.find(id, "")
+		if ok && m.Status == status {
+			return m
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("message %s is %q, want %q", id, m.Status, status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestKeyStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	s := openKeyStore(path)
+
+	key, secret := s.create("shop")
+	if got, ok := s.authenticate(secret); !ok || got.ID != key.ID {
+		t.Fatalf("authenticate(secret) = %+v, %v", got, ok)
+	}
+	if _, ok := s.authenticate(""); ok {
+		t.Error("empty key was accepted")
+	}
+	if again := s.add("shop-again", secret); again.ID != key.ID {
+		t.Errorf("adding the same secret created a second key %s", again.ID)
+	}
+	if strings.Contains(s.list()[0].Hash, secret) {
+		t.Error("plain key is stored")
+	}
+
+	reopened := openKeyStore(path)
+	if _, ok := reopened.authenticate(secret); !ok {
+		t.Fatal("key did not survive a restart")
+	}
+	if _, err := reopened.revoke(key.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reopened.authenticate(secret); ok {
+		t.Error("revoked key was accepted")
+	}
+	if _, ok := openKeyStore(path).authenticate(secret); ok {
+		t.Error("revocation did not survive a restart")
+	}
+	if _, err := reopened.revoke("key_missing"); err == nil {
+		t.Error("revoking an unknown key succeeded")
+	}
+}
+
+// newTestQueue path'e durum kaydı yazan kuyruk açar
+func newTestQueue(t *testing.T, provider Provider, path string, size, workers int) *messageQueue {
+	t.Helper()
+	q, err := newMessageQueue(provider, path, size, workers, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+func TestQueueSendAndReceipt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status.jsonl")
+	provider := &stubProvider{}
+	q := newTestQueue(t, provider, path, 10, 1)
+
+	queued, err := q.enqueue("key-1", "+905321234567", "Your code is 987650", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if queued.Status != phoneapi.StatusQueued {
+		t.Errorf("status = %q, want %q", queued.Status, phoneapi.StatusQueued)
+	}
+	sent := waitStatus(t, q, queued.ID, phoneapi.StatusSent)
+	if sent.ProviderRef != "ref-+905321234567" || sent.Attempts != 1 {
+		t.Errorf("sent message = %+v", sent)
+	}
+	// Doğrulama kodu ne dosyaya ne de mesaj kaydına kalır
+	if sent.Text != "" {
+		t.Errorf("text kept after sending: %q", sent.Text)
+	}
+	if data, _ := os.ReadFile(path); strings.Contains(string(data), "987650") {
+		t.Error("message text written to the status file")
+	}
+
+	if _, ok := q.find(queued.ID, "key-2"); ok {
+		t.Error("message is visible to another key")
+	}
+	if _, ok := q.find(queued.ID, "key-1"); !ok {
+		t.Error("message is not visible to its own key")
+	}
+
+	m, err := q.receipt(sent.ProviderRef, phoneapi.StatusDelivered, "", time.Time{})
+	if err != nil || m.Status != phoneapi.StatusDelivered {
+		t.Fatalf("receipt = %+v, %v", m, err)
+	}
+	// Geride kalan rapor durumu geri almaz
+	if m, _ := q.receipt(sent.ProviderRef, phoneapi.StatusSent, "", time.Time{}); m.Status != phoneapi.StatusDelivered {
+		t.Errorf("late sent receipt changed the status to %q", m.Status)
+	}
+	if _, err := q.receipt("", phoneapi.StatusDelivered, "", time.Time{}); !errors.Is(err, phoneapi.ErrDeliveryNotFound) {
+		t.Errorf("receipt without a reference = %v", err)
+	}
+	var statuses []string
+	for _, e := range m.History {
+		statuses = append(statuses, e.Status)
+	}
+	if got := strings.Join(statuses, ","); got != "queued,sending,sent,delivered" {
+		t.Errorf("history = %s", got)
+	}
+
+	reopened := newTestQueue(t, provider, path, 10, 1)
+	if m, ok := reopened.find(queued.ID, "key-1"); !ok || m.Status != phoneapi.StatusDelivered {
+		t.Errorf("reloaded message = %+v, %v", m, ok)
+	}
+	if got := reopened.list("key-1", "+905321234567", phoneapi.StatusDelivered, 0); len(got) != 1 {
+		t.Errorf("list after reload = %+v", got)
+	}
+	encoded, _ := json.Marshal(m)
+	if strings.Contains(string(encoded), "987650") {
+		t.Errorf("API response contains the message text: %s", encoded)
+	}
+	// Yeniden açılışta referans indeksi dosyadan kurulur
+	if _, err := reopened.receipt(sent.ProviderRef, phoneapi.StatusFailed, "", time.Time{}); err != nil {
+		t.Errorf("receipt after reload: %v", err)
+	}
+}
+
+func TestQueueRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status.jsonl")
+	now := time.Now()
+	var lines []string
+	for _, m := range []Message{
+		{ID: "msg_sending", Number: "+905321234567", Status: phoneapi.StatusSending, CreatedAt: now},
+		{ID: "msg_queued", Number: "+905321234568", Status: phoneapi.StatusQueued, CreatedAt: now.Add(time.Second)},
+	} {
+		data, _ := json.Marshal(m)
+		lines = append(lines, string(data))
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	provider := &stubProvider{}
+	q := newTestQueue(t, provider, path, 10, 1)
+	// Gönderilip gönderilmediği bilinmeyen mesaj tekrar gönderilmez
+	if m, _ := q.find("msg_sending", ""); m.Status != phoneapi.StatusFailed {
+		t.Errorf("interrupted message = %+v, want failed", m)
+	}
+	// Metin dosyada saklanmadığı için kuyruktaki mesaj da gönderilemez
+	if m, _ := q.find("msg_queued", ""); m.Status != phoneapi.StatusFailed {
+		t.Errorf("queued message = %+v, want failed", m)
+	}
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+	if len(provider.sent) != 0 {
+		t.Errorf("provider received %q", provider.sent)
+	}
+}
+
+func TestQueueRetriesFailedSend(t *testing.T) {
+	provider := &stubProvider{fail: 1}
+	q := newTestQueue(t, provider, "", 10, 1)
+
+	queued, _ := q.enqueue("key-1", "+905321234567", "hello", "")
+	sent := waitStatus(t, q, queued.ID, phoneapi.StatusSent)
+	if sent.Attempts != 2 || sent.Error != "" {
+		t.Errorf("sent message = %+v", sent)
+	}
+}
+
+// blockingProvider gönderimi release kapanana kadar bekletir
+type blockingProvider struct {
+	called  chan struct{}
+	release chan struct{}
+}
+
+func (p *blockingProvider) Send(number, text string) (string, error) {
+	close(p.called)
+	<-p.release
+	return "ref-early", nil
+}
+
+func TestQueueHoldsEarlyReceipt(t *testing.T) {
+	provider := &blockingProvider{called: make(chan struct{}), release: make(chan struct{})}
+	q := newTestQueue(t, provider, "", 10, 1)
+
+	queued, _ := q.enqueue("key-1", "+905321234567", "hello", "")
+	<-provider.called
+	// Sağlayıcı raporu Send dönmeden gönderir
+	if _, err := q.receipt("ref-early", phoneapi.StatusDelivered, "", time.Time{}); !errors.Is(err, phoneapi.ErrReceiptPending) {
+		t.Fatalf("early receipt = %v, want %v", err, phoneapi.ErrReceiptPending)
+	}
+	close(provider.release)
+
+	m := waitStatus(t, q, queued.ID, phoneapi.StatusDelivered)
+	if m.ProviderRef != "ref-early" || len(m.History) != 4 {
+		t.Errorf("message after the held receipt = %+v", m)
+	}
+}
+
+func TestQueueDoesNotRetryAmbiguousErrors(t *testing.T) {
+	// Zaman aşımında mesaj gönderilmiş olabilir
+	provider := &stubProvider{fail: 1, err: errors.New("modem: read /dev/ttyUSB0: i/o timeout")}
+	q := newTestQueue(t, provider, "", 10, 1)
+
+	queued, _ := q.enqueue("key-1", "+905321234567", "hello", "")
+	failed := waitStatus(t, q, queued.ID, phoneapi.StatusFailed)
+	if failed.Attempts != 1 || !strings.Contains(failed.Error, "timeout") {
+		t.Errorf("failed message = %+v", failed)
+	}
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+	if provider.calls != 1 {
+		t.Errorf("provider called %d times, want 1", provider.calls)
+	}
+}
+
+func TestQueueFull(t *testing.T) {
+	// Worker olmadan kuyruk boşalmaz
+	q := newTestQueue(t, &stubProvider{}, "", 1, 0)
+	if _, err := q.enqueue("key-1", "+905321234567", "first", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.enqueue("key-1", "+905321234567", "second", ""); !errors.Is(err, errQueueFull) {
+		t.Errorf("enqueue on a full queue = %v, want %v", err, errQueueFull)
+	}
+	if n := len(q.messages); n != 1 {
+		t.Errorf("%d messages stored, want 1", n)
+	}
+}
+
+func TestQueueFullWritesRemovalRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status.jsonl")
+	q := newTestQueue(t, &stubProvider{}, path, 1, 0)
+	if _, err := q.enqueue("key-1", "+905321234567", "first", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.enqueue("key-1", "+905321234568", "second", ""); !errors.Is(err, errQueueFull) {
+		t.Fatalf("enqueue on a full queue = %v, want %v", err, errQueueFull)
+	}
+
+	// Kabul edilmeyen mesajın kaydı yüklemede atlanır
+	reopened := newTestQueue(t, &stubProvider{}, path, 1, 0)
+	if got := reopened.list("key-1", "", "", 0); len(got) != 1 || got[0].Number != "+905321234567" {
+		t.Errorf("reloaded messages = %+v, want only the accepted one", got)
+	}
+}
+
+func TestQueueExpire(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status.jsonl")
+	q, err := newMessageQueue(&stubProvider{}, path, 10, 1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := q.enqueue("key-1", "+905321234567", "hello", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent := waitStatus(t, q, m.ID, phoneapi.StatusSent)
+
+	q.expire(time.Now())
+	if _, ok := q.find(m.ID, ""); !ok {
+		t.Fatal("message was removed before its retention period")
+	}
+	q.expire(time.Now().Add(2 * time.Hour))
+	if _, ok := q.find(m.ID, ""); ok {
+		t.Fatal("expired message is still in memory")
+	}
+	if _, ok := q.byProviderRef[sent.ProviderRef]; ok {
+		t.Error("provider reference of an expired message is still indexed")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 0 {
+		t.Errorf("status log was not compacted:\n%s", data)
+	}
+}
+
+func TestHandleSendSMS(t *testing.T) {
+	oldKeys, oldQueue, oldHosts := keys, queue, callbackHosts
+	t.Cleanup(func() { keys, queue, callbackHosts = oldKeys, oldQueue, oldHosts })
+	callbackHosts = []string{"shop.example.com"}
+	keys = openKeyStore("")
+	keys.add("shop", "test-key")
+	queue = newTestQueue(t, &stubProvider{}, "", 10, 0)
+
+	handler := requireKey(handleSendSMS)
+	tests := []struct {
+		name   string
+		key    string
+		body   string
+		status int
+	}{
+		{"missing key", "", `{"number": "+4915123456789", "message": "hi"}`, http.StatusUnauthorized},
+		{"wrong key", "other", `{"number": "+4915123456789", "message": "hi"}`, http.StatusUnauthorized},
+		{"invalid json", "test-key", `{`, http.StatusBadRequest},
+		{"missing message", "test-key", `{"number": "+4915123456789"}`, http.StatusBadRequest},
+		{"landline", "test-key", `{"number": "+49301234567", "message": "hi"}`, http.StatusBadRequest},
+		{"relative callback", "test-key", `{"number": "+4915123456789", "message": "hi", "callback_url": "/dlr"}`, http.StatusBadRequest},
+		{"callback to another host", "test-key", `{"number": "+4915123456789", "message": "hi", "callback_url": "http://169.254.169.254/latest"}`, http.StatusBadRequest},
+		{"queued", "test-key", `{"number": "+4915123456789", "message": "hi"}`, http.StatusOK},
+		{"queued with callback", "test-key", `{"number": "+4915123456789", "message": "hi", "callback_url": "https://SHOP.example.com:8443/sms/receipts"}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/send-sms", strings.NewReader(tt.body))
+			req.Header.Set("API-Key", tt.key)
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+		})
+	}
+}
+
+func TestHandleDeliveryReceipt(t *testing.T) {
+	oldQueue, oldToken := queue, dlrToken
+	t.Cleanup(func() { queue, dlrToken = oldQueue, oldToken })
+	queue = newTestQueue(t, &stubProvider{}, "", 10, 1)
+	dlrToken = "dlr-secret"
+
+	m, _ := queue.enqueue("key-1", "+905321234567", "hello", "")
+	waitStatus(t, queue, m.ID, phoneapi.StatusSent)
+
+	tests := []struct {
+		name   string
+		token  string
+		body   string
+		status int
+	}{
+		{"wrong token", "other", `{"message_id": "ref-+905321234567", "status": "delivered"}`, http.StatusUnauthorized},
+		{"unknown status", "dlr-secret", `{"message_id": "ref-+905321234567", "status": "read"}`, http.StatusBadRequest},
+		{"unknown reference", "dlr-secret", `{"message_id": "ref-x", "status": "delivered"}`, http.StatusAccepted},
+		{"delivered", "dlr-secret", `{"message_id": "ref-+905321234567", "status": "delivered"}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/delivery-receipt?token="+tt.token, strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			handleDeliveryReceipt(rec, req)
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+		})
+	}
+	if m, _ := queue.find(m.ID, ""); m.Status != phoneapi.StatusDelivered {
+		t.Errorf("status after receipt = %q", m.Status)
+	}
+}
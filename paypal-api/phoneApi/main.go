@@ -1,48 +1,93 @@
+// Package phoneapi SMS gönderimi için sağlayıcılar (/send-sms gateway istemcisi, konsol,
+// dosya ve failover) ve bunların üzerine kurulu SMS doğrulama (OTP) servisidir.
+// Gateway API dokümantasyonu dosyanın sonundadır.
 package phoneapi
 
 import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"time"
 )
 
-func main() {
-	url := "http://<your-server-ip>:5000/send-sms"
-	apiKey := "your_api_key"
+// Client SMS gateway'ine API-Key ile istek atan istemci
+type Client struct {
+	URL    string // örn. http://<your-server-ip>:5000/send-sms
+	APIKey string
+	// CallbackURL verilirse gateway teslim durumu değiştikçe bu adrese POST atar
+	CallbackURL string
+	HTTPClient  *http.Client
+}
+
+// NewClient gateway adresi ve API anahtarı ile istemci oluşturur
+func NewClient(url, apiKey string) *Client {
+	return &Client{
+		URL:        url,
+		APIKey:     apiKey,
+		HTTPClient: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// SendError gateway'in 200 dışında döndüğü yanıt
+type SendError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *SendError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("sms gateway: status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("sms gateway: status %d: %s", e.StatusCode, e.Message)
+}
+
+// Send mesajı gateway kuyruğuna ekler ve gateway'in verdiği mesaj ID'sini döner.
+// Gateway 200 dönmezse *SendError döner.
+func (c *Client) Send(number, message string) (SendResult, error) {
 	payload := map[string]string{
-		"number":  "+491234567890",
-		"message": "Your verification code is: 123456",
+		"number":  number,
+		"message": message,
+	}
+	if c.CallbackURL != "" {
+		payload["callback_url"] = c.CallbackURL
 	}
 
 	jsonData, err := json.Marshal(payload)
 	if err != nil {
-		fmt.Println("Error marshalling JSON:", err)
-		return
+		return SendResult{}, err
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest("POST", c.URL, bytes.NewBuffer(jsonData))
 	if err != nil {
-		fmt.Println("Error creating request:", err)
-		return
+		return SendResult{}, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("API-Key", apiKey)
+	req.Header.Set("API-Key", c.APIKey)
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
-		fmt.Println("Error sending request:", err)
-		return
+		return SendResult{}, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusOK {
-		fmt.Println("SMS successfully sent")
-	} else {
-		fmt.Printf("Failed to send SMS. Status code: %d\n", resp.StatusCode)
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode != http.StatusOK {
+		var result struct {
+			Error string `json:"error"`
+		}
+		json.Unmarshal(body, &result)
+		return SendResult{}, &SendError{StatusCode: resp.StatusCode, Message: result.Error}
 	}
+
+	var result SendResult
+	json.Unmarshal(body, &result)
+	if result.Status == "" {
+		result.Status = StatusQueued
+	}
+	return result, nil
 }
 
 /*
@@ -73,7 +118,8 @@ Send a JSON payload with the recipient’s phone number and the message content.
 ⁠ json
 {
   "number": "+491234567890",  // Recipient's phone number in international format
-  "message": "Your verification code is: 123456"  // The SMS message content
+  "message": "Your verification code is: 123456",  // The SMS message content
+  "callback_url": "https://shop.example.com/sms/receipts?token=..."  // Optional, receives status updates
 }
  ⁠
 
@@ -87,12 +133,19 @@ curl -X POST http://<your-server-ip>:5000/send-sms \
 
 ## *Responses*:
 
-•⁠  ⁠*200 OK*: The SMS was successfully sent.
+•⁠  ⁠*200 OK*: The SMS was accepted and queued for delivery.
   ⁠ json
   {
     "success": true,
-    "message": "SMS successfully sent"
+    "message": "SMS queued for delivery",
+    "message_id": "msg_6f1c2a...",
+    "status": "queued",
+    "encoding": "GSM-7",
+    "segments": 1
   }
+  `segments` is the number of SMS parts the operator will bill. A GSM-7 message fits 160 characters
+  (153 per part when split); a single character outside GSM-7, e.g. Turkish `ı`, `ğ`, `ş` or an emoji,
+  switches the whole message to UCS-2 with 70 characters (67 per part).
    ⁠
 
 •⁠  ⁠*400 Bad Request*: Missing required fields (⁠ number ⁠ or ⁠ message ⁠).
@@ -109,6 +162,32 @@ curl -X POST http://<your-server-ip>:5000/send-sms \
   }
    ⁠
 
+• *400 Bad Request*: The number is invalid or can not receive SMS (e.g. a landline). Numbers without `+` are read in the gateway's `SMS_DEFAULT_REGION` (default `TR`), so `0532 123 45 67` becomes `+905321234567`.
+  {
+    "error": "Invalid phone number: phone number can not receive SMS: +902121234567 is a fixed_line number"
+  }
+
+• *503 Service Unavailable*: The delivery queue is full. Retry after the number of seconds in the `Retry-After` header.
+  {
+    "error": "Queue is full, try again later"
+  }
+
+## *Delivery Status*:
+`GET /status?id=<message_id>` with the same `API-Key` returns the message, its status and the status history
+(the text itself is never stored or returned, since it may contain a verification code):
+`queued`, `sending`, `sent` (handed to the operator), `delivered` (delivery receipt received) or `failed`.
+`GET /messages?number=<number>&status=<status>&limit=<n>` lists the latest messages. A key can only see its own messages.
+
+If `callback_url` was given, every status change is POSTed to it. The gateway only calls hosts listed in
+`SMS_CALLBACK_HOSTS` and does not follow redirects:
+  {
+    "message_id": "msg_6f1c2a...",
+    "status": "delivered",
+    "updated_at": "2024-05-01T12:00:03Z"
+  }
+Providers report delivery with the same body to `POST /delivery-receipt?token=<SMS_DLR_TOKEN>`,
+where `message_id` is the provider's reference (`provider_ref`). Statuses never move backwards.
+
 ## *Error Handling*:
 Make sure to provide both the phone number and message in the correct format and include the valid API key in the headers. Incorrect or missing data will result in an error response.
 */
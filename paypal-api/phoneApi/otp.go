@@ -0,0 +1,428 @@
+package phoneapi
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OTP hataları
+var (
+	ErrNoCode            = errors.New("no verification code was requested for this number")
+	ErrCodeExpired       = errors.New("verification code expired")
+	ErrInvalidCode       = errors.New("invalid verification code")
+	ErrTooManyAttempts   = errors.New("too many attempts")
+	ErrResendCooldown    = errors.New("please wait before requesting a new code")
+	ErrTooManyCodes      = errors.New("too many codes requested for this number")
+	ErrClientLimit       = errors.New("too many codes requested from this client")
+	ErrSendBudget        = errors.New("verification code budget exhausted, please try again later")
+	ErrMissingNumber     = errors.New("number is required")
+	ErrMissingOTPPayload = errors.New("number and code are required")
+)
+
+// OTPConfig doğrulama kodu ayarları
+type OTPConfig struct {
+	Length         int           // kod uzunluğu
+	TTL            time.Duration // kodun geçerlilik süresi
+	MaxAttempts    int           // bir kod için en fazla yanlış deneme
+	ResendCooldown time.Duration // iki gönderim arasında beklenecek süre
+	MaxSends       int           // SendWindow içinde bir numaraya en fazla gönderim
+	SendWindow     time.Duration
+	// Farklı numaralara kod gönderterek SMS maliyeti çıkarılmasına karşı (SMS pumping) istemci başına
+	// ve tüm servis için SendWindow içindeki gönderim sınırı. 0 ise sınır uygulanmaz.
+	MaxSendsPerClient int
+	MaxSendsTotal     int
+	TrustProxy        bool         // istemci adresi X-Forwarded-For'un son elemanından alınır
+	Templates         *Templates   // mesaj TemplateOTP şablonu ile oluşturulur
+	Locale            string       // istekte dil belirtilmezse kullanılır
+	DefaultRegion     string       // "+" ile başlamayan numaralar için bölge, örn. "TR"
+	Deliveries        *DeliveryLog // verilirse gönderilen kodlar teslim takibi için kaydedilir
+}
+
+// DefaultOTPConfig 6 haneli, 5 dakika geçerli kod
+var DefaultOTPConfig = OTPConfig{
+	Length:            6,
+	TTL:               5 * time.Minute,
+	MaxAttempts:       5,
+	ResendCooldown:    60 * time.Second,
+	MaxSends:          5,
+	SendWindow:        time.Hour,
+	MaxSendsPerClient: 10,
+	MaxSendsTotal:     500,
+	Templates:         DefaultTemplates,
+	Locale:            "en",
+	DefaultRegion:     "TR",
+}
+
+// otpEntry bir numara için bekleyen kod. Kodun kendisi değil yalnızca HMAC'i tutulur.
+type otpEntry struct {
+	hash     []byte
+	expires  time.Time
+	attempts int
+	sentAt   time.Time
+	sends    []time.Time
+}
+
+// OTPService kod üretir, SMS ile gönderir ve doğrular
+type OTPService struct {
+	sender SMSSender
+	cfg    OTPConfig
+	secret []byte
+
+	mu      sync.Mutex
+	entries map[string]*otpEntry
+	clients map[string][]time.Time // istemci adresine göre gönderimler
+	sends   []time.Time            // tüm gönderimler
+}
+
+// NewOTPService kodları sender üzerinden gönderen servis oluşturur
+func NewOTPService(sender SMSSender, cfg OTPConfig) *OTPService {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+	return &OTPService{
+		sender:  sender,
+		cfg:     cfg,
+		secret:  secret,
+		entries: make(map[string]*otpEntry),
+		clients: make(map[string][]time.Time),
+	}
+}
+
+// CooldownError yeniden gönderim için beklenmesi gereken süreyi taşır
+type CooldownError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *CooldownError) Error() string { return e.Err.Error() }
+func (e *CooldownError) Unwrap() error { return e.Err }
+
+// Send numaraya locale dilinde yeni bir kod gönderir ve mesaj ID'sini döner. Önceki kod geçersiz olur.
+// İstemci sınırı uygulanmaz, genel gönderim bütçesi uygulanır.
+func (s *OTPService) Send(number, locale string) (string, error) {
+	return s.SendFrom("", number, locale)
+}
+
+// SendFrom Send gibidir, ayrıca client adresinden gelen gönderimleri MaxSendsPerClient ile sınırlar
+func (s *OTPService) SendFrom(client, number, locale string) (string, error) {
+	number = strings.TrimSpace(number)
+	if number == "" {
+		return "", ErrMissingNumber
+	}
+	// Aynı numaranın farklı yazımları tek kayıt sayılır, SMS alamayan numaralara gönderim yapılmaz
+	phone, err := ParseSMSNumber(number, s.cfg.DefaultRegion)
+	if err != nil {
+		return "", err
+	}
+	number = phone.E164
+
+	code, err := generateCode(s.cfg.Length)
+	if err != nil {
+		return "", err
+	}
+	if locale == "" {
+		locale = s.cfg.Locale
+	}
+	message, err := s.cfg.Templates.Render(locale, TemplateOTP, struct {
+		Code    string
+		Minutes int
+	}{code, int(s.cfg.TTL.Minutes())})
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	now := time.Now()
+	s.sweep(now)
+
+	entry := s.entries[number]
+	if entry == nil {
+		entry = &otpEntry{}
+	}
+	if wait := s.cfg.ResendCooldown - now.Sub(entry.sentAt); !entry.sentAt.IsZero() && wait > 0 {
+		s.mu.Unlock()
+		return "", &CooldownError{Err: ErrResendCooldown, RetryAfter: wait}
+	}
+	entry.sends = recentSends(entry.sends, now, s.cfg.SendWindow)
+	if len(entry.sends) >= s.cfg.MaxSends {
+		s.mu.Unlock()
+		return "", &CooldownError{Err: ErrTooManyCodes, RetryAfter: entry.sends[0].Add(s.cfg.SendWindow).Sub(now)}
+	}
+	var clientSends []time.Time
+	if client != "" && s.cfg.MaxSendsPerClient > 0 {
+		clientSends = recentSends(s.clients[client], now, s.cfg.SendWindow)
+		if len(clientSends) >= s.cfg.MaxSendsPerClient {
+			s.clients[client] = clientSends
+			s.mu.Unlock()
+			return "", &CooldownError{Err: ErrClientLimit, RetryAfter: clientSends[0].Add(s.cfg.SendWindow).Sub(now)}
+		}
+	}
+	s.sends = recentSends(s.sends, now, s.cfg.SendWindow)
+	if s.cfg.MaxSendsTotal > 0 && len(s.sends) >= s.cfg.MaxSendsTotal {
+		s.mu.Unlock()
+		log.Printf("otp: send budget of %d codes per %s exhausted", s.cfg.MaxSendsTotal, s.cfg.SendWindow)
+		return "", &CooldownError{Err: ErrSendBudget, RetryAfter: s.sends[0].Add(s.cfg.SendWindow).Sub(now)}
+	}
+
+	previous := *entry
+	entry.hash = s.hash(number, code)
+	entry.expires = now.Add(s.cfg.TTL)
+	entry.attempts = 0
+	entry.sentAt = now
+	entry.sends = append(entry.sends, now)
+	s.entries[number] = entry
+	if client != "" && s.cfg.MaxSendsPerClient > 0 {
+		s.clients[client] = append(clientSends, now)
+	}
+	s.sends = append(s.sends, now)
+	s.mu.Unlock()
+
+	result, err := s.sender.Send(number, message)
+	if err != nil {
+		// Gönderilemeyen kod sayılmaz, kullanıcı beklemeden tekrar deneyebilir
+		s.mu.Lock()
+		if s.entries[number] == entry {
+			*entry = previous
+		}
+		if sends, ok := s.clients[client]; ok {
+			s.clients[client] = dropSend(sends, now)
+		}
+		s.sends = dropSend(s.sends, now)
+		s.mu.Unlock()
+		return "", fmt.Errorf("send verification code: %w", err)
+	}
+
+	if s.cfg.Deliveries == nil {
+		return result.MessageID, nil
+	}
+	d := s.cfg.Deliveries.Record(Delivery{
+		ID:       result.MessageID,
+		Number:   number,
+		Template: TemplateOTP,
+		Segments: CountSegments(message).Count,
+		Status:   result.Status,
+	})
+	return d.ID, nil
+}
+
+// Verify kodu kontrol eder. Doğru kod bir kez kullanılabilir.
+func (s *OTPService) Verify(number, code string) error {
+	number = strings.TrimSpace(number)
+	code = strings.TrimSpace(code)
+	if number == "" || code == "" {
+		return ErrMissingOTPPayload
+	}
+	phone, err := ParsePhone(number, s.cfg.DefaultRegion)
+	if err != nil {
+		return err
+	}
+	number = phone.E164
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[number]
+	if !ok || entry.hash == nil {
+		return ErrNoCode
+	}
+	if time.Now().After(entry.expires) {
+		entry.hash = nil
+		return ErrCodeExpired
+	}
+	if entry.attempts >= s.cfg.MaxAttempts {
+		return ErrTooManyAttempts
+	}
+
+	if !hmac.Equal(entry.hash, s.hash(number, code)) {
+		entry.attempts++
+		if entry.attempts >= s.cfg.MaxAttempts {
+			// Kod kilitlenir, yeni kod istenmesi gerekir
+			entry.hash = nil
+			return ErrTooManyAttempts
+		}
+		return ErrInvalidCode
+	}
+
+	entry.hash = nil
+	return nil
+}
+
+// hash kodu numara ile birlikte HMAC'ler, böylece aynı kod farklı numaralarda farklı saklanır
+func (s *OTPService) hash(number, code string) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(number + ":" + code))
+	return mac.Sum(nil)
+}
+
+// sweep süresi ve gönderim penceresi dolmuş kayıtları siler, kilit altında çağrılır
+func (s *OTPService) sweep(now time.Time) {
+	for number, entry := range s.entries {
+		if now.After(entry.expires) && now.Sub(entry.sentAt) > s.cfg.SendWindow {
+			delete(s.entries, number)
+		}
+	}
+	for client, sends := range s.clients {
+		if len(sends) == 0 || now.Sub(sends[len(sends)-1]) >= s.cfg.SendWindow {
+			delete(s.clients, client)
+		}
+	}
+}
+
+// recentSends pencere içindeki gönderim zamanlarını döner
+func recentSends(sends []time.Time, now time.Time, window time.Duration) []time.Time {
+	recent := sends[:0]
+	for _, at := range sends {
+		if now.Sub(at) < window {
+			recent = append(recent, at)
+		}
+	}
+	return recent
+}
+
+// dropSend başarısız gönderimin zamanını listeden çıkarır
+func dropSend(sends []time.Time, at time.Time) []time.Time {
+	for i := len(sends) - 1; i >= 0; i-- {
+		if sends[i].Equal(at) {
+			return append(sends[:i], sends[i+1:]...)
+		}
+	}
+	return sends
+}
+
+// clientAddr isteğin geldiği IP adresi. Ters vekil arkasında X-Forwarded-For'un son elemanı
+// vekilin gördüğü adrestir; istemcinin kendi eklediği önceki elemanlara güvenilmez.
+func (s *OTPService) clientAddr(r *http.Request) string {
+	if s.cfg.TrustProxy {
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			list := strings.Split(forwarded[len(forwarded)-1], ",")
+			if addr := strings.TrimSpace(list[len(list)-1]); addr != "" {
+				return addr
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// generateCode crypto/rand ile eşit dağılımlı, başında sıfır olabilen sayısal kod üretir
+func generateCode(length int) (string, error) {
+	max := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(length)), nil)
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", length, n), nil
+}
+
+// otpRequest /otp/send ve /otp/verify gövdesi
+type otpRequest struct {
+	Number string `json:"number"`
+	Code   string `json:"code,omitempty"`
+	Locale string `json:"locale,omitempty"`
+}
+
+/*
+	Doğrulama kodu gönderir. locale verilmezse Accept-Language başlığı kullanılır:
+	curl -X POST http://localhost:3000/otp/send -H "Content-Type: application/json" \
+	-d '{"number": "+491234567890", "locale": "de"}'
+*/
+
+func (s *OTPService) HandleSend(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req otpRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+
+	locale := req.Locale
+	if locale == "" {
+		locale = r.Header.Get("Accept-Language")
+	}
+
+	messageID, err := s.SendFrom(s.clientAddr(r), req.Number, locale)
+	var cooldown *CooldownError
+	switch {
+	case err == nil:
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"success":    true,
+			"expires_in": int(s.cfg.TTL.Seconds()),
+			"message_id": messageID,
+		})
+	case errors.Is(err, ErrMissingNumber):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case IsNumberError(err):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case errors.As(err, &cooldown):
+		w.Header().Set("Retry-After", strconv.Itoa(int(cooldown.RetryAfter.Seconds())+1))
+		writeError(w, http.StatusTooManyRequests, err.Error())
+	default:
+		log.Println("otp:", err)
+		writeError(w, http.StatusBadGateway, "Failed to send verification code")
+	}
+}
+
+/*
+	Kodu doğrular:
+	curl -X POST http://localhost:3000/otp/verify -H "Content-Type: application/json" \
+	-d '{"number": "+491234567890", "code": "123456"}'
+*/
+
+func (s *OTPService) HandleVerify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req otpRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+
+	err := s.Verify(req.Number, req.Code)
+	switch {
+	case err == nil:
+		writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "verified": true})
+	case errors.Is(err, ErrMissingOTPPayload), IsNumberError(err):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrInvalidCode):
+		writeError(w, http.StatusUnauthorized, err.Error())
+	case errors.Is(err, ErrTooManyAttempts):
+		writeError(w, http.StatusTooManyRequests, err.Error())
+	default:
+		// Kod yok veya süresi dolmuş, yeni kod istenmeli
+		writeError(w, http.StatusGone, err.Error())
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError dokümandaki {"error": "..."} biçiminde hata yazar
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,285 @@
+package phoneapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeGateway gönderilen mesajları saklayan sahte /send-sms sunucusu
+type fakeGateway struct {
+	mu       sync.Mutex
+	fail     bool
+	messages map[string]string
+}
+
+func (g *fakeGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("API-Key") != "test-key" {
+		writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	var req struct{ Number, Message string }
+	json.NewDecoder(r.Body).Decode(&req)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.fail {
+		writeError(w, http.StatusInternalServerError, "modem unavailable")
+		return
+	}
+	g.messages[req.Number] = req.Message
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "message_id": "msg_" + req.Number, "status": StatusQueued})
+}
+
+var codePattern = regexp.MustCompile(`\d{6}`)
+
+// lastCode numaraya gönderilen son kodu döner
+func (g *fakeGateway) lastCode(number string) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return codePattern.FindString(g.messages[number])
+}
+
+func newTestOTP(t *testing.T, cfg OTPConfig) (*OTPService, *fakeGateway) {
+	t.Helper()
+	gw := &fakeGateway{messages: make(map[string]string)}
+	srv := httptest.NewServer(gw)
+	t.Cleanup(srv.Close)
+	return NewOTPService(NewClient(srv.URL, "test-key"), cfg), gw
+}
+
+func TestOTPSendAndVerify(t *testing.T) {
+	s, gw := newTestOTP(t, DefaultOTPConfig)
+
+	if _, err := s.Send("+4915123456789", ""); err != nil {
+		t.Fatal(err)
+	}
+	code := gw.lastCode("+4915123456789")
+	if len(code) != 6 {
+		t.Fatalf("message did not contain a code: %q", gw.messages["+4915123456789"])
+	}
+
+	if err := s.Verify("+4915123456788", code); !errors.Is(err, ErrNoCode) {
+		t.Errorf("code for another number = %v, want %v", err, ErrNoCode)
+	}
+	if err := s.Verify("+4915123456789", code); err != nil {
+		t.Fatalf("Verify = %v", err)
+	}
+	if err := s.Verify("+4915123456789", code); !errors.Is(err, ErrNoCode) {
+		t.Errorf("reused code = %v, want %v", err, ErrNoCode)
+	}
+
+	if _, err := s.Send("+4915123456780", "de-DE"); err != nil {
+		t.Fatal(err)
+	}
+	if msg := gw.messages["+4915123456780"]; !strings.HasPrefix(msg, "Ihr Bestätigungscode lautet: ") {
+		t.Errorf("German message = %q", msg)
+	}
+}
+
+func TestOTPAttemptsAndExpiry(t *testing.T) {
+	cfg := DefaultOTPConfig
+	cfg.MaxAttempts = 2
+	cfg.ResendCooldown = 0
+	s, gw := newTestOTP(t, cfg)
+
+	s.Send("+4915123456789", "")
+	code := gw.lastCode("+4915123456789")
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+	if err := s.Verify("+4915123456789", wrong); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("first wrong code = %v, want %v", err, ErrInvalidCode)
+	}
+	if err := s.Verify("+4915123456789", wrong); !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("second wrong code = %v, want %v", err, ErrTooManyAttempts)
+	}
+	// Kilitlenen kod doğru girilse de kabul edilmez
+	if err := s.Verify("+4915123456789", code); err == nil {
+		t.Fatal("locked code was accepted")
+	}
+
+	s.Send("+4915123456789", "")
+	s.mu.Lock()
+	s.entries["+4915123456789"].expires = time.Now().Add(-time.Second)
+	s.mu.Unlock()
+	if err := s.Verify("+4915123456789", gw.lastCode("+4915123456789")); !errors.Is(err, ErrCodeExpired) {
+		t.Errorf("expired code = %v, want %v", err, ErrCodeExpired)
+	}
+}
+
+func TestOTPSendLimits(t *testing.T) {
+	cfg := DefaultOTPConfig
+	cfg.MaxSends = 2
+	s, _ := newTestOTP(t, cfg)
+
+	if _, err := s.Send("+4915123456789", ""); err != nil {
+		t.Fatal(err)
+	}
+	var cooldown *CooldownError
+	if _, err := s.Send("+4915123456789", ""); !errors.Is(err, ErrResendCooldown) || !errors.As(err, &cooldown) || cooldown.RetryAfter <= 0 {
+		t.Fatalf("resend within cooldown = %v", err)
+	}
+
+	s.mu.Lock()
+	s.entries["+4915123456789"].sentAt = time.Now().Add(-2 * cfg.ResendCooldown)
+	s.mu.Unlock()
+	if _, err := s.Send("+4915123456789", ""); err != nil {
+		t.Fatalf("resend after cooldown = %v", err)
+	}
+
+	s.mu.Lock()
+	s.entries["+4915123456789"].sentAt = time.Now().Add(-2 * cfg.ResendCooldown)
+	s.mu.Unlock()
+	if _, err := s.Send("+4915123456789", ""); !errors.Is(err, ErrTooManyCodes) {
+		t.Errorf("third send in the window = %v, want %v", err, ErrTooManyCodes)
+	}
+}
+
+func TestOTPFailedSendIsNotCounted(t *testing.T) {
+	s, gw := newTestOTP(t, DefaultOTPConfig)
+	gw.fail = true
+
+	var sendErr *SendError
+	if _, err := s.Send("+4915123456789", ""); !errors.As(err, &sendErr) || sendErr.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("Send with a failing gateway = %v", err)
+	}
+	gw.fail = false
+	// Gönderilemeyen kod için cooldown uygulanmaz
+	if _, err := s.Send("+4915123456789", ""); err != nil {
+		t.Errorf("retry after a failed send: %v", err)
+	}
+}
+
+func TestOTPHandlers(t *testing.T) {
+	s, gw := newTestOTP(t, DefaultOTPConfig)
+
+	post := func(handler http.HandlerFunc, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodPost, "/otp", strings.NewReader(body)))
+		return rec
+	}
+
+	if rec := post(s.HandleSend, `{}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("send without number = %d", rec.Code)
+	}
+	if rec := post(s.HandleSend, `{"number": "+4915123456789"}`); rec.Code != http.StatusOK {
+		t.Fatalf("send = %d: %s", rec.Code, rec.Body)
+	}
+	rec := post(s.HandleSend, `{"number": "+4915123456789"}`)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("resend = %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	if rec := post(s.HandleVerify, `{"number": "+4915123456789", "code": "abc"}`); rec.Code != http.StatusUnauthorized {
+		t.Errorf("verify with a wrong code = %d", rec.Code)
+	}
+	if rec := post(s.HandleVerify, `{"number": "+4915123456789", "code": "`+gw.lastCode("+4915123456789")+`"}`); rec.Code != http.StatusOK {
+		t.Errorf("verify = %d: %s", rec.Code, rec.Body)
+	}
+	if rec := post(s.HandleVerify, `{"number": "+4915123456789", "code": "123456"}`); rec.Code != http.StatusGone {
+		t.Errorf("verify after use = %d", rec.Code)
+	}
+}
+
+// failingSender fail true iken gönderimi reddeden sender
+type failingSender struct{ fail bool }
+
+func (s *failingSender) Send(number, message string) (SendResult, error) {
+	if s.fail {
+		return SendResult{}, errors.New("gateway unavailable")
+	}
+	return SendResult{MessageID: "msg-" + number}, nil
+}
+
+func testOTPService(sender SMSSender, perClient, total int) *OTPService {
+	cfg := DefaultOTPConfig
+	cfg.MaxSendsPerClient = perClient
+	cfg.MaxSendsTotal = total
+	return NewOTPService(sender, cfg)
+}
+
+func TestOTPClientLimit(t *testing.T) {
+	s := testOTPService(&failingSender{}, 3, 0)
+
+	for i := 0; i < 3; i++ {
+		if _, err := s.SendFrom("203.0.113.7", fmt.Sprintf("+90532123450%d", i), ""); err != nil {
+			t.Fatalf("send %d: %v", i, err)
+		}
+	}
+	_, err := s.SendFrom("203.0.113.7", "+905321234599", "")
+	var cooldown *CooldownError
+	if !errors.Is(err, ErrClientLimit) || !errors.As(err, &cooldown) || cooldown.RetryAfter <= 0 {
+		t.Fatalf("fourth send from the same client = %v, want %v with a retry delay", err, ErrClientLimit)
+	}
+	if _, err := s.SendFrom("198.51.100.1", "+905321234599", ""); err != nil {
+		t.Errorf("send from another client: %v", err)
+	}
+}
+
+func TestOTPSendBudget(t *testing.T) {
+	s := testOTPService(&failingSender{}, 0, 2)
+
+	for i := 0; i < 2; i++ {
+		if _, err := s.SendFrom(fmt.Sprintf("203.0.113.%d", i), fmt.Sprintf("+90532123450%d", i), ""); err != nil {
+			t.Fatalf("send %d: %v", i, err)
+		}
+	}
+	if _, err := s.SendFrom("198.51.100.1", "+905321234599", ""); !errors.Is(err, ErrSendBudget) {
+		t.Fatalf("send over the budget = %v, want %v", err, ErrSendBudget)
+	}
+	if _, err := s.Send("+905321234598", ""); !errors.Is(err, ErrSendBudget) {
+		t.Errorf("Send without a client must also respect the budget, got %v", err)
+	}
+}
+
+func TestOTPFailedSendIsNotCountedForClient(t *testing.T) {
+	sender := &failingSender{fail: true}
+	s := testOTPService(sender, 1, 1)
+
+	if _, err := s.SendFrom("203.0.113.7", "+905321234500", ""); err == nil {
+		t.Fatal("expected the send to fail")
+	}
+	sender.fail = false
+	if _, err := s.SendFrom("203.0.113.7", "+905321234500", ""); err != nil {
+		t.Errorf("retry after a failed send: %v", err)
+	}
+}
+
+func TestOTPHandleSendLimitsByAddress(t *testing.T) {
+	s := testOTPService(&failingSender{}, 1, 0)
+	s.cfg.TrustProxy = true
+
+	send := func(number, forwardedFor string) *http.Response {
+		req := httptest.NewRequest(http.MethodPost, "/otp/send", strings.NewReader(`{"number": "`+number+`"}`))
+		req.RemoteAddr = "10.0.0.1:5000"
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		rec := httptest.NewRecorder()
+		s.HandleSend(rec, req)
+		return rec.Result()
+	}
+
+	if resp := send("+905321234500", "203.0.113.7"); resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("first send = %d %s", resp.StatusCode, body)
+	}
+	// İstemcinin eklediği sahte adres vekilin eklediği son adresi değiştirmez
+	resp := send("+905321234501", "192.0.2.55, 203.0.113.7")
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" {
+		t.Fatalf("spoofed X-Forwarded-For = %d, want %d with Retry-After", resp.StatusCode, http.StatusTooManyRequests)
+	}
+	if resp := send("+905321234502", "198.51.100.1"); resp.StatusCode != http.StatusOK {
+		t.Errorf("send from another address = %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,216 @@
+package phoneapi
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Numara tipleri
+const (
+	NumberMobile        = "mobile"
+	NumberFixedLine     = "fixed_line"
+	NumberFixedOrMobile = "fixed_line_or_mobile" // NANP'ta numaradan ayırt edilemez
+	NumberOther         = "other"                // ücretsiz, ücretli, kurumsal vb.
+	NumberUnknown       = "unknown"              // bölge bilgisi olmayan ülke kodları
+)
+
+// Numara hataları
+var (
+	ErrInvalidNumber  = errors.New("invalid phone number")
+	ErrUnknownRegion  = errors.New("unknown default region")
+	ErrNotSMSCapable  = errors.New("phone number can not receive SMS")
+	ErrMissingRegion  = errors.New("number is not in international format and no default region is set")
+	ErrInvalidCountry = errors.New("invalid country calling code")
+)
+
+// regionInfo bir ülkenin numara planı. Mobil ve sabit hat önekleri ulusal numaranın
+// (alan kodu dahil, trunk prefix hariç) başına göre eşleşir.
+type regionInfo struct {
+	region      string
+	countryCode string
+	trunkPrefix string
+	minLength   int
+	maxLength   int
+	mobile      []string
+	fixedLine   []string
+}
+
+// regions desteklenen bölgeler. Listede olmayan ülke kodları yalnızca uzunluk ile doğrulanır.
+var regions = []regionInfo{
+	{region: "TR", countryCode: "90", trunkPrefix: "0", minLength: 10, maxLength: 10,
+		mobile: []string{"5"}, fixedLine: []string{"2", "3", "4"}},
+	{region: "DE", countryCode: "49", trunkPrefix: "0", minLength: 6, maxLength: 11,
+		mobile: []string{"15", "16", "17"}, fixedLine: []string{"2", "3", "4", "5", "6", "7", "8", "9"}},
+	{region: "AT", countryCode: "43", trunkPrefix: "0", minLength: 6, maxLength: 13,
+		mobile: []string{"65", "66", "67", "68", "69"}, fixedLine: []string{"1", "2", "3", "4", "5", "7"}},
+	{region: "CH", countryCode: "41", trunkPrefix: "0", minLength: 9, maxLength: 9,
+		mobile: []string{"75", "76", "77", "78", "79"}, fixedLine: []string{"2", "3", "4", "5", "6", "71", "81", "91"}},
+	{region: "NL", countryCode: "31", trunkPrefix: "0", minLength: 9, maxLength: 9,
+		mobile: []string{"6"}, fixedLine: []string{"1", "2", "3", "4", "5", "7"}},
+	{region: "FR", countryCode: "33", trunkPrefix: "0", minLength: 9, maxLength: 9,
+		mobile: []string{"6", "7"}, fixedLine: []string{"1", "2", "3", "4", "5", "9"}},
+	{region: "GB", countryCode: "44", trunkPrefix: "0", minLength: 10, maxLength: 10,
+		mobile: []string{"71", "72", "73", "74", "75", "77", "78", "79"}, fixedLine: []string{"1", "2"}},
+	{region: "US", countryCode: "1", trunkPrefix: "1", minLength: 10, maxLength: 10},
+	{region: "CA", countryCode: "1", trunkPrefix: "1", minLength: 10, maxLength: 10},
+}
+
+// PhoneNumber ayrıştırılmış ve E.164'e çevrilmiş numara
+type PhoneNumber struct {
+	E164        string `json:"e164"`
+	CountryCode string `json:"country_code"`
+	National    string `json:"national"`
+	Region      string `json:"region,omitempty"`
+	Type        string `json:"type"`
+}
+
+// CanReceiveSMS sabit hat ve özel numaralar SMS alamaz
+func (n PhoneNumber) CanReceiveSMS() bool {
+	switch n.Type {
+	case NumberMobile, NumberFixedOrMobile, NumberUnknown:
+		return true
+	}
+	return false
+}
+
+// ParsePhone numarayı E.164'e çevirir. "+" veya "00" ile başlamayan numaralar defaultRegion'a
+// göre ulusal biçimde kabul edilir, örn. TR için "0532 123 45 67" -> +905321234567.
+func ParsePhone(raw, defaultRegion string) (PhoneNumber, error) {
+	digits, international, err := stripNumber(raw)
+	if err != nil {
+		return PhoneNumber{}, err
+	}
+
+	if !international {
+		if defaultRegion == "" {
+			return PhoneNumber{}, ErrMissingRegion
+		}
+		info, ok := findRegion(defaultRegion)
+		if !ok {
+			return PhoneNumber{}, fmt.Errorf("%w %q", ErrUnknownRegion, defaultRegion)
+		}
+		return classify(info, stripTrunkPrefix(info, digits))
+	}
+
+	for _, info := range regions {
+		if national, ok := strings.CutPrefix(digits, info.countryCode); ok {
+			// NANP'ta US ve CA aynı kodu kullanır, varsayılan bölge tercih edilir
+			if info.countryCode == "1" && strings.EqualFold(defaultRegion, "CA") {
+				info, _ = findRegion("CA")
+			}
+			// "+49 (0)30 ..." gibi yazımlarda trunk prefix numaraya karışmış olabilir
+			return classify(info, stripTrunkPrefix(info, national))
+		}
+	}
+
+	// Tabloda olmayan ülke: E.164 üst sınırı 15 hane, ülke kodu 0 ile başlamaz
+	if digits[0] == '0' {
+		return PhoneNumber{}, ErrInvalidCountry
+	}
+	if len(digits) < 8 || len(digits) > 15 {
+		return PhoneNumber{}, ErrInvalidNumber
+	}
+	return PhoneNumber{E164: "+" + digits, Type: NumberUnknown}, nil
+}
+
+// ParseSMSNumber numarayı ayrıştırır ve SMS alamayan numaraları reddeder
+func ParseSMSNumber(raw, defaultRegion string) (PhoneNumber, error) {
+	n, err := ParsePhone(raw, defaultRegion)
+	if err != nil {
+		return n, err
+	}
+	if !n.CanReceiveSMS() {
+		return n, fmt.Errorf("%w: %s is a %s number", ErrNotSMSCapable, n.E164, n.Type)
+	}
+	return n, nil
+}
+
+// IsNumberError hatanın istemcinin gönderdiği numaradan kaynaklanıp kaynaklanmadığını döner
+func IsNumberError(err error) bool {
+	return errors.Is(err, ErrInvalidNumber) || errors.Is(err, ErrNotSMSCapable) ||
+		errors.Is(err, ErrMissingRegion) || errors.Is(err, ErrInvalidCountry)
+}
+
+// stripNumber boşluk, tire, nokta, parantez ve eğik çizgiyi atar; "+" veya "00" önekini tanır
+func stripNumber(raw string) (string, bool, error) {
+	raw = strings.TrimSpace(raw)
+	var b strings.Builder
+	for i, r := range raw {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '+' && i == 0:
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')' || r == '/':
+		default:
+			return "", false, fmt.Errorf("%w: unexpected character %q", ErrInvalidNumber, r)
+		}
+	}
+
+	digits := b.String()
+	international := strings.HasPrefix(raw, "+")
+	if !international && strings.HasPrefix(digits, "00") {
+		digits, international = digits[2:], true
+	}
+	if digits == "" {
+		return "", false, ErrInvalidNumber
+	}
+	return digits, international, nil
+}
+
+// stripTrunkPrefix ulusal aramalarda kullanılan öneki (TR/DE için 0, NANP için 1) atar
+func stripTrunkPrefix(info regionInfo, national string) string {
+	if info.trunkPrefix == "0" || len(national) == info.maxLength+len(info.trunkPrefix) {
+		return strings.TrimPrefix(national, info.trunkPrefix)
+	}
+	return national
+}
+
+// classify ulusal numaranın uzunluğunu doğrular ve tipini belirler
+func classify(info regionInfo, national string) (PhoneNumber, error) {
+	if len(national) < info.minLength || len(national) > info.maxLength {
+		return PhoneNumber{}, fmt.Errorf("%w for %s: %d digits", ErrInvalidNumber, info.region, len(national))
+	}
+	if national[0] == '0' {
+		return PhoneNumber{}, fmt.Errorf("%w for %s", ErrInvalidNumber, info.region)
+	}
+
+	n := PhoneNumber{
+		E164:        "+" + info.countryCode + national,
+		CountryCode: info.countryCode,
+		National:    national,
+		Region:      info.region,
+		Type:        NumberOther,
+	}
+	switch {
+	case info.mobile == nil && info.fixedLine == nil:
+		if national[0] == '1' {
+			// NANP'ta alan kodu 2-9 ile başlar
+			return PhoneNumber{}, fmt.Errorf("%w for %s", ErrInvalidNumber, info.region)
+		}
+		n.Type = NumberFixedOrMobile
+	case hasPrefix(national, info.mobile):
+		n.Type = NumberMobile
+	case hasPrefix(national, info.fixedLine):
+		n.Type = NumberFixedLine
+	}
+	return n, nil
+}
+
+func findRegion(region string) (regionInfo, bool) {
+	for _, info := range regions {
+		if strings.EqualFold(info.region, region) {
+			return info, true
+		}
+	}
+	return regionInfo{}, false
+}
+
+func hasPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
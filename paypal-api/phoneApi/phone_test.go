@@ -0,0 +1,83 @@
+package phoneapi
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParsePhone(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      string
+		region   string
+		wantE164 string
+		wantType string
+		wantErr  error
+	}{
+		{"TR national", "0532 123 45 67", "TR", "+905321234567", NumberMobile, nil},
+		{"TR without trunk prefix", "532-123-45-67", "TR", "+905321234567", NumberMobile, nil},
+		{"TR international", "+90 532 123 45 67", "", "+905321234567", NumberMobile, nil},
+		{"00 prefix", "00905321234567", "", "+905321234567", NumberMobile, nil},
+		{"00 prefix with region", "0090 (532) 123 45 67", "DE", "+905321234567", NumberMobile, nil},
+		{"TR landline", "0212 123 45 67", "TR", "+902121234567", NumberFixedLine, nil},
+		{"DE trunk prefix in parentheses", "+49 (0)30 1234567", "", "+49301234567", NumberFixedLine, nil},
+		{"DE mobile", "0151 23456789", "DE", "+4915123456789", NumberMobile, nil},
+		{"NANP", "+1 (415) 555-2671", "", "+14155552671", NumberFixedOrMobile, nil},
+		{"NANP national with trunk prefix", "1-415-555-2671", "US", "+14155552671", NumberFixedOrMobile, nil},
+		{"NANP national", "415.555.2671", "US", "+14155552671", NumberFixedOrMobile, nil},
+		{"NANP area code starting with 1", "+1 123 456 7890", "", "", "", ErrInvalidNumber},
+		{"unlisted country", "+372 5123 4567", "", "+37251234567", NumberUnknown, nil},
+		{"unlisted country too short", "+372 512", "", "", "", ErrInvalidNumber},
+		{"country code starting with 0", "+0123456789", "", "", "", ErrInvalidCountry},
+		{"TR too short", "0532 123 45", "TR", "", "", ErrInvalidNumber},
+		{"TR too long", "0532 123 45 678", "TR", "", "", ErrInvalidNumber},
+		{"letters", "0532 ABC 45 67", "TR", "", "", ErrInvalidNumber},
+		{"plus in the middle", "0532+1234567", "TR", "", "", ErrInvalidNumber},
+		{"empty", "  ", "TR", "", "", ErrInvalidNumber},
+		{"national without region", "0532 123 45 67", "", "", "", ErrMissingRegion},
+		{"unknown region", "0532 123 45 67", "XX", "", "", ErrUnknownRegion},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePhone(tt.raw, tt.region)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParsePhone(%q, %q) error = %v, want %v", tt.raw, tt.region, err, tt.wantErr)
+			}
+			if got.E164 != tt.wantE164 || got.Type != tt.wantType {
+				t.Errorf("ParsePhone(%q, %q) = %s %s, want %s %s", tt.raw, tt.region, got.E164, got.Type, tt.wantE164, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestParsePhoneCanadaRegion(t *testing.T) {
+	n, err := ParsePhone("+1 604 555 0199", "CA")
+	if err != nil || n.Region != "CA" {
+		t.Fatalf("ParsePhone = %+v, %v; want region CA", n, err)
+	}
+}
+
+func TestParseSMSNumber(t *testing.T) {
+	if n, err := ParseSMSNumber("0532 123 45 67", "TR"); err != nil || n.E164 != "+905321234567" {
+		t.Errorf("mobile number: %+v, %v", n, err)
+	}
+	if _, err := ParseSMSNumber("+1 415 555 2671", ""); err != nil {
+		t.Errorf("NANP number should be accepted: %v", err)
+	}
+
+	_, err := ParseSMSNumber("0212 123 45 67", "TR")
+	if !errors.Is(err, ErrNotSMSCapable) {
+		t.Fatalf("landline error = %v, want %v", err, ErrNotSMSCapable)
+	}
+	if !IsNumberError(err) {
+		t.Error("landline error should be reported as a number error")
+	}
+
+	// 0800 ücretsiz hat ne mobil ne sabit hat
+	if _, err := ParseSMSNumber("0800 123 45 67", "TR"); !errors.Is(err, ErrNotSMSCapable) {
+		t.Errorf("toll free error = %v, want %v", err, ErrNotSMSCapable)
+	}
+	if _, err := ParseSMSNumber("0532", "TR"); !IsNumberError(err) {
+		t.Errorf("short number error = %v, want a number error", err)
+	}
+}
@@ -0,0 +1,113 @@
+package phoneapi
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// SMSSender bir numaraya SMS gönderen sağlayıcı. Client (gateway) bir gerçekleştirmedir.
+type SMSSender interface {
+	Send(number, message string) (SendResult, error)
+}
+
+// SendResult sağlayıcının mesaja verdiği ID ve gönderim anındaki durum.
+// Teslim raporu desteklemeyen sağlayıcılarda MessageID boş olabilir.
+type SendResult struct {
+	MessageID string `json:"message_id"`
+	Status    string `json:"status"`
+}
+
+var _ SMSSender = (*Client)(nil)
+
+// ConsoleSender mesajları göndermek yerine bir writer'a yazar, geliştirme ortamı içindir
+type ConsoleSender struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewConsoleSender mesajları w'ye yazan sender oluşturur, w nil ise stdout kullanılır
+func NewConsoleSender(w io.Writer) *ConsoleSender {
+	if w == nil {
+		w = os.Stdout
+	}
+	return &ConsoleSender{w: w}
+}
+
+// Send mesajı zaman, numara ve metin olarak tek satıra yazar
+func (s *ConsoleSender) Send(number, message string) (SendResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := fmt.Fprintf(s.w, "%s SMS to %s: %q\n", time.Now().Format(time.RFC3339), number, message)
+	if err != nil {
+		return SendResult{}, err
+	}
+	return SendResult{Status: StatusSent}, nil
+}
+
+// FileSender mesajları bir dosyanın sonuna ekler
+type FileSender struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// NewFileSender path'e yazan sender oluşturur, dosya yoksa ilk gönderimde oluşturulur
+func NewFileSender(path string) *FileSender {
+	return &FileSender{Path: path}
+}
+
+// Send mesajı dosyaya ekler
+func (s *FileSender) Send(number, message string) (SendResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return SendResult{}, err
+	}
+	_, err = fmt.Fprintf(f, "%s SMS to %s: %q\n", time.Now().Format(time.RFC3339), number, message)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return SendResult{}, err
+	}
+	return SendResult{Status: StatusSent}, nil
+}
+
+// FailoverSender önce Primary ile gönderir, Primary mesajı 200 dışında bir yanıtla reddederse
+// aynı mesajı Secondary ile tekrar dener. Zaman aşımı gibi bağlantı hatalarında Primary mesajı
+// almış olabilir, ikinci sağlayıcıya göndermek çift SMS'e yol açacağı için hata döner.
+type FailoverSender struct {
+	Primary   SMSSender
+	Secondary SMSSender
+}
+
+// NewFailoverSender iki sağlayıcıdan failover sender oluşturur
+func NewFailoverSender(primary, secondary SMSSender) *FailoverSender {
+	return &FailoverSender{Primary: primary, Secondary: secondary}
+}
+
+// Send iki sağlayıcı da başarısız olursa her iki hatayı da döner
+func (s *FailoverSender) Send(number, message string) (SendResult, error) {
+	result, err := s.Primary.Send(number, message)
+	if err == nil {
+		return result, nil
+	}
+	var sendErr *SendError
+	if !errors.As(err, &sendErr) {
+		return SendResult{}, fmt.Errorf("primary: %w", err)
+	}
+
+	log.Printf("sms: primary provider failed, trying secondary: %v", err)
+	result, secondaryErr := s.Secondary.Send(number, message)
+	if secondaryErr != nil {
+		return SendResult{}, errors.Join(fmt.Errorf("primary: %w", err), fmt.Errorf("secondary: %w", secondaryErr))
+	}
+	return result, nil
+}
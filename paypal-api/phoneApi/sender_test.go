@@ -0,0 +1,99 @@
+package phoneapi
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// recordingSender gönderilen mesajları saklar, err verilmişse onu döner
+type recordingSender struct {
+	err  error
+	sent []string
+}
+
+func (s *recordingSender) Send(number, message string) (SendResult, error) {
+	if s.err != nil {
+		return SendResult{}, s.err
+	}
+	s.sent = append(s.sent, number+": "+message)
+	return SendResult{MessageID: "msg-" + number, Status: StatusQueued}, nil
+}
+
+func TestConsoleSender(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewConsoleSender(&buf)
+	if _, err := s.Send("+491234567890", `Code: "123456"`); err != nil {
+		t.Fatal(err)
+	}
+	line := buf.String()
+	if !strings.HasSuffix(line, ` SMS to +491234567890: "Code: \"123456\""`+"\n") {
+		t.Errorf("console output = %q", line)
+	}
+}
+
+func TestFileSender(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sms.log")
+	s := NewFileSender(path)
+	for _, msg := range []string{"first", "second\nline"} {
+		if _, err := s.Send("+491234567890", msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], `: "first"`) || !strings.HasSuffix(lines[1], `: "second\nline"`) {
+		t.Errorf("file content = %q", data)
+	}
+
+	if _, err := NewFileSender(filepath.Join(t.TempDir(), "missing", "sms.log")).Send("+491234567890", "x"); err == nil {
+		t.Error("Send to a missing directory succeeded")
+	}
+}
+
+func TestFailoverSender(t *testing.T) {
+	down := errors.New("connection refused")
+
+	primary, secondary := &recordingSender{}, &recordingSender{}
+	if _, err := NewFailoverSender(primary, secondary).Send("+491234567890", "hi"); err != nil {
+		t.Fatal(err)
+	}
+	if len(primary.sent) != 1 || len(secondary.sent) != 0 {
+		t.Errorf("healthy primary: primary %v secondary %v", primary.sent, secondary.sent)
+	}
+
+	primary, secondary = &recordingSender{err: &SendError{StatusCode: 503}}, &recordingSender{}
+	result, err := NewFailoverSender(primary, secondary).Send("+491234567890", "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.MessageID != "msg-+491234567890" {
+		t.Errorf("result = %+v, want the secondary's message ID", result)
+	}
+	if len(secondary.sent) != 1 {
+		t.Errorf("secondary was not used after a 503: %v", secondary.sent)
+	}
+
+	// Bağlantı hatasında primary mesajı almış olabilir, secondary denenmez
+	primary, secondary = &recordingSender{err: down}, &recordingSender{}
+	if _, err := NewFailoverSender(primary, secondary).Send("+491234567890", "hi"); !errors.Is(err, down) {
+		t.Errorf("transport error = %v, want %v", err, down)
+	}
+	if len(secondary.sent) != 0 {
+		t.Errorf("secondary was used after a transport error: %v", secondary.sent)
+	}
+
+	primary, secondary = &recordingSender{err: &SendError{StatusCode: 503}}, &recordingSender{err: down}
+	_, err = NewFailoverSender(primary, secondary).Send("+491234567890", "hi")
+	var sendErr *SendError
+	if !errors.As(err, &sendErr) || !errors.Is(err, down) {
+		t.Errorf("both failing = %v, want both errors", err)
+	}
+}
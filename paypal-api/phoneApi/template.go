@@ -0,0 +1,108 @@
+package phoneapi
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"text/template"
+)
+
+// Şablon adları. Her dil dosyası bu adlarla {{define}} blokları içerir.
+const (
+	TemplateOTP               = "otp"
+	TemplateOrderConfirmation = "order_confirmation"
+	TemplateCourierAssigned   = "courier_assigned"
+	TemplateDelivered         = "delivered"
+)
+
+// OrderMessage sipariş bildirimlerinin (onay, kurye, teslimat) şablon verisi
+type OrderMessage struct {
+	OrderID      string `json:"order_id"`
+	Amount       string `json:"amount,omitempty"`
+	Currency     string `json:"currency,omitempty"`
+	CourierName  string `json:"courier_name,omitempty"`
+	CourierPhone string `json:"courier_phone,omitempty"`
+	TrackingURL  string `json:"tracking_url,omitempty"`
+}
+
+// Şablon hataları
+var (
+	ErrUnknownTemplate = errors.New("unknown SMS template")
+	ErrUnknownLocale   = errors.New("no SMS templates for locale")
+)
+
+//go:embed templates/*.tmpl
+var embeddedTemplates embed.FS
+
+// DefaultTemplates paketle gelen tr, de ve en şablonları, bulunamayan dillerde en kullanılır
+var DefaultTemplates = mustLoadTemplates(embeddedTemplates, "templates", "en")
+
+// Templates dil başına bir şablon seti
+type Templates struct {
+	locales  map[string]*template.Template
+	fallback string
+}
+
+// LoadTemplates dir altındaki <dil>.tmpl dosyalarını yükler, örn. templates/tr.tmpl.
+// fallback, istenen dilde şablon yoksa kullanılacak dildir ve dosyası bulunmalıdır.
+func LoadTemplates(fsys fs.FS, dir, fallback string) (*Templates, error) {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Templates{locales: make(map[string]*template.Template), fallback: fallback}
+	for _, file := range files {
+		locale := strings.TrimSuffix(path.Base(file), ".tmpl")
+		tmpl, err := template.New(locale).Option("missingkey=error").ParseFS(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", file, err)
+		}
+		t.locales[strings.ToLower(locale)] = tmpl
+	}
+	if _, ok := t.locales[fallback]; !ok {
+		return nil, fmt.Errorf("%w %q in %s", ErrUnknownLocale, fallback, dir)
+	}
+	return t, nil
+}
+
+func mustLoadTemplates(fsys fs.FS, dir, fallback string) *Templates {
+	t, err := LoadTemplates(fsys, dir, fallback)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Render name şablonunu locale dilinde data ile doldurur. locale "tr", "de-DE" veya bir
+// Accept-Language başlığı olabilir; dil bulunamazsa fallback kullanılır.
+func (t *Templates) Render(locale, name string, data interface{}) (string, error) {
+	tmpl := t.lookup(locale)
+	if tmpl.Lookup(name) == nil {
+		return "", fmt.Errorf("%w %q", ErrUnknownTemplate, name)
+	}
+	var b strings.Builder
+	if err := tmpl.ExecuteTemplate(&b, name, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// lookup "de-DE,de;q=0.9" gibi değerlerde ilk dili, yoksa ana dili (de) dener
+func (t *Templates) lookup(locale string) *template.Template {
+	locale, _, _ = strings.Cut(locale, ",")
+	locale, _, _ = strings.Cut(locale, ";")
+	locale = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+
+	if tmpl, ok := t.locales[locale]; ok {
+		return tmpl
+	}
+	base, _, _ := strings.Cut(locale, "-")
+	if tmpl, ok := t.locales[base]; ok {
+		return tmpl
+	}
+	return t.locales[t.fallback]
+}
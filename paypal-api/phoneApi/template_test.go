@@ -0,0 +1,67 @@
+package phoneapi
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+)
+
+func TestTemplatesRender(t *testing.T) {
+	data := OrderMessage{OrderID: "A-1", CourierName: "Ali", CourierPhone: "+905321234567"}
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{"tr", "A-1 numaralı siparişiniz yola çıktı. Kurye: Ali (+905321234567)."},
+		{"de-DE,de;q=0.9,en;q=0.8", "Ihre Bestellung A-1 ist unterwegs. Kurier: Ali (+905321234567)."},
+		{"EN_us", "Your order A-1 is on its way. Courier: Ali (+905321234567)."},
+		// Şablonu olmayan dil fallback'e düşer
+		{"fr", "Your order A-1 is on its way. Courier: Ali (+905321234567)."},
+		{"", "Your order A-1 is on its way. Courier: Ali (+905321234567)."},
+	}
+	for _, tt := range tests {
+		got, err := DefaultTemplates.Render(tt.locale, TemplateCourierAssigned, data)
+		if err != nil {
+			t.Fatalf("Render(%q) = %v", tt.locale, err)
+		}
+		if got != tt.want {
+			t.Errorf("Render(%q) = %q, want %q", tt.locale, got, tt.want)
+		}
+	}
+
+	data.TrackingURL = "https://example.com/t/A-1"
+	got, _ := DefaultTemplates.Render("en", TemplateCourierAssigned, data)
+	if want := "Your order A-1 is on its way. Courier: Ali (+905321234567). Track it: https://example.com/t/A-1"; got != want {
+		t.Errorf("with tracking URL = %q, want %q", got, want)
+	}
+
+	if _, err := DefaultTemplates.Render("en", "missing", data); !errors.Is(err, ErrUnknownTemplate) {
+		t.Errorf("unknown template = %v, want %v", err, ErrUnknownTemplate)
+	}
+	// OTP şablonunda OrderMessage alanları yok, eksik alan hata verir
+	if _, err := DefaultTemplates.Render("en", TemplateOTP, data); err == nil {
+		t.Error("missing field did not fail")
+	}
+}
+
+func TestLoadTemplates(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sms/en.tmpl": {Data: []byte(`{{define "otp"}}Code {{.Code}}{{end}}`)},
+		"sms/nl.tmpl": {Data: []byte(`{{define "otp"}}Code {{.Code}} (nl){{end}}`)},
+	}
+	tmpl, err := LoadTemplates(fsys, "sms", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := tmpl.Render("nl-BE", TemplateOTP, map[string]string{"Code": "1"}); got != "Code 1 (nl)" {
+		t.Errorf("nl-BE = %q", got)
+	}
+
+	if _, err := LoadTemplates(fsys, "sms", "tr"); !errors.Is(err, ErrUnknownLocale) {
+		t.Errorf("missing fallback = %v, want %v", err, ErrUnknownLocale)
+	}
+	fsys["sms/de.tmpl"] = &fstest.MapFile{Data: []byte(`{{define "otp"}}{{.Code}`)}
+	if _, err := LoadTemplates(fsys, "sms", "en"); err == nil {
+		t.Error("broken template was loaded")
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"paypal/paypal"
+)
+
+// stuckPaymentAge bu süreden uzun bekleyen ödemeler PayPal ile karşılaştırılır
+const stuckPaymentAge = 15 * time.Minute
+
+// reconcilePayment yerel kaydı PayPal'daki order durumuna göre düzeltir. Kullanıcı onay
+// verip /success'e dönmeden tarayıcıyı kapattıysa order burada capture/authorize edilir.
+func reconcilePayment(payment Payment) (Payment, error) {
+	order, err := client.GetOrder(payment.PayPalOrderID)
+	var apiErr *paypal.APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		// Onaylanmayan order'lar PayPal tarafında bir süre sonra silinir
+		return payments.update(payment.OrderID, func(p *Payment) error {
+			if p.Status == PaymentPending {
+				p.Status = PaymentAbandoned
+			}
+			p.PayPalStatus = "NOT_FOUND"
+			p.ReconciledAt = time.Now()
+			return nil
+		})
+	}
+	if err != nil {
+		return payment, err
+	}
+
+	if order.Status == "APPROVED" && payment.Status == PaymentPending {
+		// /success ile aynı request ID kullanılır, aynı anda dönen kullanıcıyla çift tahsilat olmaz
+		if payment.Intent == paypal.IntentAuthorize {
+			order, err = client.AuthorizeOrder(payment.PayPalOrderID, payment.orderRequestID("authorize"))
+		} else {
+			order, err = client.CaptureOrder(payment.PayPalOrderID, payment.orderRequestID("capture"))
+		}
+		if err != nil {
+			return payment, err
+		}
+	}
+
+	return payments.update(payment.OrderID, func(p *Payment) error {
+		p.PayPalStatus = order.Status
+		p.ReconciledAt = time.Now()
+		if order.Payer != nil && p.PayerID == "" {
+			p.PayerID = order.Payer.PayerID
+		}
+
+		switch order.Status {
+		case "COMPLETED":
+			applyOrderPayments(p, order)
+		case "VOIDED":
+			if p.Status == PaymentPending {
+				p.Status = PaymentAbandoned
+			}
+		case "CREATED", "PAYER_ACTION_REQUIRED":
+			// Onay linki dönüş state'i ile aynı sürede geçersiz olur
+			if p.Status == PaymentPending && time.Since(p.CreatedAt) > stateTTL {
+				p.Status = PaymentAbandoned
+			}
+		}
+		if p.Status != PaymentPending {
+			// Sonuçlanan ödemenin dönüş linki artık işlenmez
+			p.StateNonce = ""
+		}
+		return nil
+	})
+}
+
+// applyOrderPayments tamamlanmış order'daki capture ve authorization kayıtlarını ödemeye işler
+func applyOrderPayments(p *Payment, order *paypal.Order) {
+	if p.Status != PaymentPending && p.Status != PaymentAbandoned {
+		// İade, void ve kısmi capture durumları webhook ve API çağrıları ile takip edilir
+		return
+	}
+
+	var captured int64
+	for _, capture := range order.Captures() {
+		if capture.Status != "COMPLETED" || capture.Amount == nil {
+			continue
+		}
+		amount, err := paypal.ParseMinorUnits(capture.Amount.Value, p.Currency)
+		if err != nil {
+			continue
+		}
+		captured += amount
+		if p.CaptureID == "" {
+			p.CaptureID = capture.ID
+		}
+	}
+	if captured > 0 {
+		p.Captured = captured
+		p.Status = PaymentCompleted
+		return
+	}
+
+	for _, auth := range order.Authorizations() {
+		if auth.Status == "CREATED" {
+			p.AuthorizationID = auth.ID
+			p.AuthorizedAt = time.Now()
+			p.Status = PaymentAuthorized
+			return
+		}
+	}
+}
+
+// stuckPayments PayPal ile karşılaştırılması gereken ödemeleri döner
+func stuckPayments(olderThan time.Duration) []Payment {
+	var stuck []Payment
+	for _, p := range payments.list(PaymentPending) {
+		if time.Since(p.UpdatedAt) > olderThan {
+			stuck = append(stuck, p)
+		}
+	}
+	return stuck
+}
+
+// reconcilePayments takılı kalmış ödemeleri PayPal'dan sorgulayıp düzeltir
+func reconcilePayments(olderThan time.Duration) {
+	for _, p := range stuckPayments(olderThan) {
+		updated, err := reconcilePayment(p)
+		if err != nil {
+			log.Printf("reconciler: order %s (%s): %v", p.OrderID, p.PayPalOrderID, err)
+			continue
+		}
+		if updated.Status != p.Status {
+			log.Printf("reconciler: order %s %s -> %s", p.OrderID, p.Status, updated.Status)
+		}
+	}
+}
+
+// runReconciler reconcilePayments'ı periyodik olarak çalıştırır
+func runReconciler(interval time.Duration) {
+	for range time.Tick(interval) {
+		reconcilePayments(stuckPaymentAge)
+	}
+}
+
+/*
+	Yerel ödeme kayıtları (API-Key gerekir):
+	GET /payments                      -> tüm ödemeler
+	GET /payments?status=PENDING       -> duruma göre filtre
+	GET /payments?order_id=<ORDER_ID>  -> tek bir ödeme
+*/
+
+func handlePayments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if orderID := r.URL.Query().Get("order_id"); orderID != "" {
+		payment, ok := payments.find(orderID)
+		if !ok {
+			http.Error(w, "Payment not found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(payment)
+		return
+	}
+	json.NewEncoder(w).Encode(payments.list(r.URL.Query().Get("status")))
+}
+
+/*
+	Ödemeleri PayPal ile elle karşılaştırır (API-Key gerekir):
+	POST /payments/reconcile?order_id=<ORDER_ID>  -> tek bir ödeme, durumu ne olursa olsun
+	POST /payments/reconcile                      -> bekleyen tüm ödemeler
+*/
+
+func handleReconcile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	orderID := r.URL.Query().Get("order_id")
+	if orderID == "" {
+		reconcilePayments(0)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(payments.list(""))
+		return
+	}
+
+	payment, ok := payments.find(orderID)
+	if !ok {
+		http.Error(w, "Payment not found", http.StatusNotFound)
+		return
+	}
+	updated, err := reconcilePayment(payment)
+	if err != nil {
+		writePayPalError(w, fmt.Sprintf("Failed to reconcile order %s", orderID), err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
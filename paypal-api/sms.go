@@ -0,0 +1,207 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"paypal/paypal"
+	"paypal/phoneApi"
+)
+
+// smsTemplates SMS_TEMPLATE_DIR verilmişse oradaki <dil>.tmpl dosyaları, yoksa paketle gelen şablonlar
+var smsTemplates = loadSMSTemplates()
+
+// smsDeliveries gönderilen SMS'lerin teslim kayıtları, SMS_STATUS_LOG dosyasında
+// SMS_RETENTION_DAYS (varsayılan 30) gün saklanır
+var smsDeliveries = openSMSDeliveries()
+
+// smsReceiptToken gateway'in teslim raporlarını gönderdiği /sms/receipts adresini korur
+var smsReceiptToken = os.Getenv("SMS_RECEIPT_TOKEN")
+
+// smsSender tüm SMS'lerin gönderildiği sağlayıcı
+var smsSender = newSMSSender()
+
+// otp telefon doğrulama kodlarını smsSender üzerinden gönderir
+var otp = newOTPService()
+
+/*
+	SMS sağlayıcısı ortam değişkenlerinden seçilir:
+	SMS_GATEWAY_URL, SMS_API_KEY                      -> /send-sms gateway'i
+	SMS_FALLBACK_URL, SMS_FALLBACK_API_KEY            -> birincil başarısız olursa kullanılan ikinci gateway
+	SMS_LOG_FILE                                      -> gateway yoksa mesajlar bu dosyaya yazılır
+	Hiçbiri verilmezse mesajlar konsola yazılır (geliştirme ortamı).
+	SMS_RECEIPT_TOKEN verilirse gateway teslim raporlarını
+	PUBLIC_BASE_URL/sms/receipts?token=<SMS_RECEIPT_TOKEN> adresine gönderir.
+	/otp/send saatte IP başına OTP_MAX_PER_CLIENT (10), toplamda OTP_MAX_TOTAL (500) kod gönderir.
+	Sunucu ters vekil arkasındaysa TRUST_PROXY=true ile istemci adresi X-Forwarded-For'dan alınır.
+*/
+
+func newSMSSender() phoneapi.SMSSender {
+	gatewayURL := os.Getenv("SMS_GATEWAY_URL")
+	if gatewayURL == "" {
+		if path := os.Getenv("SMS_LOG_FILE"); path != "" {
+			return phoneapi.NewFileSender(path)
+		}
+		log.Println("SMS_GATEWAY_URL is not set, SMS messages are printed to the console")
+		return phoneapi.NewConsoleSender(os.Stdout)
+	}
+
+	var sender phoneapi.SMSSender = newSMSClient(gatewayURL, os.Getenv("SMS_API_KEY"))
+	if fallbackURL := os.Getenv("SMS_FALLBACK_URL"); fallbackURL != "" {
+		sender = phoneapi.NewFailoverSender(sender, newSMSClient(fallbackURL, os.Getenv("SMS_FALLBACK_API_KEY")))
+	}
+	return sender
+}
+
+func newSMSClient(gatewayURL, apiKey string) *phoneapi.Client {
+	client := phoneapi.NewClient(gatewayURL, apiKey)
+	if smsReceiptToken != "" {
+		client.CallbackURL = publicBaseURL + "/sms/receipts?token=" + url.QueryEscape(smsReceiptToken)
+	}
+	return client
+}
+
+func openSMSDeliveries() *phoneapi.DeliveryLog {
+	retention := time.Duration(envInt("SMS_RETENTION_DAYS", 30)) * 24 * time.Hour
+	deliveries, err := phoneapi.OpenDeliveryLog(os.Getenv("SMS_STATUS_LOG"), retention)
+	if err != nil {
+		log.Fatalf("sms status log: %v", err)
+	}
+	return deliveries
+}
+
+func loadSMSTemplates() *phoneapi.Templates {
+	dir := os.Getenv("SMS_TEMPLATE_DIR")
+	if dir == "" {
+		return phoneapi.DefaultTemplates
+	}
+	templates, err := phoneapi.LoadTemplates(os.DirFS(dir), ".", "en")
+	if err != nil {
+		log.Fatalf("sms templates: %v", err)
+	}
+	return templates
+}
+
+func newOTPService() *phoneapi.OTPService {
+	cfg := phoneapi.DefaultOTPConfig
+	cfg.Templates = smsTemplates
+	cfg.Deliveries = smsDeliveries
+	cfg.MaxSendsPerClient = envInt("OTP_MAX_PER_CLIENT", cfg.MaxSendsPerClient)
+	cfg.MaxSendsTotal = envInt("OTP_MAX_TOTAL", cfg.MaxSendsTotal)
+	cfg.TrustProxy = os.Getenv("TRUST_PROXY") == "true"
+	return phoneapi.NewOTPService(smsSender, cfg)
+}
+
+func envInt(key string, fallback int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		log.Fatalf("%s must be a non-negative integer", key)
+	}
+	return n
+}
+
+// notifyRequest POST /sms/notify gövdesi
+type notifyRequest struct {
+	Number   string                `json:"number"`
+	Locale   string                `json:"locale"`
+	Template string                `json:"template"`
+	Order    phoneapi.OrderMessage `json:"order"`
+	DryRun   bool                  `json:"dry_run"`
+}
+
+/*
+	Sipariş bildirimi gönderir. template: order_confirmation, courier_assigned veya delivered.
+	Tutar verilmezse kayıtlı ödemeden alınır. dry_run ile mesaj gönderilmeden metni ve
+	kaç SMS parçası tutacağı döner:
+	curl -X POST http://localhost:3000/sms/notify -H "API-Key: <ADMIN_API_KEY>" \
+	-d '{"number": "0532 123 45 67", "locale": "tr", "template": "courier_assigned",
+	     "order": {"order_id": "<ORDER_ID>", "courier_name": "Ali", "courier_phone": "+905551112233"},
+	     "dry_run": true}'
+*/
+
+func handleSMSNotify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req notifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	switch req.Template {
+	case phoneapi.TemplateOrderConfirmation, phoneapi.TemplateCourierAssigned, phoneapi.TemplateDelivered:
+	default:
+		// Doğrulama kodları yalnızca /otp/send ile gönderilir
+		http.Error(w, "Unsupported template: "+req.Template, http.StatusBadRequest)
+		return
+	}
+	if req.Order.OrderID == "" {
+		http.Error(w, "order.order_id is required", http.StatusBadRequest)
+		return
+	}
+	if payment, ok := payments.find(req.Order.OrderID); ok && req.Order.Amount == "" {
+		req.Order.Amount = paypal.FormatMinorUnits(payment.Amount, payment.Currency)
+		req.Order.Currency = payment.Currency
+	}
+
+	phone, err := phoneapi.ParseSMSNumber(req.Number, phoneapi.DefaultOTPConfig.DefaultRegion)
+	if err != nil {
+		http.Error(w, "Invalid phone number: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	message, err := smsTemplates.Render(req.Locale, req.Template, req.Order)
+	if err != nil {
+		http.Error(w, "Failed to render message: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp := map[string]interface{}{
+		"number":   phone.E164,
+		"message":  message,
+		"segments": phoneapi.CountSegments(message),
+		"sent":     false,
+	}
+	if !req.DryRun {
+		result, err := smsSender.Send(phone.E164, message)
+		if err != nil {
+			log.Printf("sms notify %s: %v", req.Order.OrderID, err)
+			http.Error(w, "Failed to send SMS", http.StatusBadGateway)
+			return
+		}
+		delivery := smsDeliveries.Record(phoneapi.Delivery{
+			ID:        result.MessageID,
+			Number:    phone.E164,
+			Template:  req.Template,
+			Reference: req.Order.OrderID,
+			Segments:  phoneapi.CountSegments(message).Count,
+			Status:    result.Status,
+		})
+		resp["sent"] = true
+		resp["message_id"] = delivery.ID
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleSMSReceipt gateway'in callback'ini SMS_RECEIPT_TOKEN ile doğrular
+func handleSMSReceipt(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if smsReceiptToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(smsReceiptToken)) != 1 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	smsDeliveries.HandleReceipt(w, r)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// stateTTL imzalı state'in geçerlilik süresi, PayPal onay linki de yaklaşık bu kadar geçerlidir
+const stateTTL = 3 * time.Hour
+
+// publicBaseURL PayPal'ın kullanıcıyı geri yönlendireceği dış adres, örn. https://shop.example.com
+var publicBaseURL = strings.TrimRight(envOrDefault("PUBLIC_BASE_URL", "http://localhost:3000"), "/")
+
+// returnURL ve cancelURL ayrı ayrı da verilebilir, boş ise publicBaseURL kullanılır
+var (
+	returnURL = envOrDefault("PAYPAL_RETURN_URL", publicBaseURL+"/success")
+	cancelURL = envOrDefault("PAYPAL_CANCEL_URL", publicBaseURL+"/cancel")
+)
+
+// stateSecret state imzası için anahtar. Verilmezse her açılışta rastgele üretilir,
+// bu durumda yeniden başlatmadan önce başlatılmış ödemeler geri dönüşte reddedilir.
+var stateSecret = loadStateSecret()
+
+var (
+	errInvalidState = errors.New("invalid state")
+	errExpiredState = errors.New("state expired")
+)
+
+// redirectState dönüş URL'sine eklenen, yerel siparişi PayPal yönlendirmesine bağlayan veri
+type redirectState struct {
+	OrderID   string `json:"o"`
+	Nonce     string `json:"n"`
+	ExpiresAt int64  `json:"e"`
+}
+
+// newRedirectState sipariş için tek kullanımlık bir state oluşturur
+func newRedirectState(orderID string) redirectState {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return redirectState{
+		OrderID:   orderID,
+		Nonce:     hex.EncodeToString(b),
+		ExpiresAt: time.Now().Add(stateTTL).Unix(),
+	}
+}
+
+// encode state'i payload.imza biçiminde base64url olarak kodlar
+func (s redirectState) encode() string {
+	payload, _ := json.Marshal(s)
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(signState(encoded))
+}
+
+// parseRedirectState imzayı ve süreyi doğrular
+func parseRedirectState(value string) (redirectState, error) {
+	encoded, sig, ok := strings.Cut(value, ".")
+	if !ok {
+		return redirectState{}, errInvalidState
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, signState(encoded)) {
+		return redirectState{}, errInvalidState
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return redirectState{}, errInvalidState
+	}
+	var s redirectState
+	if err := json.Unmarshal(payload, &s); err != nil || s.OrderID == "" || s.Nonce == "" {
+		return redirectState{}, errInvalidState
+	}
+	if time.Now().Unix() > s.ExpiresAt {
+		return redirectState{}, errExpiredState
+	}
+	return s, nil
+}
+
+// signState payload'ın HMAC-SHA256 imzası
+func signState(encoded string) []byte {
+	mac := hmac.New(sha256.New, stateSecret)
+	mac.Write([]byte(encoded))
+	return mac.Sum(nil)
+}
+
+// withState URL'ye state sorgu parametresini ekler
+func withState(rawURL string, state redirectState) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	q := u.Query()
+	q.Set("state", state.encode())
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+func loadStateSecret() []byte {
+	if secret := os.Getenv("PAYPAL_STATE_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	log.Println("PAYPAL_STATE_SECRET is not set, using a random key for this process")
+	b := make([]byte, 32)
+	rand.Read(b)
+	return b
+}
+
+func envOrDefault(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRedirectState(t *testing.T) {
+	valid := newRedirectState("ORD-1")
+
+	expired := newRedirectState("ORD-2")
+	expired.ExpiresAt = time.Now().Add(-time.Minute).Unix()
+
+	encoded, _, _ := strings.Cut(valid.encode(), ".")
+	tampered := valid
+	tampered.OrderID = "ORD-OTHER"
+	tamperedPayload, _ := json.Marshal(tampered)
+	sig := base64.RawURLEncoding.EncodeToString(signState(encoded))
+
+	unsigned, _ := json.Marshal(redirectState{OrderID: "ORD-3", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	missingNonce := base64.RawURLEncoding.EncodeToString(unsigned)
+
+	tests := []struct {
+		name  string
+		value string
+		want  error
+	}{
+		{"valid", valid.encode(), nil},
+		{"expired", expired.encode(), errExpiredState},
+		{"tampered payload", base64.RawURLEncoding.EncodeToString(tamperedPayload) + "." + sig, errInvalidState},
+		{"tampered signature", encoded + "." + base64.RawURLEncoding.EncodeToString([]byte("not-a-signature")), errInvalidState},
+		{"missing signature", encoded, errInvalidState},
+		{"bad signature encoding", encoded + ".!!", errInvalidState},
+		{"missing nonce", missingNonce + "." + base64.RawURLEncoding.EncodeToString(signState(missingNonce)), errInvalidState},
+		{"malformed payload", "e30x." + base64.RawURLEncoding.EncodeToString(signState("e30x")), errInvalidState},
+		{"empty", "", errInvalidState},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRedirectState(tt.value)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("parseRedirectState error = %v, want %v", err, tt.want)
+			}
+			if tt.want == nil && got != valid {
+				t.Errorf("parseRedirectState = %+v, want %+v", got, valid)
+			}
+		})
+	}
+}
+
+func TestWithState(t *testing.T) {
+	state := newRedirectState("ORD-1")
+	got := withState("https://shop.example.com/success?lang=tr", state)
+	if !strings.HasPrefix(got, "https://shop.example.com/success?") || !strings.Contains(got, "lang=tr") {
+		t.Fatalf("withState dropped the original URL: %s", got)
+	}
+	_, query, _ := strings.Cut(got, "state=")
+	value, _, _ := strings.Cut(query, "&")
+	if parsed, err := parseRedirectState(value); err != nil || parsed != state {
+		t.Errorf("state in %s = %+v, %v", got, parsed, err)
+	}
+}
@@ -0,0 +1,492 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"paypal/paypal"
+)
+
+// SubscriptionStatus abonelik durumu. ABANDONED dışındakiler PayPal'ın abonelik durumlarıdır.
+type SubscriptionStatus string
+
+const (
+	SubscriptionApprovalPending SubscriptionStatus = "APPROVAL_PENDING"
+	SubscriptionApproved        SubscriptionStatus = "APPROVED"
+	SubscriptionActive          SubscriptionStatus = "ACTIVE"
+	SubscriptionSuspended       SubscriptionStatus = "SUSPENDED"
+	SubscriptionCancelled       SubscriptionStatus = "CANCELLED"
+	SubscriptionExpired         SubscriptionStatus = "EXPIRED"
+	// SubscriptionAbandoned abone PayPal onay sayfasından vazgeçti, yalnızca yerelde kullanılır
+	SubscriptionAbandoned SubscriptionStatus = "ABANDONED"
+)
+
+// Membership yerel abonelik kaydı, webhook'lar ile güncel tutulur
+type Membership struct {
+	SubscriptionID  string             `json:"subscription_id"`
+	CustomerID      string             `json:"customer_id"`
+	PlanID          string             `json:"plan_id"`
+	Status          SubscriptionStatus `json:"status"`
+	NextBillingTime string             `json:"next_billing_time,omitempty"`
+	LastPaymentTime string             `json:"last_payment_time,omitempty"`
+	LastPayment     *paypal.Money      `json:"last_payment,omitempty"`
+	// PaymentCount tahsil edilen dönem sayısı. İlk tahsilat üyeliğin başlangıcıdır,
+	// RenewalCount yalnızca sonraki dönemleri sayar.
+	PaymentCount   int       `json:"payment_count"`
+	RenewalCount   int       `json:"renewal_count"`
+	FailedPayments int       `json:"failed_payments"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// membershipStore abonelikleri bellekte tutar. path verilmişse her değişiklikten sonra
+// kayıtlar JSON dosyasına yazılır ve açılışta oradan yüklenir.
+type membershipStore struct {
+	mu          sync.Mutex
+	path        string
+	memberships map[string]*Membership
+}
+
+var memberships = openMembershipStore(os.Getenv("MEMBERSHIPS_FILE"))
+
+// openMembershipStore store'u oluşturur ve varsa dosyadaki kayıtları yükler
+func openMembershipStore(path string) *membershipStore {
+	s := &membershipStore{path: path, memberships: make(map[string]*Membership)}
+	if path == "" {
+		return s
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s
+	}
+	if err != nil {
+		log.Fatalf("memberships: read %s: %v", path, err)
+	}
+	var stored []Membership
+	if err := json.Unmarshal(data, &stored); err != nil {
+		log.Fatalf("memberships: decode %s: %v", path, err)
+	}
+	for i := range stored {
+		s.memberships[stored[i].SubscriptionID] = &stored[i]
+	}
+	return s
+}
+
+// persist tüm kayıtları geçici dosya üzerinden dosyaya yazar, kilit altında çağrılır
+func (s *membershipStore) persist() {
+	if s.path == "" {
+		return
+	}
+	stored := make([]Membership, 0, len(s.memberships))
+	for _, m := range s.memberships {
+		stored = append(stored, *m)
+	}
+	sort.Slice(stored, func(i, j int) bool { return stored[i].SubscriptionID < stored[j].SubscriptionID })
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		log.Printf("memberships: encode: %v", err)
+		return
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		log.Printf("memberships: write %s: %v", tmp, err)
+		return
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		log.Printf("memberships: rename %s: %v", tmp, err)
+	}
+}
+
+// save aboneliği kaydeder veya günceller
+func (s *membershipStore) save(m Membership) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m.UpdatedAt = time.Now()
+	s.memberships[m.SubscriptionID] = &m
+	s.persist()
+}
+
+// find abonelik ID'sine ait kaydı döner
+func (s *membershipStore) find(subscriptionID string) (Membership, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, ok := s.memberships[subscriptionID]
+	if !ok {
+		return Membership{}, false
+	}
+	return *m, true
+}
+
+// update aboneliği kilit altında günceller, kayıt yoksa false döner
+func (s *membershipStore) update(subscriptionID string, fn func(m *Membership)) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, ok := s.memberships[subscriptionID]
+	if !ok {
+		return false
+	}
+	fn(m)
+	m.UpdatedAt = time.Now()
+	s.persist()
+	return true
+}
+
+// syncFromSubscription PayPal'daki abonelik durumunu yerel kayda yansıtır
+func (m *Membership) syncFromSubscription(sub *paypal.Subscription) {
+	if sub.Status != "" {
+		m.Status = SubscriptionStatus(sub.Status)
+	}
+	if sub.BillingInfo != nil {
+		m.NextBillingTime = sub.BillingInfo.NextBillingTime
+		m.FailedPayments = sub.BillingInfo.FailedPaymentsCount
+		if sub.BillingInfo.LastPayment != nil {
+			m.LastPaymentTime = sub.BillingInfo.LastPayment.Time
+			amount := sub.BillingInfo.LastPayment.Amount
+			m.LastPayment = &amount
+		}
+	}
+}
+
+// planAPIRequest POST /plans isteği, aylık üyelik planı oluşturur
+type planAPIRequest struct {
+	Name          string `json:"name"`
+	Description   string `json:"description,omitempty"`
+	Price         string `json:"price"`
+	Currency      string `json:"currency"`
+	IntervalUnit  string `json:"interval_unit,omitempty"`
+	IntervalCount int    `json:"interval_count,omitempty"`
+	TrialDays     int    `json:"trial_days,omitempty"`
+}
+
+/*
+	Yeni bir üyelik planı oluşturur (API-Key gerekir):
+	curl -X POST http://localhost:3000/plans \
+	-H "API-Key: <ADMIN_API_KEY>" -H "Content-Type: application/json" \
+	-d '{"name": "Delivery Plus", "price": "4.99", "currency": "EUR"}'
+*/
+
+func handleCreatePlan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req planAPIRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid plan payload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" {
+		http.Error(w, "Missing name in request", http.StatusBadRequest)
+		return
+	}
+	currency, err := paypal.NormalizeCurrency(req.Currency)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	price, err := paypal.ParseMinorUnits(req.Price, currency)
+	if err != nil || price <= 0 {
+		http.Error(w, "Invalid price", http.StatusBadRequest)
+		return
+	}
+	if req.IntervalUnit == "" {
+		req.IntervalUnit = "MONTH"
+	}
+	if req.IntervalCount == 0 {
+		req.IntervalCount = 1
+	}
+
+	product, err := client.CreateProduct(paypal.Product{
+		Name:        req.Name,
+		Description: req.Description,
+		Type:        "SERVICE",
+	})
+	if err != nil {
+		writePayPalError(w, "Failed to create product", err)
+		return
+	}
+
+	var cycles []paypal.BillingCycle
+	if req.TrialDays > 0 {
+		cycles = append(cycles, paypal.BillingCycle{
+			Frequency:     paypal.Frequency{IntervalUnit: "DAY", IntervalCount: req.TrialDays},
+			TenureType:    "TRIAL",
+			Sequence:      1,
+			TotalCycles:   1,
+			PricingScheme: paypal.PricingScheme{FixedPrice: paypal.NewMoney(0, currency)},
+		})
+	}
+	cycles = append(cycles, paypal.BillingCycle{
+		Frequency:     paypal.Frequency{IntervalUnit: req.IntervalUnit, IntervalCount: req.IntervalCount},
+		TenureType:    "REGULAR",
+		Sequence:      len(cycles) + 1,
+		TotalCycles:   0,
+		PricingScheme: paypal.PricingScheme{FixedPrice: paypal.NewMoney(price, currency)},
+	})
+
+	plan, err := client.CreatePlan(paypal.Plan{
+		ProductID:     product.ID,
+		Name:          req.Name,
+		Description:   req.Description,
+		Status:        "ACTIVE",
+		BillingCycles: cycles,
+		PaymentPreferences: &paypal.PaymentPreferences{
+			AutoBillOutstanding:     true,
+			SetupFeeFailureAction:   "CONTINUE",
+			PaymentFailureThreshold: 3,
+		},
+	})
+	if err != nil {
+		writePayPalError(w, "Failed to create plan", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"product_id": product.ID,
+		"plan_id":    plan.ID,
+		"status":     plan.Status,
+	})
+}
+
+// subscribeAPIRequest POST /subscriptions isteği
+type subscribeAPIRequest struct {
+	PlanID     string `json:"plan_id"`
+	CustomerID string `json:"customer_id"`
+	Email      string `json:"email,omitempty"`
+}
+
+// handleSubscribe abonelik oluşturur ve PayPal onay linkini döner
+func handleSubscribe(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req subscribeAPIRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid subscription payload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.PlanID == "" || req.CustomerID == "" {
+		http.Error(w, "plan_id and customer_id are required", http.StatusBadRequest)
+		return
+	}
+
+	subReq := paypal.SubscriptionRequest{
+		PlanID:   req.PlanID,
+		CustomID: req.CustomerID,
+		ApplicationContext: &paypal.ApplicationContext{
+			UserAction: "SUBSCRIBE_NOW",
+			ReturnURL:  publicBaseURL + "/subscriptions/success",
+			CancelURL:  publicBaseURL + "/subscriptions/abandon",
+		},
+	}
+	if req.Email != "" {
+		subReq.Subscriber = &paypal.Subscriber{EmailAddress: req.Email}
+	}
+
+	sub, err := client.CreateSubscription(subReq)
+	if err != nil {
+		writePayPalError(w, "Failed to create subscription", err)
+		return
+	}
+
+	approvalURL, err := sub.ApprovalURL()
+	if err != nil {
+		writePayPalError(w, "Failed to create subscription", err)
+		return
+	}
+
+	memberships.save(Membership{
+		SubscriptionID: sub.ID,
+		CustomerID:     req.CustomerID,
+		PlanID:         req.PlanID,
+		Status:         SubscriptionStatus(sub.Status),
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"subscription_id": sub.ID,
+		"status":          sub.Status,
+		"approval_url":    approvalURL,
+	})
+}
+
+/*
+	Abone PayPal'da onay verdikten sonra buraya döner:
+	http://localhost:3000/subscriptions/success?subscription_id=<ID>&ba_token=<TOKEN>&token=<TOKEN>
+*/
+
+func handleSubscriptionSuccess(w http.ResponseWriter, r *http.Request) {
+	subscriptionID := r.URL.Query().Get("subscription_id")
+	if subscriptionID == "" {
+		http.Error(w, "Missing subscription_id in request", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := memberships.find(subscriptionID); !ok {
+		http.Error(w, "Subscription not found", http.StatusNotFound)
+		return
+	}
+
+	// Sorgu parametrelerine güvenilmez, durum PayPal'dan okunur
+	sub, err := client.GetSubscription(subscriptionID)
+	if err != nil {
+		writePayPalError(w, "Failed to get subscription", err)
+		return
+	}
+
+	memberships.update(subscriptionID, func(m *Membership) {
+		m.syncFromSubscription(sub)
+	})
+
+	fmt.Fprintln(w, "Subscription status:", sub.Status)
+}
+
+// handleSubscriptionAbandon abone PayPal onay sayfasından vazgeçtiğinde çağrılır
+func handleSubscriptionAbandon(w http.ResponseWriter, r *http.Request) {
+	subscriptionID := r.URL.Query().Get("subscription_id")
+	if subscriptionID != "" {
+		memberships.update(subscriptionID, func(m *Membership) {
+			if m.Status == SubscriptionApprovalPending {
+				m.Status = SubscriptionAbandoned
+			}
+		})
+	}
+	fmt.Fprintln(w, "Subscription canceled!")
+}
+
+/*
+	Yerel abonelik kaydını döner (API-Key gerekir), ?sync=1 ile önce PayPal'dan güncellenir:
+	curl http://localhost:3000/subscriptions/I-BW452GLLEP1G -H "API-Key: <ADMIN_API_KEY>"
+*/
+
+func handleGetSubscription(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	subscriptionID := r.PathValue("id")
+
+	if _, ok := memberships.find(subscriptionID); !ok {
+		http.Error(w, "Subscription not found", http.StatusNotFound)
+		return
+	}
+	if r.URL.Query().Get("sync") == "1" {
+		sub, err := client.GetSubscription(subscriptionID)
+		if err != nil {
+			writePayPalError(w, "Failed to get subscription", err)
+			return
+		}
+		memberships.update(subscriptionID, func(m *Membership) {
+			m.syncFromSubscription(sub)
+		})
+	}
+
+	m, _ := memberships.find(subscriptionID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(m)
+}
+
+// subscriptionActionRequest activate/suspend/cancel isteği
+type subscriptionActionRequest struct {
+	SubscriptionID string `json:"subscription_id"`
+	Reason         string `json:"reason"`
+}
+
+// handleSubscriptionAction aboneliği aktif eder, askıya alır veya iptal eder
+func handleSubscriptionAction(action string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req subscriptionActionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid payload: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.SubscriptionID == "" {
+			http.Error(w, "Missing subscription_id in request", http.StatusBadRequest)
+			return
+		}
+		// PayPal suspend ve cancel için reason zorunlu tutar
+		if req.Reason == "" && action != "activate" {
+			http.Error(w, "Missing reason in request", http.StatusBadRequest)
+			return
+		}
+
+		if err := client.ChangeSubscriptionStatus(req.SubscriptionID, action, req.Reason); err != nil {
+			writePayPalError(w, "Failed to "+action+" subscription", err)
+			return
+		}
+
+		sub, err := client.GetSubscription(req.SubscriptionID)
+		if err != nil {
+			writePayPalError(w, "Failed to get subscription", err)
+			return
+		}
+		memberships.update(sub.ID, func(m *Membership) {
+			m.syncFromSubscription(sub)
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"subscription_id": sub.ID,
+			"status":          sub.Status,
+		})
+	}
+}
+
+// processSubscriptionEvent BILLING.SUBSCRIPTION.* ve yenileme tahsilatı olaylarını işler
+func processSubscriptionEvent(event paypal.WebhookEvent) error {
+	if event.EventType == "PAYMENT.SALE.COMPLETED" {
+		var sale paypal.WebhookSale
+		if err := json.Unmarshal(event.Resource, &sale); err != nil {
+			return err
+		}
+		if sale.BillingAgreementID == "" {
+			return nil
+		}
+		found := memberships.update(sale.BillingAgreementID, func(m *Membership) {
+			m.PaymentCount++
+			if m.PaymentCount > 1 {
+				m.RenewalCount++
+			}
+			m.FailedPayments = 0
+			m.LastPaymentTime = sale.CreateTime
+			m.LastPayment = &paypal.Money{CurrencyCode: sale.Amount.Currency, Value: sale.Amount.Total}
+		})
+		if !found {
+			log.Printf("webhook %s: no local subscription %s", event.ID, sale.BillingAgreementID)
+		}
+		return nil
+	}
+
+	var sub paypal.Subscription
+	if err := json.Unmarshal(event.Resource, &sub); err != nil {
+		return err
+	}
+	found := memberships.update(sub.ID, func(m *Membership) {
+		if event.EventType == "BILLING.SUBSCRIPTION.PAYMENT.FAILED" && sub.BillingInfo == nil {
+			m.FailedPayments++
+			return
+		}
+		m.syncFromSubscription(&sub)
+	})
+	if !found {
+		log.Printf("webhook %s: no local subscription %s", event.ID, sub.ID)
+	}
+	return nil
+}
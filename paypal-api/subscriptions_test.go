@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"paypal/paypal"
+)
+
+func subscriptionEvent(t *testing.T, eventType string, resource interface{}) paypal.WebhookEvent {
+	t.Helper()
+	raw, err := json.Marshal(resource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return paypal.WebhookEvent{ID: "WH-" + eventType, EventType: eventType, Resource: raw}
+}
+
+func TestProcessSubscriptionEvent(t *testing.T) {
+	saved := memberships
+	defer func() { memberships = saved }()
+	memberships = openMembershipStore("")
+	memberships.save(Membership{SubscriptionID: "I-TEST", CustomerID: "customer-1", PlanID: "P-1", Status: "APPROVAL_PENDING"})
+
+	activated := subscriptionEvent(t, "BILLING.SUBSCRIPTION.ACTIVATED", paypal.Subscription{
+		ID:     "I-TEST",
+		Status: "ACTIVE",
+		BillingInfo: &paypal.BillingInfo{
+			NextBillingTime: "2026-11-19T10:00:00Z",
+			LastPayment:     &paypal.LastPayment{Amount: paypal.Money{CurrencyCode: "EUR", Value: "4.99"}, Time: "2026-10-19T10:00:00Z"},
+		},
+	})
+	if err := processSubscriptionEvent(activated); err != nil {
+		t.Fatal(err)
+	}
+	m, _ := memberships.find("I-TEST")
+	if m.Status != "ACTIVE" || m.NextBillingTime != "2026-11-19T10:00:00Z" || m.LastPayment == nil || m.LastPayment.Value != "4.99" {
+		t.Fatalf("after activation: %+v", m)
+	}
+
+	failed := subscriptionEvent(t, "BILLING.SUBSCRIPTION.PAYMENT.FAILED", paypal.Subscription{ID: "I-TEST"})
+	for i := 0; i < 2; i++ {
+		if err := processSubscriptionEvent(failed); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if m, _ = memberships.find("I-TEST"); m.FailedPayments != 2 || m.Status != "ACTIVE" {
+		t.Fatalf("after failed payments: %+v", m)
+	}
+
+	sale := subscriptionEvent(t, "PAYMENT.SALE.COMPLETED", map[string]interface{}{
+		"id":                   "SALE-1",
+		"billing_agreement_id": "I-TEST",
+		"create_time":          "2026-11-19T10:00:00Z",
+		"amount":               map[string]string{"total": "4.99", "currency": "EUR"},
+	})
+	if err := processSubscriptionEvent(sale); err != nil {
+		t.Fatal(err)
+	}
+	if m, _ = memberships.find("I-TEST"); m.PaymentCount != 1 || m.RenewalCount != 0 || m.FailedPayments != 0 || m.LastPaymentTime != "2026-11-19T10:00:00Z" {
+		t.Fatalf("after sale: %+v", m)
+	}
+
+	cancelled := subscriptionEvent(t, "BILLING.SUBSCRIPTION.CANCELLED", paypal.Subscription{ID: "I-TEST", Status: "CANCELLED"})
+	if err := processSubscriptionEvent(cancelled); err != nil {
+		t.Fatal(err)
+	}
+	if m, _ = memberships.find("I-TEST"); m.Status != "CANCELLED" || m.PlanID != "P-1" || m.CustomerID != "customer-1" {
+		t.Errorf("after cancel: %+v", m)
+	}
+
+	unknown := subscriptionEvent(t, "BILLING.SUBSCRIPTION.SUSPENDED", paypal.Subscription{ID: "I-UNKNOWN", Status: "SUSPENDED"})
+	if err := processSubscriptionEvent(unknown); err != nil {
+		t.Errorf("unknown subscription should be ignored: %v", err)
+	}
+	if _, ok := memberships.find("I-UNKNOWN"); ok {
+		t.Error("unknown subscription was created by a webhook")
+	}
+}
+
+func TestSubscriptionApprovalURL(t *testing.T) {
+	sub := paypal.Subscription{ID: "I-TEST", Links: []paypal.Link{
+		{Href: "https://api.sandbox.paypal.com/v1/billing/subscriptions/I-TEST", Rel: "self"},
+		{Href: "https://www.sandbox.paypal.com/webapps/billing/subscriptions?ba_token=BA-1", Rel: "approve"},
+	}}
+	if url, err := sub.ApprovalURL(); err != nil || url != sub.Links[1].Href {
+		t.Errorf("ApprovalURL = %q, %v", url, err)
+	}
+
+	sub.Links = sub.Links[:1]
+	if _, err := sub.ApprovalURL(); err == nil {
+		t.Error("ApprovalURL without approve link succeeded")
+	}
+}
+
+func TestRenewalsStartAfterFirstPayment(t *testing.T) {
+	memberships.save(Membership{SubscriptionID: "I-TEST-RENEWAL", Status: SubscriptionActive})
+
+	sale := func(id string) paypal.WebhookEvent {
+		resource, _ := json.Marshal(map[string]interface{}{
+			"id":                   id,
+			"billing_agreement_id": "I-TEST-RENEWAL",
+			"amount":               map[string]string{"total": "4.99", "currency": "EUR"},
+		})
+		return paypal.WebhookEvent{ID: "WH-" + id, EventType: "PAYMENT.SALE.COMPLETED", Resource: resource}
+	}
+
+	for i, want := range []struct{ payments, renewals int }{{1, 0}, {2, 1}, {3, 2}} {
+		if err := processSubscriptionEvent(sale(string(rune('A' + i)))); err != nil {
+			t.Fatal(err)
+		}
+		m, _ := memberships.find("I-TEST-RENEWAL")
+		if m.PaymentCount != want.payments || m.RenewalCount != want.renewals {
+			t.Errorf("after sale %d: payments %d renewals %d, want %d %d", i+1, m.PaymentCount, m.RenewalCount, want.payments, want.renewals)
+		}
+	}
+}
+
+func TestMembershipStoreSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memberships.json")
+
+	s := openMembershipStore(path)
+	s.save(Membership{SubscriptionID: "I-1", CustomerID: "customer-1", PlanID: "P-1", Status: SubscriptionApprovalPending})
+	s.save(Membership{SubscriptionID: "I-2", CustomerID: "customer-2", PlanID: "P-1", Status: SubscriptionActive})
+	s.update("I-1", func(m *Membership) {
+		m.Status = SubscriptionActive
+		m.PaymentCount = 1
+	})
+
+	reopened := openMembershipStore(path)
+	if m, ok := reopened.find("I-1"); !ok || m.Status != SubscriptionActive || m.PaymentCount != 1 || m.CustomerID != "customer-1" {
+		t.Errorf("I-1 after restart: %+v", m)
+	}
+	if m, ok := reopened.find("I-2"); !ok || m.Status != SubscriptionActive {
+		t.Errorf("I-2 after restart: %+v", m)
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"paypal/paypal"
+)
+
+// webhookID PayPal developer panelinde webhook oluşturulurken verilen ID
+var webhookID = os.Getenv("PAYPAL_WEBHOOK_ID")
+
+// maxWebhookBody webhook gövdesi için üst sınır
+const maxWebhookBody = 1 << 20
+
+// eventLog işlenmiş olay ID'lerini tutar, PayPal aynı olayı birden fazla gönderebilir.
+// path verilmişse ID'ler dosyaya yazılır, böylece yeniden başlatmadan sonra gelen tekrarlar da atlanır.
+type eventLog struct {
+	mu   sync.Mutex
+	path string
+	seen map[string]time.Time
+	ttl  time.Duration
+}
+
+var processedEvents = openEventLog(os.Getenv("WEBHOOK_EVENTS_FILE"), 72*time.Hour)
+
+// openEventLog olay kaydını oluşturur ve varsa dosyadaki ID'leri yükler
+func openEventLog(path string, ttl time.Duration) *eventLog {
+	l := &eventLog{path: path, seen: make(map[string]time.Time), ttl: ttl}
+	if path == "" {
+		return l
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l
+	}
+	if err != nil {
+		log.Fatalf("webhook events: read %s: %v", path, err)
+	}
+	if err := json.Unmarshal(data, &l.seen); err != nil {
+		log.Fatalf("webhook events: decode %s: %v", path, err)
+	}
+	return l
+}
+
+// persist ID'leri dosyaya yazar, kilit altında çağrılır
+func (l *eventLog) persist() {
+	if l.path == "" {
+		return
+	}
+	data, err := json.Marshal(l.seen)
+	if err != nil {
+		log.Printf("webhook events: encode: %v", err)
+		return
+	}
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		log.Printf("webhook events: write %s: %v", tmp, err)
+		return
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		log.Printf("webhook events: rename %s: %v", tmp, err)
+	}
+}
+
+// markSeen olay daha önce işlenmediyse kaydeder ve true döner
+func (l *eventLog) markSeen(id string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	for eventID, at := range l.seen {
+		if now.Sub(at) > l.ttl {
+			delete(l.seen, eventID)
+		}
+	}
+
+	if _, ok := l.seen[id]; ok {
+		return false
+	}
+	l.seen[id] = now
+	l.persist()
+	return true
+}
+
+// forget işlenemeyen olayı siler, böylece PayPal'ın tekrar denemesi işlenebilir
+func (l *eventLog) forget(id string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.seen, id)
+	l.persist()
+}
+
+// handleWebhook PayPal webhook olaylarını doğrular ve yerel ödeme durumunu günceller
+func handleWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+	if err != nil {
+		http.Error(w, "Failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	if err := client.VerifyWebhookSignature(webhookID, r.Header, body); err != nil {
+		log.Println("webhook verification:", err)
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	var event paypal.WebhookEvent
+	if err := json.Unmarshal(body, &event); err != nil || event.ID == "" {
+		http.Error(w, "Invalid event payload", http.StatusBadRequest)
+		return
+	}
+
+	if !processedEvents.markSeen(event.ID) {
+		// Daha önce işlendi, PayPal'ın tekrar göndermemesi için 200 dönülür
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if err := processWebhookEvent(event); err != nil {
+		processedEvents.forget(event.ID)
+		log.Printf("webhook %s (%s): %v", event.ID, event.EventType, err)
+		http.Error(w, "Failed to process event", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// processWebhookEvent olay tipine göre yerel ödeme kaydını günceller
+func processWebhookEvent(event paypal.WebhookEvent) error {
+	switch event.EventType {
+	case "PAYMENT.CAPTURE.COMPLETED":
+		var capture paypal.WebhookCapture
+		if err := json.Unmarshal(event.Resource, &capture); err != nil {
+			return err
+		}
+		payment, ok := payments.findByPayPalOrder(capture.SupplementaryData.RelatedIDs.OrderID)
+		if !ok {
+			log.Printf("webhook %s: no local payment for capture %s", event.ID, capture.ID)
+			return nil
+		}
+		// /authorizations/capture ile zaten işlenmiş capture'lar applyCapture'da atlanır
+		_, err := payments.update(payment.OrderID, func(p *Payment) error {
+			return p.applyCapture(capture.ID, capture.Amount, capture.FinalCapture)
+		})
+		return err
+
+	case "PAYMENT.CAPTURE.REFUNDED", "PAYMENT.CAPTURE.REVERSED":
+		// Resource iade kaydıdır, capture ID "up" linkinde bulunur
+		var refund paypal.Refund
+		if err := json.Unmarshal(event.Resource, &refund); err != nil {
+			return err
+		}
+		captureID := refund.CaptureID()
+		payment, ok := payments.findByCapture(captureID)
+		if !ok {
+			log.Printf("webhook %s: no local payment for capture %s", event.ID, captureID)
+			return nil
+		}
+		_, err := payments.update(payment.OrderID, func(p *Payment) error {
+			if event.EventType == "PAYMENT.CAPTURE.REVERSED" {
+				p.applyRefund(refund.ID, p.Captured-p.Refunded)
+				return nil
+			}
+			if refund.Amount == nil {
+				return fmt.Errorf("refund %s has no amount", refund.ID)
+			}
+			amount, err := paypal.ParseMinorUnits(refund.Amount.Value, p.Currency)
+			if err != nil {
+				return err
+			}
+			p.applyRefund(refund.ID, amount)
+			return nil
+		})
+		return err
+
+	case "PAYMENT.CAPTURE.DENIED":
+		var capture paypal.WebhookCapture
+		if err := json.Unmarshal(event.Resource, &capture); err != nil {
+			return err
+		}
+		payment, ok := payments.findByPayPalOrder(capture.SupplementaryData.RelatedIDs.OrderID)
+		if !ok {
+			return nil
+		}
+		_, err := payments.update(payment.OrderID, func(p *Payment) error {
+			p.Status = PaymentDenied
+			return nil
+		})
+		return err
+
+	case "CUSTOMER.DISPUTE.CREATED", "CUSTOMER.DISPUTE.UPDATED", "CUSTOMER.DISPUTE.RESOLVED":
+		var dispute paypal.WebhookDispute
+		if err := json.Unmarshal(event.Resource, &dispute); err != nil {
+			return err
+		}
+		for _, tx := range dispute.DisputedTransactions {
+			payment, ok := payments.findByCapture(tx.SellerTransactionID)
+			if !ok {
+				continue
+			}
+			_, err := payments.update(payment.OrderID, func(p *Payment) error {
+				p.DisputeID = dispute.DisputeID
+				p.DisputeStatus = dispute.Status
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case "BILLING.SUBSCRIPTION.ACTIVATED", "BILLING.SUBSCRIPTION.UPDATED", "BILLING.SUBSCRIPTION.SUSPENDED",
+		"BILLING.SUBSCRIPTION.CANCELLED", "BILLING.SUBSCRIPTION.EXPIRED", "BILLING.SUBSCRIPTION.PAYMENT.FAILED",
+		"PAYMENT.SALE.COMPLETED":
+		return processSubscriptionEvent(event)
+
+	default:
+		if strings.HasPrefix(event.EventType, "PAYMENT.PAYOUTS-ITEM.") {
+			return processPayoutEvent(event)
+		}
+		log.Printf("webhook %s: ignoring event type %s", event.ID, event.EventType)
+		return nil
+	}
+}
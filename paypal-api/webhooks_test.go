@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"paypal/paypal"
+)
+
+func TestEventLogMarkSeen(t *testing.T) {
+	l := &eventLog{seen: make(map[string]time.Time), ttl: time.Hour}
+	if !l.markSeen("WH-1") {
+		t.Fatal("new event should be marked")
+	}
+	if l.markSeen("WH-1") {
+		t.Error("duplicate event was marked twice")
+	}
+
+	l.forget("WH-1")
+	if !l.markSeen("WH-1") {
+		t.Error("forgotten event should be processed again")
+	}
+
+	l.seen["WH-OLD"] = time.Now().Add(-2 * time.Hour)
+	if !l.markSeen("WH-OLD") {
+		t.Error("expired event should be processed again")
+	}
+}
+
+func TestProcessWebhookEvent(t *testing.T) {
+	saved := payments
+	defer func() { payments = saved }()
+	payments = newTestPayments()
+	payments.create(Payment{OrderID: "ORDER-1", PayPalOrderID: "PAYPAL-1", Status: PaymentPending, Currency: "USD", Amount: 1000})
+
+	event := func(eventType string, resource interface{}) paypal.WebhookEvent {
+		raw, err := json.Marshal(resource)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return paypal.WebhookEvent{ID: "WH-" + eventType, EventType: eventType, Resource: raw}
+	}
+	refund := func(id, value string) map[string]interface{} {
+		return map[string]interface{}{
+			"id":     id,
+			"status": "COMPLETED",
+			"amount": paypal.Money{CurrencyCode: "USD", Value: value},
+			"links":  []paypal.Link{{Href: "https://api-m.sandbox.paypal.com/v2/payments/captures/C1", Rel: "up"}},
+		}
+	}
+
+	captured := event("PAYMENT.CAPTURE.COMPLETED", map[string]interface{}{
+		"id":                 "C1",
+		"status":             "COMPLETED",
+		"supplementary_data": map[string]interface{}{"related_ids": map[string]string{"order_id": "PAYPAL-1"}},
+	})
+	if err := processWebhookEvent(captured); err != nil {
+		t.Fatal(err)
+	}
+	p, _ := payments.findByCapture("C1")
+	if p.Status != PaymentCompleted {
+		t.Fatalf("status after capture = %s", p.Status)
+	}
+
+	// Aynı iade hem /refunds hem webhook ile gelebilir, tutar bir kez işlenmeli
+	for i := 0; i < 2; i++ {
+		if err := processWebhookEvent(event("PAYMENT.CAPTURE.REFUNDED", refund("R1", "4.00"))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p, _ = payments.findByCapture("C1")
+	if p.Refunded != 400 || p.Status != PaymentPartiallyRefunded {
+		t.Fatalf("after R1 refunded %d status %s", p.Refunded, p.Status)
+	}
+
+	dispute := event("CUSTOMER.DISPUTE.CREATED", map[string]interface{}{
+		"dispute_id":            "PP-D-1",
+		"status":                "OPEN",
+		"disputed_transactions": []map[string]string{{"seller_transaction_id": "C1"}},
+	})
+	if err := processWebhookEvent(dispute); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := processWebhookEvent(event("PAYMENT.CAPTURE.REVERSED", refund("R2", "6.00"))); err != nil {
+		t.Fatal(err)
+	}
+	p, _ = payments.findByCapture("C1")
+	if p.Refunded != 1000 || p.Status != PaymentRefunded {
+		t.Errorf("after reversal refunded %d status %s", p.Refunded, p.Status)
+	}
+	if p.DisputeID != "PP-D-1" || p.DisputeStatus != "OPEN" {
+		t.Errorf("dispute = %s %s", p.DisputeID, p.DisputeStatus)
+	}
+
+	if err := processWebhookEvent(event("PAYMENT.CAPTURE.REFUNDED", map[string]string{"id": "R3"})); err != nil {
+		t.Errorf("refund for unknown capture should be ignored: %v", err)
+	}
+}
+
+func TestPaymentApplyCapture(t *testing.T) {
+	eur := func(v string) *paypal.Money { return &paypal.Money{CurrencyCode: "EUR", Value: v} }
+	tests := []struct {
+		name         string
+		payment      Payment
+		capture      paypal.WebhookCapture
+		wantStatus   string
+		wantCaptured int64
+		wantCapture  string
+	}{
+		{"pending order capture", Payment{Intent: paypal.IntentCapture, Status: PaymentPending, Amount: 1000},
+			paypal.WebhookCapture{ID: "C1", Amount: eur("10.00")}, PaymentCompleted, 1000, "C1"},
+		{"partial authorization capture", Payment{Intent: paypal.IntentAuthorize, Status: PaymentAuthorized, Amount: 1000},
+			paypal.WebhookCapture{ID: "C1", Amount: eur("4.00")}, PaymentPartiallyCaptured, 400, "C1"},
+		{"second capture completes", Payment{Intent: paypal.IntentAuthorize, Status: PaymentPartiallyCaptured, Amount: 1000, Captured: 400},
+			paypal.WebhookCapture{ID: "C2", Amount: eur("6.00")}, PaymentCompleted, 1000, "C2"},
+		{"final capture below amount", Payment{Intent: paypal.IntentAuthorize, Status: PaymentAuthorized, Amount: 1000},
+			paypal.WebhookCapture{ID: "C1", Amount: eur("7.00"), FinalCapture: true}, PaymentCompleted, 700, "C1"},
+		{"refunded payment is left alone", Payment{Intent: paypal.IntentCapture, Status: PaymentRefunded, Amount: 1000, Captured: 1000, Refunded: 1000, CaptureID: "C1"},
+			paypal.WebhookCapture{ID: "C9", Amount: eur("10.00")}, PaymentRefunded, 1000, "C1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.payment
+			p.Currency = "EUR"
+			if err := p.applyCapture(tt.capture.ID, tt.capture.Amount, tt.capture.FinalCapture); err != nil {
+				t.Fatal(err)
+			}
+			if p.Status != tt.wantStatus || p.Captured != tt.wantCaptured || p.CaptureID != tt.wantCapture {
+				t.Errorf("got status %s captured %d capture %s, want %s %d %s",
+					p.Status, p.Captured, p.CaptureID, tt.wantStatus, tt.wantCaptured, tt.wantCapture)
+			}
+		})
+	}
+}
+
+func TestEventLogSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.json")
+
+	l := openEventLog(path, time.Hour)
+	if !l.markSeen("WH-1") || !l.markSeen("WH-2") {
+		t.Fatal("new events should be marked")
+	}
+	l.forget("WH-2")
+
+	reopened := openEventLog(path, time.Hour)
+	if reopened.markSeen("WH-1") {
+		t.Error("WH-1 was processed before the restart and must be skipped")
+	}
+	if !reopened.markSeen("WH-2") {
+		t.Error("forgotten WH-2 must be processed again")
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// minPasswordLength yerel hesap parolası için en az uzunluk
+const minPasswordLength = 8
+
+// credentials /register ve /signin gövdesi
+type credentials struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Name     string `json:"name,omitempty"`
+}
+
+/*
+	Yerel hesap oluşturur ve oturum açar. E-posta doğrulanmaz; aynı e-postayla PayPal'dan
+	doğrulanmış giriş gelirse e-posta PayPal hesabına geçer, hesap PayPal bağlanınca doğrulanır:
+	curl -X POST http://localhost:8080/register -c cookies \
+	-d '{"email": "user@example.com", "password": "<PASSWORD>", "name": "Test User"}'
+*/
+
+func registerHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req credentials
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	if !strings.Contains(req.Email, "@") || len(req.Password) < minPasswordLength {
+		http.Error(w, "A valid email and a password of at least 8 characters are required", http.StatusBadRequest)
+		return
+	}
+
+	user, err := users.register(req.Email, req.Password, strings.TrimSpace(req.Name))
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+	sessions.signIn(w, r, user.ID)
+	writeUser(w, http.StatusCreated, user)
+}
+
+// signInHandler e-posta ve parola ile oturum açar
+func signInHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req credentials
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	user, err := users.authenticate(req.Email, req.Password)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+	sessions.signIn(w, r, user.ID)
+	writeUser(w, http.StatusOK, user)
+}
+
+// logoutHandler PayPal token'larını iptal eder ve oturumu kapatır. PayPal'a ulaşılamasa da
+// token'lar silinir ve oturum kapanır.
+func logoutHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if userID, ok := sessions.current(r); ok {
+		if err := tokens.revoke(userID); err != nil {
+			log.Printf("logout %s: revoke tokens: %v", userID, err)
+			tokens.remove(userID)
+		}
+	}
+	sessions.signOut(w, r)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// revokePayPalHandler oturumu kapatmadan PayPal token'larını iptal eder
+func revokePayPalHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := sessions.current(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err := tokens.revoke(userID); err != nil {
+		http.Error(w, "Failed to revoke PayPal tokens: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// payPalProfileHandler kullanıcının güncel PayPal profilini saklanan token ile alır,
+// access token'ın süresi dolmuşsa yenilenir
+func payPalProfileHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := sessions.current(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	accessToken, err := tokens.accessToken(userID)
+	if errors.Is(err, errNoTokens) || errors.Is(err, errReauthRequired) {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error refreshing access token: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	userInfo, err := getUserInfo(accessToken)
+	if err != nil {
+		http.Error(w, "Error getting user info: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(userInfo)
+}
+
+// meHandler oturumdaki kullanıcıyı döner
+func meHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := currentUser(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	writeUser(w, http.StatusOK, user)
+}
+
+/*
+	Giriş yapmış yerel kullanıcı PayPal hesabını bağlar. Tarayıcıda açılır, PayPal'dan
+	dönüşte /callback hesabı bağlar:
+	GET /link/paypal
+*/
+
+func linkPayPalHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := currentUser(r); !ok {
+		http.Error(w, "Sign in before linking PayPal", http.StatusUnauthorized)
+		return
+	}
+	redirectToPayPal(w, r, true)
+}
+
+func unlinkPayPalHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := sessions.current(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	user, err := users.unlinkPayPal(userID)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+	if err := tokens.revoke(userID); err != nil {
+		log.Printf("unlink %s: revoke tokens: %v", userID, err)
+		tokens.remove(userID)
+	}
+	writeUser(w, http.StatusOK, user)
+}
+
+func currentUser(r *http.Request) (User, bool) {
+	userID, ok := sessions.current(r)
+	if !ok {
+		return User{}, false
+	}
+	return users.find(userID)
+}
+
+func writeUser(w http.ResponseWriter, status int, user User) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(user)
+}
+
+// writeUserError kullanıcı hatalarını HTTP durumuna çevirir
+func writeUserError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errInvalidCredentials):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case errors.Is(err, errUserNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, errEmailTaken), errors.Is(err, errPayPalLinked),
+		errors.Is(err, errAlreadyLinked), errors.Is(err, errLinkRequired), errors.Is(err, errLastSignInMethod):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadGateway)
+	}
+}
@@ -2,10 +2,11 @@ module paypal-login
 
 go 1.23.1
 
+require golang.org/x/crypto v0.31.0
+
 require (
 	github.com/fatih/structs v1.1.0 // indirect
 	github.com/nooize/go-assist v0.9.2 // indirect
 	github.com/nooize/paytokens v1.1.2 // indirect
-	golang.org/x/crypto v0.6.0 // indirect
-	golang.org/x/sys v0.5.0 // indirect
+	golang.org/x/sys v0.28.0 // indirect
 )
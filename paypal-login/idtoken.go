@@ -0,0 +1,341 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// jwksTTL PayPal Cache-Control göndermezse anahtarların önbellekte tutulma süresi
+	jwksTTL = time.Hour
+	// jwksMinRefresh bilinmeyen kid geldiğinde JWKS en fazla bu sıklıkla yeniden çekilir
+	jwksMinRefresh = time.Minute
+	// idTokenLeeway sunucular arasındaki saat farkı için exp ve iat'e tanınan pay
+	idTokenLeeway = time.Minute
+)
+
+var (
+	errInvalidIDToken = errors.New("invalid id_token")
+	errUnknownKey     = errors.New("id_token is signed with an unknown key")
+)
+
+/*
+	id_token PayPal'ın JWKS'i ile doğrulanır. Canlı ortamda:
+	PAYPAL_ISSUER=https://www.paypal.com PAYPAL_JWKS_URL=https://api-m.paypal.com/v1/oauth2/certs
+	Verilmezse issuer PAYPAL_WEB_BASE, JWKS PAYPAL_API_BASE/v1/oauth2/certs olur.
+*/
+
+var idTokens = &idTokenVerifier{
+	issuer:   envOrDefault("PAYPAL_ISSUER", paypalWebBase),
+	clientID: clientID,
+	keys:     &jwksCache{url: envOrDefault("PAYPAL_JWKS_URL", paypalAPIBase+"/v1/oauth2/certs")},
+}
+
+// idTokenClaims id_token'ın doğrulanmış alanları
+type idTokenClaims struct {
+	Issuer          string   `json:"iss"`
+	Subject         string   `json:"sub"` // PayPal'da userinfo'daki user_id ile aynıdır
+	Audience        audience `json:"aud"`
+	AuthorizedParty string   `json:"azp,omitempty"`
+	ExpiresAt       int64    `json:"exp"`
+	IssuedAt        int64    `json:"iat"`
+	AuthTime        int64    `json:"auth_time,omitempty"`
+	Nonce           string   `json:"nonce,omitempty"`
+
+	Name          string `json:"name,omitempty"`
+	Email         string `json:"email,omitempty"`
+	EmailVerified bool   `json:"email_verified,omitempty"`
+}
+
+// Expiry id_token'ın geçerlilik sonu
+func (c idTokenClaims) Expiry() time.Time {
+	return time.Unix(c.ExpiresAt, 0)
+}
+
+// identity kimliği doğrulanmış subject'ten alır; ad ve e-posta token'da yoksa userinfo'dan tamamlanır
+func (c idTokenClaims) identity(info paypalUserInfo) paypalIdentity {
+	id := info.identity()
+	id.UserID = c.Subject
+	if c.Name != "" {
+		id.Name = c.Name
+	}
+	if c.Email != "" && c.EmailVerified {
+		id.Email, id.EmailVerified = c.Email, true
+	}
+	return id
+}
+
+// audience aud tek bir string veya string listesi olabilir
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+func (a audience) contains(clientID string) bool {
+	for _, aud := range a {
+		if aud == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// idTokenVerifier token endpoint'inin döndüğü id_token'ı doğrular
+type idTokenVerifier struct {
+	issuer   string
+	clientID string
+	keys     *jwksCache
+}
+
+// verify RS256 imzasını, issuer, audience, süre ve nonce'u kontrol eder.
+// Hatalar errInvalidIDToken veya errUnknownKey'i sarar.
+func (v *idTokenVerifier) verify(raw, nonce string) (idTokenClaims, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return idTokenClaims{}, fmt.Errorf("%w: malformed token", errInvalidIDToken)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return idTokenClaims{}, fmt.Errorf("%w: header: %v", errInvalidIDToken, err)
+	}
+	// alg'i token belirlemez; none ve HS256 gibi algoritmalar reddedilir
+	if header.Alg != "RS256" {
+		return idTokenClaims{}, fmt.Errorf("%w: unsupported alg %q", errInvalidIDToken, header.Alg)
+	}
+
+	key, err := v.keys.key(header.Kid)
+	if err != nil {
+		return idTokenClaims{}, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return idTokenClaims{}, fmt.Errorf("%w: signature encoding", errInvalidIDToken)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return idTokenClaims{}, fmt.Errorf("%w: bad signature", errInvalidIDToken)
+	}
+
+	var claims idTokenClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return idTokenClaims{}, fmt.Errorf("%w: claims: %v", errInvalidIDToken, err)
+	}
+
+	now := time.Now()
+	switch {
+	case claims.Issuer != v.issuer:
+		return idTokenClaims{}, fmt.Errorf("%w: unexpected issuer %q", errInvalidIDToken, claims.Issuer)
+	case !claims.Audience.contains(v.clientID):
+		return idTokenClaims{}, fmt.Errorf("%w: token was not issued for this client", errInvalidIDToken)
+	case len(claims.Audience) > 1 && claims.AuthorizedParty != v.clientID:
+		return idTokenClaims{}, fmt.Errorf("%w: azp does not match client", errInvalidIDToken)
+	case claims.Subject == "":
+		return idTokenClaims{}, fmt.Errorf("%w: sub is missing", errInvalidIDToken)
+	case claims.ExpiresAt == 0 || now.After(claims.Expiry().Add(idTokenLeeway)):
+		return idTokenClaims{}, fmt.Errorf("%w: token expired", errInvalidIDToken)
+	case time.Unix(claims.IssuedAt, 0).After(now.Add(idTokenLeeway)):
+		return idTokenClaims{}, fmt.Errorf("%w: token issued in the future", errInvalidIDToken)
+	case claims.Nonce == "" || !constantTimeEqual(claims.Nonce, nonce):
+		return idTokenClaims{}, fmt.Errorf("%w: nonce does not match the login session", errInvalidIDToken)
+	}
+	return claims, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// jwksCache PayPal'ın imza anahtarlarını önbellekte tutar. Süre dolunca veya bilinmeyen
+// bir kid geldiğinde yeniden çekilir; çekme başarısız olursa eldeki anahtarlar kullanılmaya devam eder.
+// JWKS isteği kilit dışında yapılır, eşzamanlı çağrılarda PayPal'a yalnızca tek bir istek gider.
+type jwksCache struct {
+	url string
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	expires   time.Time
+	fetchedAt time.Time
+	inflight  *jwksCall
+}
+
+// jwksCall devam eden bir JWKS isteği; aynı anda gelen çağrılar sonucu bekler
+type jwksCall struct {
+	done chan struct{}
+	err  error
+}
+
+// key kid'e ait RSA anahtarını döner. kid boşsa ve JWKS'te tek anahtar varsa o kullanılır.
+func (c *jwksCache) key(kid string) (*rsa.PublicKey, error) {
+	c.mu.Lock()
+	now := time.Now()
+	key, ok := c.lookup(kid)
+	fresh := now.Before(c.expires)
+	// Bilinmeyen kid ile gelen token'lar JWKS endpoint'ini sürekli çağırtamaz
+	recent := now.Sub(c.fetchedAt) < jwksMinRefresh
+	c.mu.Unlock()
+
+	if ok && fresh {
+		return key, nil
+	}
+	if !ok && fresh && recent {
+		return nil, fmt.Errorf("%w %q", errUnknownKey, kid)
+	}
+
+	err := c.refresh()
+
+	c.mu.Lock()
+	key, ok = c.lookup(kid)
+	c.mu.Unlock()
+	switch {
+	case ok:
+		if err != nil {
+			log.Printf("jwks: refresh failed, using cached keys: %v", err)
+		}
+		return key, nil
+	case err != nil:
+		return nil, fmt.Errorf("jwks: %w", err)
+	default:
+		return nil, fmt.Errorf("%w %q", errUnknownKey, kid)
+	}
+}
+
+// refresh JWKS'i yeniden çeker. Başarısız olursa eldeki anahtarlar jwksMinRefresh boyunca
+// kullanılmaya devam eder, böylece PayPal'a her girişte yeniden istek atılmaz.
+func (c *jwksCache) refresh() error {
+	c.mu.Lock()
+	if call := c.inflight; call != nil {
+		c.mu.Unlock()
+		<-call.done
+		return call.err
+	}
+	call := &jwksCall{done: make(chan struct{})}
+	c.inflight = call
+	c.mu.Unlock()
+
+	keys, ttl, err := fetchJWKS(c.url)
+
+	c.mu.Lock()
+	now := time.Now()
+	c.fetchedAt = now
+	if err == nil {
+		c.keys, c.expires = keys, now.Add(ttl)
+	} else if len(c.keys) > 0 {
+		c.expires = now.Add(jwksMinRefresh)
+	}
+	call.err = err
+	c.inflight = nil
+	c.mu.Unlock()
+
+	close(call.done)
+	return call.err
+}
+
+// lookup kilit altında çağrılır
+func (c *jwksCache) lookup(kid string) (*rsa.PublicKey, bool) {
+	if kid == "" && len(c.keys) == 1 {
+		for _, key := range c.keys {
+			return key, true
+		}
+	}
+	key, ok := c.keys[kid]
+	return key, ok
+}
+
+// jsonWebKey JWKS'teki anahtar. Yalnızca imza için kullanılan RSA anahtarları alınır.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// fetchJWKS anahtarları ve Cache-Control max-age'e göre önbellek süresini döner
+func fetchJWKS(url string) (map[string]*rsa.PublicKey, time.Duration, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("status %d: %s", resp.StatusCode, body)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(body, &set); err != nil {
+		return nil, 0, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") || (k.Alg != "" && k.Alg != "RS256") {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+			log.Printf("jwks: skipping malformed key %q", k.Kid)
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	if len(keys) == 0 {
+		return nil, 0, errors.New("no RSA signing keys found")
+	}
+	return keys, cacheMaxAge(resp.Header.Get("Cache-Control")), nil
+}
+
+func cacheMaxAge(cacheControl string) time.Duration {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		if v, ok := strings.CutPrefix(strings.TrimSpace(directive), "max-age="); ok {
+			if seconds, err := strconv.Atoi(v); err == nil && seconds > 0 {
+				return time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	return jwksTTL
+}
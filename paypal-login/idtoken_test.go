@@ -0,0 +1,193 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	testIssuer   = "https://www.sandbox.paypal.com"
+	testClientID = "test-client"
+	testNonce    = "nonce-123"
+)
+
+// testJWKS RSA anahtarını JWKS olarak sunan sunucu; istek sayısı fetches'te tutulur
+func testJWKS(t *testing.T, kid string, key *rsa.PublicKey, fetches *int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(fetches, 1)
+		w.Header().Set("Cache-Control", "max-age=600")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": kid,
+				"use": "sig",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func signTestToken(t *testing.T, key *rsa.PrivateKey, header, claims map[string]interface{}) string {
+	t.Helper()
+	h, _ := json.Marshal(header)
+	c, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func validClaims() map[string]interface{} {
+	now := time.Now()
+	return map[string]interface{}{
+		"iss":   testIssuer,
+		"sub":   "paypal-user-1",
+		"aud":   testClientID,
+		"exp":   now.Add(5 * time.Minute).Unix(),
+		"iat":   now.Unix(),
+		"nonce": testNonce,
+		"email": "buyer@example.com",
+	}
+}
+
+func TestIDTokenVerify(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fetches int32
+	jwks := testJWKS(t, "key-1", &key.PublicKey, &fetches)
+	header := map[string]interface{}{"alg": "RS256", "kid": "key-1", "typ": "JWT"}
+
+	with := func(changes map[string]interface{}) map[string]interface{} {
+		claims := validClaims()
+		for k, v := range changes {
+			if v == nil {
+				delete(claims, k)
+			} else {
+				claims[k] = v
+			}
+		}
+		return claims
+	}
+
+	valid := signTestToken(t, key, header, validClaims())
+	parts := strings.Split(valid, ".")
+	noneHeader, _ := json.Marshal(map[string]string{"alg": "none"})
+	hsHeader, _ := json.Marshal(map[string]string{"alg": "HS256", "kid": "key-1"})
+
+	tests := []struct {
+		name  string
+		token string
+		nonce string
+		want  error
+	}{
+		{"valid", valid, testNonce, nil},
+		{"audience list with azp", signTestToken(t, key, header, with(map[string]interface{}{"aud": []string{"other", testClientID}, "azp": testClientID})), testNonce, nil},
+		{"bad signature", signTestToken(t, otherKey, header, validClaims()), testNonce, errInvalidIDToken},
+		{"modified claims", parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"attacker"}`)) + "." + parts[2], testNonce, errInvalidIDToken},
+		{"wrong issuer", signTestToken(t, key, header, with(map[string]interface{}{"iss": "https://evil.example.com"})), testNonce, errInvalidIDToken},
+		{"wrong audience", signTestToken(t, key, header, with(map[string]interface{}{"aud": "another-client"})), testNonce, errInvalidIDToken},
+		{"audience list without azp", signTestToken(t, key, header, with(map[string]interface{}{"aud": []string{"other", testClientID}})), testNonce, errInvalidIDToken},
+		{"expired", signTestToken(t, key, header, with(map[string]interface{}{"exp": time.Now().Add(-2 * idTokenLeeway).Unix()})), testNonce, errInvalidIDToken},
+		{"missing exp", signTestToken(t, key, header, with(map[string]interface{}{"exp": nil})), testNonce, errInvalidIDToken},
+		{"issued in the future", signTestToken(t, key, header, with(map[string]interface{}{"iat": time.Now().Add(time.Hour).Unix()})), testNonce, errInvalidIDToken},
+		{"missing sub", signTestToken(t, key, header, with(map[string]interface{}{"sub": nil})), testNonce, errInvalidIDToken},
+		{"wrong nonce", valid, "another-nonce", errInvalidIDToken},
+		{"missing nonce", signTestToken(t, key, header, with(map[string]interface{}{"nonce": nil})), "", errInvalidIDToken},
+		{"alg none", base64.RawURLEncoding.EncodeToString(noneHeader) + "." + parts[1] + ".", testNonce, errInvalidIDToken},
+		{"alg HS256", base64.RawURLEncoding.EncodeToString(hsHeader) + "." + parts[1] + "." + parts[2], testNonce, errInvalidIDToken},
+		{"unknown kid", signTestToken(t, key, map[string]interface{}{"alg": "RS256", "kid": "key-2"}, validClaims()), testNonce, errUnknownKey},
+		{"malformed", "not-a-jwt", testNonce, errInvalidIDToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &idTokenVerifier{issuer: testIssuer, clientID: testClientID, keys: &jwksCache{url: jwks.URL}}
+			claims, err := v.verify(tt.token, tt.nonce)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("verify error = %v, want %v", err, tt.want)
+			}
+			if tt.want == nil && claims.Subject != "paypal-user-1" {
+				t.Errorf("verify subject = %q", claims.Subject)
+			}
+		})
+	}
+}
+
+func TestJWKSCacheRefresh(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fetches int32
+	jwks := testJWKS(t, "key-1", &key.PublicKey, &fetches)
+	v := &idTokenVerifier{issuer: testIssuer, clientID: testClientID, keys: &jwksCache{url: jwks.URL}}
+
+	valid := signTestToken(t, key, map[string]interface{}{"alg": "RS256", "kid": "key-1"}, validClaims())
+	unknown := signTestToken(t, key, map[string]interface{}{"alg": "RS256", "kid": "key-2"}, validClaims())
+
+	for i := 0; i < 3; i++ {
+		if _, err := v.verify(valid, testNonce); err != nil {
+			t.Fatalf("verify: %v", err)
+		}
+	}
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Fatalf("JWKS fetched %d times, want 1", n)
+	}
+
+	// Bilinmeyen kid jwksMinRefresh dolmadan yeniden çekme yaptırmaz
+	for i := 0; i < 3; i++ {
+		if _, err := v.verify(unknown, testNonce); !errors.Is(err, errUnknownKey) {
+			t.Fatalf("verify error = %v, want %v", err, errUnknownKey)
+		}
+	}
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Errorf("JWKS fetched %d times for an unknown kid, want 1", n)
+	}
+
+	// Süre dolduktan sonra JWKS'e ulaşılamazsa eldeki anahtarlar kullanılır
+	v.keys.expires = time.Now().Add(-time.Second)
+	v.keys.url = "http://127.0.0.1:1/unreachable"
+	if _, err := v.verify(valid, testNonce); err != nil {
+		t.Errorf("verify with cached keys: %v", err)
+	}
+}
+
+func TestCacheMaxAge(t *testing.T) {
+	tests := map[string]time.Duration{
+		"":                                    jwksTTL,
+		"max-age=600":                         10 * time.Minute,
+		"public, max-age=30, must-revalidate": 30 * time.Second,
+		"max-age=0":                           jwksTTL,
+		"max-age=abc":                         jwksTTL,
+	}
+	for header, want := range tests {
+		if got := cacheMaxAge(header); got != want {
+			t.Errorf("cacheMaxAge(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
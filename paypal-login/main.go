@@ -3,61 +3,191 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"log"
 	"net/http"
 	"net/url"
+	"os"
+	"time"
 )
 
 const clientID = ""
 const secret = ""
-const redirectURI = "localhost:8080/callback"
+const redirectURI = "http://localhost:8080/callback"
+
+// PayPal adresleri. Sahte sunucu ile denemek için PAYPAL_WEB_BASE ve PAYPAL_API_BASE verilebilir.
+var (
+	paypalWebBase = envOrDefault("PAYPAL_WEB_BASE", "https://www.sandbox.paypal.com")
+	paypalAPIBase = envOrDefault("PAYPAL_API_BASE", "https://api-m.sandbox.paypal.com")
+)
 
 func main() {
 	http.HandleFunc("/login", loginHandler)
 	http.HandleFunc("/callback", callbackHandler)
+	http.HandleFunc("/register", registerHandler)
+	http.HandleFunc("/signin", signInHandler)
+	http.HandleFunc("/logout", logoutHandler)
+	http.HandleFunc("/me", meHandler)
+	http.HandleFunc("/link/paypal", linkPayPalHandler)
+	http.HandleFunc("/unlink/paypal", unlinkPayPalHandler)
+	http.HandleFunc("/me/paypal", payPalProfileHandler)
+	http.HandleFunc("/paypal/revoke", revokePayPalHandler)
 	fmt.Println("Server is running on port 8080...")
 	if err := http.ListenAndServe(":8080", nil); err != nil {
 		fmt.Println("Error starting server:", err)
 	}
 }
+
+// loginHandler kullanıcıyı PayPal ile giriş için yönlendirir
 func loginHandler(w http.ResponseWriter, r *http.Request) {
-	authURL := fmt.Sprintf("https://www.sandbox.paypal.com/signin/authorize?client_id=%s&response_type=code&scope=openid profile email&redirect_uri=%s", clientID, redirectURI)
-	http.Redirect(w, r, authURL, http.StatusFound)
+	redirectToPayPal(w, r, false)
+}
+
+// redirectToPayPal oturuma state, PKCE verifier ve nonce yazar ve kullanıcıyı PayPal giriş sayfasına yönlendirir
+func redirectToPayPal(w http.ResponseWriter, r *http.Request, link bool) {
+	sess := sessions.start(w, r)
+	state, challenge, nonce := sessions.beginLogin(sess, link)
+
+	params := url.Values{}
+	params.Set("client_id", clientID)
+	params.Set("response_type", "code")
+	params.Set("scope", "openid profile email")
+	params.Set("redirect_uri", redirectURI)
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", challenge)
+	params.Set("code_challenge_method", "S256")
+
+	http.Redirect(w, r, paypalWebBase+"/signin/authorize?"+params.Encode(), http.StatusFound)
 }
 
+// callbackHandler state'i oturumla karşılaştırır, eşleşmeyen veya daha önce kullanılmış
+// callback'leri reddeder ve kodu PKCE verifier ile token'a çevirir. Kimlik, imzası ve nonce'u
+// doğrulanan id_token'dan alınır. PayPal kimliğine bağlı yerel
+// kullanıcı bulunur veya oluşturulur (bağlama akışında oturumdaki kullanıcıya bağlanır) ve oturum açılır.
 func callbackHandler(w http.ResponseWriter, r *http.Request) {
-	authCode := r.URL.Query().Get("code")
+	q := r.URL.Query()
+	login, err := sessions.consumeLogin(r, q.Get("state"))
+	if err != nil {
+		http.Error(w, "Invalid login callback: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if errCode := q.Get("error"); errCode != "" {
+		// Kullanıcı girişi iptal etti veya PayPal isteği reddetti
+		http.Error(w, "Login failed: "+errCode+" "+q.Get("error_description"), http.StatusUnauthorized)
+		return
+	}
+	authCode := q.Get("code")
 	if authCode == "" {
 		http.Error(w, "Authorization code is required", http.StatusBadRequest)
 		return
 	}
 
-	token, err := getAccessToken(authCode)
+	token, err := getAccessToken(authCode, login.CodeVerifier)
 	if err != nil {
 		http.Error(w, "Error getting access token: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	userInfo, err := getUserInfo(token)
+	if token.IDToken == "" {
+		http.Error(w, "PayPal did not return an id_token", http.StatusUnauthorized)
+		return
+	}
+	claims, err := idTokens.verify(token.IDToken, login.Nonce)
+	if errors.Is(err, errInvalidIDToken) || errors.Is(err, errUnknownKey) {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error verifying id_token: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	userInfo, err := getUserInfo(token.AccessToken)
 	if err != nil {
 		http.Error(w, "Error getting user info: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	// userinfo başka bir kullanıcının token'ı ile alınmış olamaz
+	if userInfo.UserID != claims.Subject {
+		http.Error(w, "userinfo does not match the id_token subject", http.StatusUnauthorized)
+		return
+	}
+	identity := claims.identity(userInfo)
+
+	var user User
+	if login.LinkUserID != "" {
+		user, err = users.linkPayPal(login.LinkUserID, identity)
+	} else {
+		user, err = users.signInWithPayPal(identity)
+	}
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	if err := tokens.save(user.ID, token); err != nil {
+		log.Printf("tokens: save %s: %v", user.ID, err)
+	}
+	sessions.signIn(w, r, user.ID)
+	writeUser(w, http.StatusOK, user)
+}
+
+// paypalUserInfo userinfo yanıtının kullanılan alanları
+type paypalUserInfo struct {
+	UserID        string `json:"user_id"`
+	Name          string `json:"name"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	// schema=paypalv1.1 ile e-postalar liste olarak döner
+	Emails []struct {
+		Value     string `json:"value"`
+		Primary   bool   `json:"primary"`
+		Confirmed bool   `json:"confirmed"`
+	} `json:"emails"`
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(userInfo)
+// identity yalnızca PayPal'ın doğruladığı e-postayı alır
+func (u paypalUserInfo) identity() paypalIdentity {
+	id := paypalIdentity{UserID: u.UserID, Name: u.Name}
+	if u.Email != "" && u.EmailVerified {
+		id.Email, id.EmailVerified = u.Email, true
+		return id
+	}
+	for _, e := range u.Emails {
+		if e.Primary && e.Confirmed {
+			id.Email, id.EmailVerified = e.Value, true
+		}
+	}
+	return id
 }
 
-func getAccessToken(authCode string) (string, error) {
+// getAccessToken yetkilendirme kodunu PKCE verifier ile token'lara çevirir
+func getAccessToken(authCode, codeVerifier string) (tokenSet, error) {
 	data := url.Values{}
 	data.Set("grant_type", "authorization_code")
 	data.Set("code", authCode)
 	data.Set("redirect_uri", redirectURI)
+	data.Set("code_verifier", codeVerifier)
+	return requestToken(data)
+}
 
-	req, err := http.NewRequest("POST", "https://api-m.sandbox.paypal.com/v1/oauth2/token", bytes.NewBufferString(data.Encode()))
+// refreshAccessToken refresh token ile yeni access token alır
+func refreshAccessToken(refreshToken string) (tokenSet, error) {
+	data := url.Values{}
+	data.Set("grant_type", "refresh_token")
+	data.Set("refresh_token", refreshToken)
+	return requestToken(data)
+}
+
+// requestToken token endpoint'ine istek atar. PayPal hata dönerse *tokenError döner.
+func requestToken(data url.Values) (tokenSet, error) {
+	req, err := http.NewRequest("POST", paypalAPIBase+"/v1/oauth2/token", bytes.NewBufferString(data.Encode()))
 	if err != nil {
-		return "", err
+		return tokenSet{}, err
 	}
 
 	req.SetBasicAuth(clientID, secret)
@@ -66,31 +196,65 @@ func getAccessToken(authCode string) (string, error) {
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", err
+		return tokenSet{}, err
 	}
 	defer resp.Body.Close()
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return "", err
+		return tokenSet{}, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		tokenErr := &tokenError{StatusCode: resp.StatusCode}
+		json.Unmarshal(body, tokenErr)
+		return tokenSet{}, tokenErr
 	}
 
-	var result map[string]interface{}
+	var result tokenSet
 	if err := json.Unmarshal(body, &result); err != nil {
-		return "", err
+		return tokenSet{}, err
+	}
+	if result.AccessToken == "" {
+		return tokenSet{}, fmt.Errorf("no access token found")
+	}
+	result.ExpiresAt = time.Now().Add(time.Duration(result.ExpiresIn) * time.Second)
+	return result, nil
+}
+
+// revokeToken access veya refresh token'ı PayPal tarafında iptal eder
+func revokeToken(token, tokenTypeHint string) error {
+	data := url.Values{}
+	data.Set("token", token)
+	data.Set("token_type_hint", tokenTypeHint)
+
+	req, err := http.NewRequest("POST", paypalAPIBase+"/v1/oauth2/revoke", bytes.NewBufferString(data.Encode()))
+	if err != nil {
+		return err
 	}
+	req.SetBasicAuth(clientID, secret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	if token, ok := result["access_token"].(string); ok {
-		return token, nil
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
 	}
+	defer resp.Body.Close()
 
-	return "", fmt.Errorf("no access token found")
+	if resp.StatusCode != http.StatusOK {
+		tokenErr := &tokenError{StatusCode: resp.StatusCode}
+		body, _ := ioutil.ReadAll(resp.Body)
+		json.Unmarshal(body, tokenErr)
+		return tokenErr
+	}
+	return nil
 }
 
-func getUserInfo(accessToken string) (map[string]interface{}, error) {
-	req, err := http.NewRequest("GET", "https://api-m.sandbox.paypal.com/v1/identity/openidconnect/userinfo?schema=openid", nil)
+func getUserInfo(accessToken string) (paypalUserInfo, error) {
+	req, err := http.NewRequest("GET", paypalAPIBase+"/v1/identity/openidconnect/userinfo?schema=openid", nil)
 	if err != nil {
-		return nil, err
+		return paypalUserInfo{}, err
 	}
 
 	req.Header.Set("Authorization", "Bearer "+accessToken)
@@ -99,19 +263,30 @@ func getUserInfo(accessToken string) (map[string]interface{}, error) {
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, err
+		return paypalUserInfo{}, err
 	}
 	defer resp.Body.Close()
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return paypalUserInfo{}, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return paypalUserInfo{}, fmt.Errorf("userinfo: status %d: %s", resp.StatusCode, body)
 	}
 
-	var userInfo map[string]interface{}
+	var userInfo paypalUserInfo
 	if err := json.Unmarshal(body, &userInfo); err != nil {
-		return nil, err
+		return paypalUserInfo{}, err
 	}
 
 	return userInfo, nil
 }
+
+func envOrDefault(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
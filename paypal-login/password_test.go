@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckPasswordKnownAnswers(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoded  string
+		password string
+		want     bool
+	}{
+		// Argon2 referans uygulamasının vektörü: argon2 somesalt -id -t 2 -m 16 -p 1
+		{"argon2id", "$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc", "password", true},
+		{"argon2id wrong password", "$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc", "passwort", false},
+		{"argon2i is rejected", "$argon2i$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$wWKIMhR9lyDFvRz9YTZweHKfbftvj+qf+YFY4NeBbtA", "password", false},
+		{"unknown version", "$argon2id$v=16$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc", "password", false},
+		{"malformed", "$argon2id$v=19$m=65536$c29tZXNhbHQ$CTFh", "password", false},
+		{"empty", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkPassword(tt.encoded, tt.password); got != tt.want {
+				t.Errorf("checkPassword(%q, %q) = %v, want %v", tt.encoded, tt.password, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHashPasswordRoundTrip(t *testing.T) {
+	hash := hashPassword("correct horse battery staple")
+	if !strings.HasPrefix(hash, "$argon2id$v=19$") {
+		t.Fatalf("unexpected hash format %q", hash)
+	}
+	if !checkPassword(hash, "correct horse battery staple") {
+		t.Error("checkPassword rejected the hashed password")
+	}
+	if checkPassword(hash, "correct horse battery stapl") {
+		t.Error("checkPassword accepted a wrong password")
+	}
+	if hashPassword("correct horse battery staple") == hash {
+		t.Error("hashes of the same password must use different salts")
+	}
+}
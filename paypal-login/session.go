@@ -0,0 +1,204 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	sessionCookie = "paypal_login_session"
+	sessionTTL    = 24 * time.Hour
+	// loginTTL PayPal'a yönlendirilen kullanıcının geri dönmesi için verilen süre
+	loginTTL = 10 * time.Minute
+)
+
+// Callback doğrulama hataları
+var (
+	errNoSession     = errors.New("login session not found, start again at /login")
+	errLoginReplayed = errors.New("no login in progress, the callback was already used")
+	errStateMismatch = errors.New("state does not match the login session")
+	errLoginExpired  = errors.New("login expired, start again at /login")
+)
+
+// session tarayıcıya cookie ile bağlanan sunucu tarafı oturum
+type session struct {
+	ID     string
+	UserID string // giriş yapmış yerel kullanıcı
+
+	// Devam eden giriş. State callback'te bir kez kullanılır ve silinir.
+	State        string
+	CodeVerifier string
+	Nonce        string
+	LinkUserID   string // giriş hesabı bağlamak için başlatıldıysa bağlanacak kullanıcı
+	LoginExpires time.Time
+
+	ExpiresAt time.Time
+}
+
+// pendingLogin callback'te doğrulanan giriş bilgileri
+type pendingLogin struct {
+	CodeVerifier string
+	Nonce        string
+	LinkUserID   string
+}
+
+// sessionStore oturumları bellekte tutar
+type sessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+var sessions = &sessionStore{sessions: make(map[string]*session)}
+
+// start istekteki oturumu döner, yoksa veya süresi dolmuşsa yenisini oluşturup cookie'sini yazar
+func (s *sessionStore) start(w http.ResponseWriter, r *http.Request) *session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		if sess, ok := s.sessions[c.Value]; ok && now.Before(sess.ExpiresAt) {
+			return sess
+		}
+	}
+
+	s.sweep(now)
+	return s.create(w, "")
+}
+
+// create yeni oturum açar ve cookie'sini yazar, kilit altında çağrılır
+func (s *sessionStore) create(w http.ResponseWriter, userID string) *session {
+	sess := &session{ID: randomString(32), UserID: userID, ExpiresAt: time.Now().Add(sessionTTL)}
+	s.sessions[sess.ID] = sess
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    sess.ID,
+		Path:     "/",
+		Expires:  sess.ExpiresAt,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(redirectURI, "https://"),
+		// PayPal'dan dönüş üst seviye bir GET yönlendirmesi olduğu için Lax yeterlidir
+		SameSite: http.SameSiteLaxMode,
+	})
+	return sess
+}
+
+// current istekteki oturumun kullanıcısını döner
+func (s *sessionStore) current(r *http.Request) (string, bool) {
+	c, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return "", false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[c.Value]
+	if !ok || sess.UserID == "" || time.Now().After(sess.ExpiresAt) {
+		return "", false
+	}
+	return sess.UserID, true
+}
+
+// signIn eski oturumu silip kullanıcı için yeni ID'li oturum açar (session fixation'a karşı)
+func (s *sessionStore) signIn(w http.ResponseWriter, r *http.Request, userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		delete(s.sessions, c.Value)
+	}
+	s.create(w, userID)
+}
+
+// signOut oturumu siler ve cookie'yi temizler
+func (s *sessionStore) signOut(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		delete(s.sessions, c.Value)
+	}
+	s.mu.Unlock()
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: "", Path: "/", MaxAge: -1, HttpOnly: true})
+}
+
+// beginLogin oturuma yeni state, PKCE verifier ve nonce yazar. Önceki yarım kalan giriş geçersiz olur.
+// link true ise callback PayPal hesabını oturumdaki kullanıcıya bağlar.
+func (s *sessionStore) beginLogin(sess *session, link bool) (state, challenge, nonce string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess.State = randomString(32)
+	sess.CodeVerifier = randomString(64)
+	sess.Nonce = randomString(32)
+	sess.LinkUserID = ""
+	if link {
+		sess.LinkUserID = sess.UserID
+	}
+	sess.LoginExpires = time.Now().Add(loginTTL)
+	return sess.State, pkceChallenge(sess.CodeVerifier), sess.Nonce
+}
+
+// consumeLogin callback'teki state'i oturumdaki ile karşılaştırır. Eşleşse de eşleşmese de
+// bekleyen giriş silinir, böylece aynı callback ikinci kez kullanılamaz.
+func (s *sessionStore) consumeLogin(r *http.Request, state string) (pendingLogin, error) {
+	c, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return pendingLogin{}, errNoSession
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[c.Value]
+	if !ok || time.Now().After(sess.ExpiresAt) {
+		return pendingLogin{}, errNoSession
+	}
+	if sess.State == "" {
+		return pendingLogin{}, errLoginReplayed
+	}
+
+	expected, login, expires := sess.State, pendingLogin{sess.CodeVerifier, sess.Nonce, sess.LinkUserID}, sess.LoginExpires
+	sess.State, sess.CodeVerifier, sess.Nonce, sess.LinkUserID, sess.LoginExpires = "", "", "", "", time.Time{}
+
+	if !constantTimeEqual(state, expected) {
+		return pendingLogin{}, errStateMismatch
+	}
+	if time.Now().After(expires) {
+		return pendingLogin{}, errLoginExpired
+	}
+	if login.LinkUserID != "" && login.LinkUserID != sess.UserID {
+		// Bağlama başladıktan sonra oturum değişti
+		return pendingLogin{}, errStateMismatch
+	}
+	return login, nil
+}
+
+// sweep süresi dolmuş oturumları siler, kilit altında çağrılır
+func (s *sessionStore) sweep(now time.Time) {
+	for id, sess := range s.sessions {
+		if now.After(sess.ExpiresAt) {
+			delete(s.sessions, id)
+		}
+	}
+}
+
+// pkceChallenge RFC 7636 S256 yöntemi: BASE64URL(SHA256(verifier))
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// randomString n bayt rastgele veriyi base64url olarak döner
+func randomString(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func constantTimeEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// RFC 7636 Appendix B örneği
+func TestPKCEChallenge(t *testing.T) {
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	if got, want := pkceChallenge(verifier), "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"; got != want {
+		t.Errorf("pkceChallenge = %q, want %q", got, want)
+	}
+}
+
+// startLogin yeni bir oturumda giriş başlatır ve callback isteğini oluşturacak cookie'yi döner
+func startLogin(t *testing.T, s *sessionStore) (*session, *http.Cookie, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	sess := s.start(rec, httptest.NewRequest(http.MethodGet, "/login", nil))
+	state, challenge, nonce := s.beginLogin(sess, false)
+	if challenge != pkceChallenge(sess.CodeVerifier) || nonce == "" {
+		t.Fatalf("beginLogin = %q, %q", challenge, nonce)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != sessionCookie || !cookies[0].HttpOnly {
+		t.Fatalf("session cookie = %+v", cookies)
+	}
+	return sess, cookies[0], state
+}
+
+func callbackRequest(cookie *http.Cookie) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/callback", nil)
+	if cookie != nil {
+		r.AddCookie(cookie)
+	}
+	return r
+}
+
+func TestConsumeLogin(t *testing.T) {
+	s := &sessionStore{sessions: make(map[string]*session)}
+
+	sess, cookie, state := startLogin(t, s)
+	verifier, nonce := sess.CodeVerifier, sess.Nonce
+	login, err := s.consumeLogin(callbackRequest(cookie), state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if login.CodeVerifier != verifier || login.Nonce != nonce {
+		t.Errorf("login = %+v", login)
+	}
+	// Aynı callback ikinci kez kullanılamaz
+	if _, err := s.consumeLogin(callbackRequest(cookie), state); !errors.Is(err, errLoginReplayed) {
+		t.Errorf("replayed callback = %v, want %v", err, errLoginReplayed)
+	}
+
+	// Yanlış state bekleyen girişi de siler
+	_, cookie, state = startLogin(t, s)
+	if _, err := s.consumeLogin(callbackRequest(cookie), state+"x"); !errors.Is(err, errStateMismatch) {
+		t.Errorf("mismatched state = %v, want %v", err, errStateMismatch)
+	}
+	if _, err := s.consumeLogin(callbackRequest(cookie), state); !errors.Is(err, errLoginReplayed) {
+		t.Errorf("correct state after a mismatch = %v, want %v", err, errLoginReplayed)
+	}
+
+	sess, cookie, state = startLogin(t, s)
+	sess.LoginExpires = time.Now().Add(-time.Second)
+	if _, err := s.consumeLogin(callbackRequest(cookie), state); !errors.Is(err, errLoginExpired) {
+		t.Errorf("expired login = %v, want %v", err, errLoginExpired)
+	}
+
+	sess, cookie, state = startLogin(t, s)
+	sess.ExpiresAt = time.Now().Add(-time.Second)
+	if _, err := s.consumeLogin(callbackRequest(cookie), state); !errors.Is(err, errNoSession) {
+		t.Errorf("expired session = %v, want %v", err, errNoSession)
+	}
+	if _, err := s.consumeLogin(callbackRequest(nil), state); !errors.Is(err, errNoSession) {
+		t.Errorf("callback without a cookie = %v, want %v", err, errNoSession)
+	}
+}
+
+func TestSessionStartReusesCookie(t *testing.T) {
+	s := &sessionStore{sessions: make(map[string]*session)}
+	sess, cookie, _ := startLogin(t, s)
+
+	rec := httptest.NewRecorder()
+	if again := s.start(rec, callbackRequest(cookie)); again != sess {
+		t.Error("start created a new session for a valid cookie")
+	}
+	if len(rec.Result().Cookies()) != 0 {
+		t.Error("start rewrote the cookie of an existing session")
+	}
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// refreshMargin access token'ın süresi dolmadan bu kadar önce yenilenir
+const refreshMargin = time.Minute
+
+var (
+	errNoTokens       = errors.New("no PayPal tokens for this user")
+	errReauthRequired = errors.New("PayPal session expired, sign in again at /login")
+)
+
+// tokenSet PayPal token endpoint'inin döndüğü token'lar
+type tokenSet struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	IDToken      string    `json:"id_token,omitempty"`
+	TokenType    string    `json:"token_type"`
+	Scope        string    `json:"scope,omitempty"`
+	ExpiresIn    int       `json:"expires_in"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// tokenError token ve revoke endpoint'lerinin OAuth hata yanıtı
+type tokenError struct {
+	StatusCode  int    `json:"-"`
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *tokenError) Error() string {
+	return fmt.Sprintf("paypal: status %d: %s %s", e.StatusCode, e.Code, e.Description)
+}
+
+// tokenStore kullanıcıların PayPal token'larını tutar. path verilmişse her kullanıcının
+// token'ları AES-GCM ile şifrelenip dosyaya yazılır; düz token diske yazılmaz.
+type tokenStore struct {
+	mu     sync.Mutex
+	path   string
+	aead   cipher.AEAD
+	tokens map[string]tokenSet
+
+	// refreshing aynı kullanıcı için aynı anda gelen isteklerin refresh token'ı birden fazla kez
+	// kullanmasını önler, farklı kullanıcıların yenilemeleri birbirini beklemez
+	refreshing map[string]*refreshLock
+}
+
+// refreshLock bir kullanıcının yenileme kilidi, bekleyen kalmayınca haritadan silinir
+type refreshLock struct {
+	sync.Mutex
+	waiters int
+}
+
+/*
+	Token'lar TOKENS_FILE dosyasında saklanır, TOKEN_ENCRYPTION_KEY 32 baytlık base64 anahtardır:
+	TOKEN_ENCRYPTION_KEY=$(openssl rand -base64 32) TOKENS_FILE=tokens.json go run .
+	TOKENS_FILE verilmezse token'lar yalnızca bellekte tutulur.
+*/
+
+var tokens = openTokenStore(os.Getenv("TOKENS_FILE"), os.Getenv("TOKEN_ENCRYPTION_KEY"))
+
+func openTokenStore(path, encodedKey string) *tokenStore {
+	s := &tokenStore{path: path, tokens: make(map[string]tokenSet), refreshing: make(map[string]*refreshLock)}
+	if path == "" {
+		return s
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil || len(key) != 32 {
+		log.Fatal("tokens: TOKEN_ENCRYPTION_KEY must be 32 bytes, base64 encoded")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		log.Fatalf("tokens: %v", err)
+	}
+	if s.aead, err = cipher.NewGCM(block); err != nil {
+		log.Fatalf("tokens: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s
+	}
+	if err != nil {
+		log.Fatalf("tokens: read %s: %v", path, err)
+	}
+	var encrypted map[string]string
+	if err := json.Unmarshal(data, &encrypted); err != nil {
+		log.Fatalf("tokens: decode %s: %v", path, err)
+	}
+	for userID, value := range encrypted {
+		set, err := s.decrypt(userID, value)
+		if err != nil {
+			// Anahtar değişmiş olabilir, kullanıcı tekrar giriş yapınca yeni token'lar yazılır
+			log.Printf("tokens: skipping %s: %v", userID, err)
+			continue
+		}
+		s.tokens[userID] = set
+	}
+	return s
+}
+
+// save kullanıcının token'larını kaydeder
+func (s *tokenStore) save(userID string, set tokenSet) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[userID] = set
+	return s.persist()
+}
+
+func (s *tokenStore) get(userID string) (tokenSet, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	set, ok := s.tokens[userID]
+	return set, ok
+}
+
+// replace kullanıcının token'larını, yenileme sürerken değişmedilerse fresh ile değiştirir ve
+// kayıtlı token'ları döner. Bu arada token'lar iptal edildiyse false döner ve fresh kaydedilmez,
+// kullanıcı tekrar giriş yaptıysa yeni token'lar korunur.
+func (s *tokenStore) replace(userID string, old, fresh tokenSet) (tokenSet, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cur, ok := s.tokens[userID]
+	if !ok {
+		return tokenSet{}, false, nil
+	}
+	if cur.AccessToken != old.AccessToken || cur.RefreshToken != old.RefreshToken {
+		return cur, true, nil
+	}
+	s.tokens[userID] = fresh
+	return fresh, true, s.persist()
+}
+
+// remove token'ları siler ve silinen token'ları döner
+func (s *tokenStore) remove(userID string) (tokenSet, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	set, ok := s.tokens[userID]
+	if !ok {
+		return tokenSet{}, false
+	}
+	delete(s.tokens, userID)
+	if err := s.persist(); err != nil {
+		log.Printf("tokens: remove %s: %v", userID, err)
+	}
+	return set, true
+}
+
+// accessToken geçerli bir access token döner, süresi dolmak üzereyse refresh token ile yeniler.
+// Refresh token iptal edilmiş veya süresi dolmuşsa token'lar silinir ve errReauthRequired döner.
+func (s *tokenStore) accessToken(userID string) (string, error) {
+	set, ok := s.get(userID)
+	if !ok {
+		return "", errNoTokens
+	}
+	if time.Until(set.ExpiresAt) > refreshMargin {
+		return set.AccessToken, nil
+	}
+
+	unlock := s.lockRefresh(userID)
+	defer unlock()
+	// Beklerken başka bir istek yenilemiş olabilir
+	if set, ok = s.get(userID); !ok {
+		return "", errNoTokens
+	}
+	if time.Until(set.ExpiresAt) > refreshMargin {
+		return set.AccessToken, nil
+	}
+	if set.RefreshToken == "" {
+		s.remove(userID)
+		return "", errReauthRequired
+	}
+
+	fresh, err := refreshAccessToken(set.RefreshToken)
+	var tokenErr *tokenError
+	if errors.As(err, &tokenErr) && tokenErr.Code == "invalid_grant" {
+		s.remove(userID)
+		return "", errReauthRequired
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// Yenileme yanıtında refresh ve id token olmayabilir, eskileri korunur
+	if fresh.RefreshToken == "" {
+		fresh.RefreshToken = set.RefreshToken
+	}
+	if fresh.IDToken == "" {
+		fresh.IDToken = set.IDToken
+	}
+	// Yenileme sürerken revoke edilmiş token'lar geri yazılmaz
+	stored, ok, err := s.replace(userID, set, fresh)
+	if err != nil {
+		log.Printf("tokens: save %s: %v", userID, err)
+	}
+	if !ok {
+		return "", errNoTokens
+	}
+	return stored.AccessToken, nil
+}
+
+// lockRefresh kullanıcının yenileme kilidini alır ve kilidi bırakan fonksiyonu döner
+func (s *tokenStore) lockRefresh(userID string) func() {
+	s.mu.Lock()
+	l, ok := s.refreshing[userID]
+	if !ok {
+		l = &refreshLock{}
+		s.refreshing[userID] = l
+	}
+	l.waiters++
+	s.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		s.mu.Lock()
+		if l.waiters--; l.waiters == 0 {
+			delete(s.refreshing, userID)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// revoke kullanıcının token'larını PayPal tarafında iptal eder ve siler. Refresh token
+// iptal edilince PayPal onunla alınmış access token'ları da geçersiz kılar. PayPal hata
+// dönerse token'lar tekrar denenebilmesi için silinmez.
+func (s *tokenStore) revoke(userID string) error {
+	set, ok := s.get(userID)
+	if !ok {
+		return nil
+	}
+	var errs []error
+	if set.RefreshToken != "" {
+		errs = append(errs, revokeToken(set.RefreshToken, "refresh_token"))
+	}
+	errs = append(errs, revokeToken(set.AccessToken, "access_token"))
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	s.remove(userID)
+	return nil
+}
+
+// persist token'ları şifreleyip dosyaya yazar, kilit altında çağrılır
+func (s *tokenStore) persist() error {
+	if s.path == "" {
+		return nil
+	}
+	encrypted := make(map[string]string, len(s.tokens))
+	for userID, set := range s.tokens {
+		value, err := s.encrypt(userID, set)
+		if err != nil {
+			return err
+		}
+		encrypted[userID] = value
+	}
+	data, err := json.MarshalIndent(encrypted, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// encrypt token'ları nonce||ciphertext olarak şifreler. Kullanıcı ID'si ek veri olarak
+// doğrulandığı için bir kullanıcının kaydı başka kullanıcıya taşınamaz.
+func (s *tokenStore) encrypt(userID string, set tokenSet) (string, error) {
+	plaintext, err := json.Marshal(set)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(s.aead.Seal(nonce, nonce, plaintext, []byte(userID))), nil
+}
+
+func (s *tokenStore) decrypt(userID, value string) (tokenSet, error) {
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return tokenSet{}, err
+	}
+	if len(data) < s.aead.NonceSize() {
+		return tokenSet{}, fmt.Errorf("encrypted token too short")
+	}
+	nonce, ciphertext := data[:s.aead.NonceSize()], data[s.aead.NonceSize():]
+	plaintext, err := s.aead.Open(nil, nonce, ciphertext, []byte(userID))
+	if err != nil {
+		return tokenSet{}, err
+	}
+	var set tokenSet
+	err = json.Unmarshal(plaintext, &set)
+	return set, err
+}
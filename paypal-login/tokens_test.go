@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testEncryptionKey = base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+
+// stubTokenEndpoint PAYPAL_API_BASE'i handler'a yönlendirir
+func stubTokenEndpoint(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	old := paypalAPIBase
+	paypalAPIBase = srv.URL
+	t.Cleanup(func() {
+		paypalAPIBase = old
+		srv.Close()
+	})
+}
+
+func TestTokenStoreEncryptsTokens(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	s := openTokenStore(path, testEncryptionKey)
+	set := tokenSet{AccessToken: "access-1", RefreshToken: "refresh-1", ExpiresAt: time.Now().Add(time.Hour).Round(0)}
+	if err := s.save("usr_1", set); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "access-1") || strings.Contains(string(data), "refresh-1") {
+		t.Fatalf("token file contains plain tokens:\n%s", data)
+	}
+
+	reopened := openTokenStore(path, testEncryptionKey)
+	got, ok := reopened.get("usr_1")
+	if !ok || got.AccessToken != set.AccessToken || got.RefreshToken != set.RefreshToken || !got.ExpiresAt.Equal(set.ExpiresAt) {
+		t.Errorf("reloaded tokens = %+v, %v", got, ok)
+	}
+
+	// Kullanıcı ID'si ek veri olarak doğrulandığı için kayıt başka kullanıcıya taşınamaz
+	var encrypted map[string]string
+	json.Unmarshal(data, &encrypted)
+	if _, err := reopened.decrypt("usr_2", encrypted["usr_1"]); err == nil {
+		t.Error("record decrypted for another user")
+	}
+	encrypted["usr_2"] = encrypted["usr_1"]
+	moved, _ := json.Marshal(encrypted)
+	os.WriteFile(path, moved, 0o600)
+	if _, ok := openTokenStore(path, testEncryptionKey).get("usr_2"); ok {
+		t.Error("copied record was loaded for another user")
+	}
+
+	otherKey := base64.StdEncoding.EncodeToString([]byte("fedcba9876543210fedcba9876543210"))
+	if _, ok := openTokenStore(path, otherKey).get("usr_1"); ok {
+		t.Error("tokens decrypted with a different key")
+	}
+}
+
+func TestAccessTokenRefresh(t *testing.T) {
+	var calls int32
+	stubTokenEndpoint(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		r.ParseForm()
+		if r.Form.Get("grant_type") != "refresh_token" || r.Form.Get("refresh_token") != "refresh-1" {
+			t.Errorf("token request = %v", r.Form)
+		}
+		w.Write([]byte(`{"access_token": "access-2", "token_type": "Bearer", "expires_in": 28800}`))
+	})
+
+	s := openTokenStore("", "")
+	s.save("usr_1", tokenSet{AccessToken: "access-1", RefreshToken: "refresh-1", IDToken: "id-1", ExpiresAt: time.Now().Add(time.Hour)})
+	if token, err := s.accessToken("usr_1"); err != nil || token != "access-1" {
+		t.Fatalf("valid token = %q, %v", token, err)
+	}
+	if calls != 0 {
+		t.Fatal("valid token was refreshed")
+	}
+
+	s.save("usr_1", tokenSet{AccessToken: "access-1", RefreshToken: "refresh-1", IDToken: "id-1", ExpiresAt: time.Now().Add(30 * time.Second)})
+	token, err := s.accessToken("usr_1")
+	if err != nil || token != "access-2" {
+		t.Fatalf("refreshed token = %q, %v", token, err)
+	}
+	// Yanıtta olmayan refresh ve id token korunur
+	if set, _ := s.get("usr_1"); set.RefreshToken != "refresh-1" || set.IDToken != "id-1" {
+		t.Errorf("stored tokens after refresh = %+v", set)
+	}
+	if _, err := s.accessToken("usr_2"); !errors.Is(err, errNoTokens) {
+		t.Errorf("unknown user = %v, want %v", err, errNoTokens)
+	}
+}
+
+func TestAccessTokenInvalidGrant(t *testing.T) {
+	stubTokenEndpoint(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "invalid_grant", "error_description": "Refresh token is expired"}`))
+	})
+
+	s := openTokenStore("", "")
+	s.save("usr_1", tokenSet{AccessToken: "access-1", RefreshToken: "refresh-1", ExpiresAt: time.Now()})
+	if _, err := s.accessToken("usr_1"); !errors.Is(err, errReauthRequired) {
+		t.Fatalf("invalid_grant = %v, want %v", err, errReauthRequired)
+	}
+	if _, ok := s.get("usr_1"); ok {
+		t.Error("tokens were kept after invalid_grant")
+	}
+
+	s.save("usr_1", tokenSet{AccessToken: "access-1", ExpiresAt: time.Now()})
+	if _, err := s.accessToken("usr_1"); !errors.Is(err, errReauthRequired) {
+		t.Errorf("expired token without a refresh token = %v, want %v", err, errReauthRequired)
+	}
+}
+
+func TestAccessTokenRefreshLocksPerUser(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	stubTokenEndpoint(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		r.ParseForm()
+		if r.Form.Get("refresh_token") == "refresh-1" {
+			// usr_1'in yenilemesi usr_2'ninki bitene kadar sürer
+			<-release
+		}
+		w.Write([]byte(`{"access_token": "access-new", "token_type": "Bearer", "expires_in": 28800}`))
+	})
+
+	s := openTokenStore("", "")
+	s.save("usr_1", tokenSet{AccessToken: "access-1", RefreshToken: "refresh-1", ExpiresAt: time.Now()})
+	s.save("usr_2", tokenSet{AccessToken: "access-2", RefreshToken: "refresh-2", ExpiresAt: time.Now()})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if token, err := s.accessToken("usr_1"); err != nil || token != "access-new" {
+				t.Errorf("concurrent refresh = %q, %v", token, err)
+			}
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		s.accessToken("usr_2")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("refresh of another user waited for usr_1")
+	}
+	close(release)
+	wg.Wait()
+
+	if calls != 2 {
+		t.Errorf("token endpoint called %d times, want 2", calls)
+	}
+	if len(s.refreshing) != 0 {
+		t.Errorf("refresh locks left behind: %d", len(s.refreshing))
+	}
+}
+
+func TestAccessTokenRefreshAfterRevoke(t *testing.T) {
+	s := openTokenStore("", "")
+	stubTokenEndpoint(t, func(w http.ResponseWriter, r *http.Request) {
+		// Yenileme sürerken kullanıcı çıkış yapar
+		s.remove("usr_1")
+		w.Write([]byte(`{"access_token": "access-2", "token_type": "Bearer", "expires_in": 28800}`))
+	})
+
+	s.save("usr_1", tokenSet{AccessToken: "access-1", RefreshToken: "refresh-1", ExpiresAt: time.Now()})
+	if _, err := s.accessToken("usr_1"); !errors.Is(err, errNoTokens) {
+		t.Errorf("refresh after revoke = %v, want %v", err, errNoTokens)
+	}
+	if set, ok := s.get("usr_1"); ok {
+		t.Errorf("revoked tokens were saved again: %+v", set)
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kullanıcı hataları
+var (
+	errEmailTaken          = errors.New("an account with this email already exists")
+	errInvalidCredentials  = errors.New("invalid email or password")
+	errUserNotFound        = errors.New("user not found")
+	errPayPalLinked        = errors.New("this PayPal account is already linked to another user")
+	errAlreadyLinked       = errors.New("user is already linked to a different PayPal account")
+	errLinkRequired        = errors.New("an account with this email already exists, sign in and link PayPal at /link/paypal")
+	errLastSignInMethod    = errors.New("set a password before unlinking PayPal")
+	errMissingPayPalUserID = errors.New("PayPal did not return a user_id")
+)
+
+// User yerel kullanıcı. PayPal ile giriş yapanlar PayPalUserID ile eşleştirilir.
+type User struct {
+	ID            string    `json:"id"`
+	Email         string    `json:"email,omitempty"`
+	EmailVerified bool      `json:"email_verified"` // e-posta PayPal tarafından doğrulanmış
+	Name          string    `json:"name,omitempty"`
+	PayPalUserID  string    `json:"paypal_user_id,omitempty"`
+	HasPassword   bool      `json:"has_password"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// storedUser parola özeti API yanıtlarına girmesin diye User'dan ayrı saklanır
+type storedUser struct {
+	User
+	PasswordHash string `json:"password_hash,omitempty"`
+}
+
+// paypalIdentity PayPal userinfo'dan kullanıcıya yazılan alanlar
+type paypalIdentity struct {
+	UserID        string
+	Email         string // yalnızca PayPal'ın doğruladığı e-posta
+	EmailVerified bool
+	Name          string
+}
+
+// userStore kullanıcıları bellekte tutar, USERS_FILE verilmişse dosyaya yazar
+type userStore struct {
+	mu    sync.Mutex
+	path  string
+	users map[string]*storedUser
+}
+
+var users = openUserStore(os.Getenv("USERS_FILE"))
+
+func openUserStore(path string) *userStore {
+	s := &userStore{path: path, users: make(map[string]*storedUser)}
+	if path == "" {
+		return s
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s
+	}
+	if err != nil {
+		log.Fatalf("users: read %s: %v", path, err)
+	}
+	var list []*storedUser
+	if err := json.Unmarshal(data, &list); err != nil {
+		log.Fatalf("users: decode %s: %v", path, err)
+	}
+	for _, u := range list {
+		s.users[u.ID] = u
+	}
+	return s
+}
+
+// register e-posta ve parola ile yerel hesap oluşturur
+func (s *userStore) register(email, password, name string) (User, error) {
+	email = normalizeEmail(email)
+	hash := hashPassword(password)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.byEmail(email) != nil {
+		return User{}, errEmailTaken
+	}
+	now := time.Now()
+	u := &storedUser{
+		User:         User{ID: "usr_" + randomString(12), Email: email, Name: name, HasPassword: true, CreatedAt: now, UpdatedAt: now},
+		PasswordHash: hash,
+	}
+	s.users[u.ID] = u
+	s.persist()
+	return u.User, nil
+}
+
+// dummyPasswordHash bilinmeyen e-postalarda da parola özeti hesaplansın diye kullanılır,
+// böylece yanıt süresinden e-postanın kayıtlı olup olmadığı anlaşılmaz
+var dummyPasswordHash = hashPassword(randomString(16))
+
+// authenticate e-posta ve parolayı doğrular
+func (s *userStore) authenticate(email, password string) (User, error) {
+	s.mu.Lock()
+	u := s.byEmail(normalizeEmail(email))
+	var hash string
+	if u != nil {
+		hash = u.PasswordHash
+	}
+	s.mu.Unlock()
+
+	if hash == "" {
+		checkPassword(dummyPasswordHash, password)
+		return User{}, errInvalidCredentials
+	}
+	if !checkPassword(hash, password) {
+		return User{}, errInvalidCredentials
+	}
+	return u.User, nil
+}
+
+func (s *userStore) find(id string) (User, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[id]
+	if !ok {
+		return User{}, false
+	}
+	return u.User, true
+}
+
+// signInWithPayPal PayPal user_id'ye bağlı kullanıcıyı döner, yoksa yeni kullanıcı oluşturur.
+// Aynı e-postayla doğrulanmış bir yerel hesap varsa otomatik birleştirmez, hesabın sahibi giriş
+// yapıp /link/paypal ile bağlamalıdır. Doğrulanmamış hesap e-postayı tutamaz, e-posta PayPal
+// kullanıcısına geçer (bkz. applyPayPal).
+func (s *userStore) signInWithPayPal(id paypalIdentity) (User, error) {
+	if id.UserID == "" {
+		return User{}, errMissingPayPalUserID
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if u := s.byPayPal(id.UserID); u != nil {
+		s.applyPayPal(u, id, now)
+		s.persist()
+		return u.User, nil
+	}
+	if other := s.byEmail(id.Email); id.Email != "" && other != nil && other.EmailVerified {
+		return User{}, errLinkRequired
+	}
+
+	u := &storedUser{User: User{ID: "usr_" + randomString(12), CreatedAt: now}}
+	s.users[u.ID] = u
+	s.applyPayPal(u, id, now)
+	s.persist()
+	return u.User, nil
+}
+
+// linkPayPal giriş yapmış kullanıcının hesabına PayPal hesabını bağlar
+func (s *userStore) linkPayPal(userID string, id paypalIdentity) (User, error) {
+	if id.UserID == "" {
+		return User{}, errMissingPayPalUserID
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[userID]
+	if !ok {
+		return User{}, errUserNotFound
+	}
+	if other := s.byPayPal(id.UserID); other != nil && other.ID != userID {
+		return User{}, errPayPalLinked
+	}
+	if u.PayPalUserID != "" && u.PayPalUserID != id.UserID {
+		return User{}, errAlreadyLinked
+	}
+	s.applyPayPal(u, id, time.Now())
+	s.persist()
+	return u.User, nil
+}
+
+// unlinkPayPal PayPal bağlantısını kaldırır. Parolası olmayan kullanıcı giriş yapamaz hale geleceği için reddedilir.
+func (s *userStore) unlinkPayPal(userID string) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[userID]
+	if !ok {
+		return User{}, errUserNotFound
+	}
+	if u.PasswordHash == "" {
+		return User{}, errLastSignInMethod
+	}
+	u.PayPalUserID = ""
+	u.UpdatedAt = time.Now()
+	s.persist()
+	return u.User, nil
+}
+
+// applyPayPal PayPal kimliğini kullanıcıya yazar, kilit altında çağrılır. Yerel hesabın e-posta ve
+// adı yalnızca boşsa doldurulur (e-posta aynıysa doğrulanmış işaretlenir); parolasız, yalnızca PayPal
+// ile giren kullanıcılarda PayPal'daki değişiklikler izlenir. Başka hesapta doğrulanmış e-posta alınmaz;
+// doğrulanmamışsa o hesaptan kaldırılır, yoksa herkes başkasının e-postasıyla kayıt olup onu kilitleyebilirdi.
+func (s *userStore) applyPayPal(u *storedUser, id paypalIdentity, now time.Time) {
+	u.PayPalUserID = id.UserID
+	email := normalizeEmail(id.Email)
+	if email != "" && id.EmailVerified && (u.Email == "" || u.Email == email || u.PasswordHash == "") {
+		other := s.byEmail(email)
+		if other != nil && other.ID != u.ID && !other.EmailVerified {
+			log.Printf("users: email verified by PayPal for %s, removed from unverified user %s", u.ID, other.ID)
+			other.Email = ""
+			other.UpdatedAt = now
+			other = nil
+		}
+		if other == nil || other.ID == u.ID {
+			u.Email = email
+			u.EmailVerified = true
+		}
+	}
+	if id.Name != "" && (u.Name == "" || u.PasswordHash == "") {
+		u.Name = id.Name
+	}
+	u.UpdatedAt = now
+}
+
+// byEmail ve byPayPal kilit altında çağrılır
+func (s *userStore) byEmail(email string) *storedUser {
+	for _, u := range s.users {
+		if u.Email != "" && u.Email == normalizeEmail(email) {
+			return u
+		}
+	}
+	return nil
+}
+
+func (s *userStore) byPayPal(paypalUserID string) *storedUser {
+	for _, u := range s.users {
+		if u.PayPalUserID == paypalUserID {
+			return u
+		}
+	}
+	return nil
+}
+
+// persist kullanıcıları dosyaya yazar, kilit altında çağrılır
+func (s *userStore) persist() {
+	if s.path == "" {
+		return
+	}
+	list := make([]*storedUser, 0, len(s.users))
+	for _, u := range s.users {
+		list = append(list, u)
+	}
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		log.Printf("users: encode: %v", err)
+		return
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		log.Printf("users: write %s: %v", tmp, err)
+		return
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		log.Printf("users: rename %s: %v", tmp, err)
+	}
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestUserStoreRegisterAndAuthenticate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	s := openUserStore(path)
+
+	u, err := s.register(" Ada@Example.com ", "s3cret-pass", "Ada")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Email != "ada@example.com" || !u.HasPassword || u.EmailVerified {
+		t.Errorf("registered user = %+v", u)
+	}
+	if _, err := s.register("ada@example.com", "other", ""); !errors.Is(err, errEmailTaken) {
+		t.Errorf("second registration = %v, want %v", err, errEmailTaken)
+	}
+
+	reopened := openUserStore(path)
+	if got, err := reopened.authenticate("ADA@example.com", "s3cret-pass"); err != nil || got.ID != u.ID {
+		t.Errorf("authenticate after restart = %+v, %v", got, err)
+	}
+	if _, err := reopened.authenticate("ada@example.com", "wrong"); !errors.Is(err, errInvalidCredentials) {
+		t.Errorf("wrong password = %v, want %v", err, errInvalidCredentials)
+	}
+	if _, err := reopened.authenticate("nobody@example.com", "s3cret-pass"); !errors.Is(err, errInvalidCredentials) {
+		t.Errorf("unknown email = %v, want %v", err, errInvalidCredentials)
+	}
+}
+
+func TestUserStorePayPal(t *testing.T) {
+	s := openUserStore("")
+	local, _ := s.register("ada@example.com", "s3cret-pass", "Ada")
+
+	if _, err := s.signInWithPayPal(paypalIdentity{Email: "x@example.com"}); !errors.Is(err, errMissingPayPalUserID) {
+		t.Errorf("identity without user_id = %v", err)
+	}
+	ada := paypalIdentity{UserID: "PP-ADA", Email: "ada@example.com", EmailVerified: true, Name: "Ada L."}
+	linked, err := s.linkPayPal(local.ID, ada)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if linked.PayPalUserID != "PP-ADA" || !linked.EmailVerified || linked.Name != "Ada" {
+		t.Errorf("linked user = %+v", linked)
+	}
+	if u, err := s.signInWithPayPal(ada); err != nil || u.ID != local.ID {
+		t.Errorf("sign in after linking = %+v, %v", u, err)
+	}
+
+	bob, err := s.signInWithPayPal(paypalIdentity{UserID: "PP-BOB", Email: "bob@example.com", EmailVerified: true, Name: "Bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bob.HasPassword || bob.Email != "bob@example.com" {
+		t.Errorf("new PayPal user = %+v", bob)
+	}
+	if _, err := s.linkPayPal(local.ID, paypalIdentity{UserID: "PP-BOB"}); !errors.Is(err, errPayPalLinked) {
+		t.Errorf("linking another user's PayPal = %v, want %v", err, errPayPalLinked)
+	}
+	if _, err := s.linkPayPal(local.ID, paypalIdentity{UserID: "PP-OTHER"}); !errors.Is(err, errAlreadyLinked) {
+		t.Errorf("linking a second PayPal account = %v, want %v", err, errAlreadyLinked)
+	}
+
+	// Parolası olmayan kullanıcı PayPal bağlantısını kaldıramaz
+	if _, err := s.unlinkPayPal(bob.ID); !errors.Is(err, errLastSignInMethod) {
+		t.Errorf("unlink without a password = %v, want %v", err, errLastSignInMethod)
+	}
+	if u, err := s.unlinkPayPal(local.ID); err != nil || u.PayPalUserID != "" {
+		t.Errorf("unlink = %+v, %v", u, err)
+	}
+	// Aynı e-postalı doğrulanmış yerel hesap otomatik birleştirilmez
+	if _, err := s.signInWithPayPal(ada); !errors.Is(err, errLinkRequired) {
+		t.Errorf("sign in with a local account's email = %v, want %v", err, errLinkRequired)
+	}
+}
+
+func TestUserStorePayPalTakesUnverifiedEmail(t *testing.T) {
+	s := openUserStore("")
+	squatter, _ := s.register("eve@example.com", "s3cret-pass", "")
+
+	eve, err := s.signInWithPayPal(paypalIdentity{UserID: "PP-EVE", Email: "eve@example.com", EmailVerified: true})
+	if err != nil {
+		t.Fatalf("sign in with an unverified account's email = %v", err)
+	}
+	if eve.ID == squatter.ID || eve.Email != "eve@example.com" || !eve.EmailVerified {
+		t.Errorf("PayPal user = %+v", eve)
+	}
+	if u, _ := s.find(squatter.ID); u.Email != "" {
+		t.Errorf("unverified user kept the email: %+v", u)
+	}
+	if _, err := s.authenticate("eve@example.com", "s3cret-pass"); !errors.Is(err, errInvalidCredentials) {
+		t.Errorf("password sign in after transfer = %v, want %v", err, errInvalidCredentials)
+	}
+	if _, err := s.register("eve@example.com", "other-pass", ""); !errors.Is(err, errEmailTaken) {
+		t.Errorf("registering a verified email = %v, want %v", err, errEmailTaken)
+	}
+}